@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ashebanow/rainbridge/internal/ledger"
+)
+
+// clearConfigEnv unsets every configuration variable for the duration of the test.
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{
+		"RAINDROP_API_TOKEN", "KARAKEEP_API_TOKEN", "RAINDROP_BASE_URL", "KARAKEEP_BASE_URL",
+		"RAINBRIDGE_CHECKPOINT_FILE", "RAINBRIDGE_FAILURE_REPORT", "RAINBRIDGE_LIST_POLICY", "RAINBRIDGE_BOOKMARK_POLICY",
+		"RAINBRIDGE_NESTED_COLLECTIONS", "RAINBRIDGE_IMPORT_UNSORTED", "RAINBRIDGE_UNSORTED_LIST",
+		"RAINBRIDGE_IMPORT_TRASH", "RAINBRIDGE_TRASH_LIST", "RAINBRIDGE_ARCHIVE_COLLECTIONS",
+		"RAINBRIDGE_WORKERS", "RAINBRIDGE_RATE_LIMIT", "RAINBRIDGE_MAX_RETRIES",
+	} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	clearConfigEnv(t)
+
+	tests := []struct {
+		name         string
+		args         []string
+		env          map[string]string
+		expectedCode int
+		expectedOut  string
+		expectedErr  string
+	}{
+		{name: "no command", args: nil, expectedCode: exitUsage, expectedErr: "Commands:"},
+		{name: "version flag", args: []string{"--version"}, expectedCode: exitOK, expectedOut: "rainbridge dev"},
+		{name: "version command", args: []string{"version"}, expectedCode: exitOK, expectedOut: "rainbridge dev"},
+		{name: "help", args: []string{"--help"}, expectedCode: exitOK, expectedErr: "Exit codes:"},
+		{name: "help command", args: []string{"help", "cleanup"}, expectedCode: exitOK, expectedOut: "-yes"},
+		{name: "unknown command", args: []string{"frobnicate"}, expectedCode: exitUsage, expectedErr: `unknown command "frobnicate"`},
+		{name: "unknown flag", args: []string{"import", "--frobnicate"}, expectedCode: exitUsage, expectedErr: "flag provided but not defined"},
+		{name: "extra arguments", args: []string{"config", "extra"}, expectedCode: exitUsage, expectedErr: "unexpected arguments"},
+		{name: "missing tokens", args: []string{"import"}, expectedCode: exitConfig, expectedErr: "no Raindrop.io token"},
+		{
+			name:         "invalid policy",
+			args:         []string{"plan", "--raindrop-token", "r", "--karakeep-token", "k", "--list-policy", "sometimes"},
+			expectedCode: exitConfig,
+			expectedErr:  "invalid list policy",
+		},
+		{
+			name:         "invalid URL flag",
+			args:         []string{"config", "--karakeep-url", "karakeep.local"},
+			expectedCode: exitConfig,
+			expectedErr:  "KARAKEEP_BASE_URL",
+		},
+		{
+			name:         "invalid environment",
+			args:         []string{"config"},
+			env:          map[string]string{"RAINBRIDGE_IMPORT_TRASH": "maybe"},
+			expectedCode: exitConfig,
+			expectedErr:  "RAINBRIDGE_IMPORT_TRASH",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, &stdout, &stderr)
+			if code != tt.expectedCode {
+				t.Errorf("Expected exit code %d, got %d (stderr: %s)", tt.expectedCode, code, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.expectedOut) {
+				t.Errorf("Expected stdout to contain %q, got %q", tt.expectedOut, stdout.String())
+			}
+			if !strings.Contains(stderr.String(), tt.expectedErr) {
+				t.Errorf("Expected stderr to contain %q, got %q", tt.expectedErr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunConfigMasksTokensAndAppliesFlags(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("RAINDROP_API_TOKEN", "raindrop-secret-1234")
+	t.Setenv("RAINBRIDGE_LIST_POLICY", "update")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"config", "--list-policy", "duplicate", "--karakeep-url", "http://karakeep.local:3000", "--archive-collections", "Old, Done"}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr.String())
+	}
+
+	out := stdout.String()
+	if strings.Contains(out, "raindrop-secret") {
+		t.Errorf("Expected token to be masked, got:\n%s", out)
+	}
+	for _, expected := range []string{
+		"ends in 1234",
+		"KARAKEEP_API_TOKEN              (not set)",
+		"RAINBRIDGE_LIST_POLICY          duplicate",
+		"KARAKEEP_BASE_URL               http://karakeep.local:3000/api/v1",
+		`RAINBRIDGE_ARCHIVE_COLLECTIONS  "Old,Done"`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+// newCheckpoint writes a checkpoint with one created and one pre-existing
+// list and bookmark, and returns its path.
+func newCheckpoint(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	checkpoint, err := ledger.Open(path)
+	if err != nil {
+		t.Fatalf("ledger.Open failed: %v", err)
+	}
+	checkpoint.RecordList(1, "list-created")
+	checkpoint.RecordExistingList(2, "list-existing")
+	checkpoint.RecordBookmark(101, 1, "bookmark-created")
+	checkpoint.RecordExistingBookmark(102, 2, "bookmark-existing")
+	if err := checkpoint.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	return path
+}
+
+func TestRunVerify(t *testing.T) {
+	clearConfigEnv(t)
+	path := newCheckpoint(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/users/me":
+			fmt.Fprintln(w, `{"id": "user-1", "name": "Test User"}`)
+		case "/api/v1/lists":
+			fmt.Fprintln(w, `[{"id": "list-created"}, {"id": "list-existing"}]`)
+		case "/api/v1/bookmarks":
+			fmt.Fprintln(w, `[{"id": "bookmark-existing"}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"verify", "--karakeep-token", "k", "--karakeep-url", server.URL, "--checkpoint", path}, &stdout, &stderr)
+	if code != exitPartial {
+		t.Errorf("Expected exit code %d, got %d (stderr: %s)", exitPartial, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "missing bookmark bookmark-created (Raindrop bookmark 101)") {
+		t.Errorf("Expected missing bookmark to be reported, got:\n%s", stdout.String())
+	}
+}
+
+func TestRunCleanup(t *testing.T) {
+	clearConfigEnv(t)
+	path := newCheckpoint(t)
+
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.URL.Path == "/api/v1/users/me" {
+			fmt.Fprintln(w, `{"id": "user-1", "name": "Test User"}`)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	args := []string{"cleanup", "--karakeep-token", "k", "--karakeep-url", server.URL, "--checkpoint", path}
+
+	var stdout, stderr bytes.Buffer
+	if code := run(args, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr.String())
+	}
+	if len(deleted) != 0 {
+		t.Fatalf("Expected nothing to be deleted without --yes, got %v", deleted)
+	}
+	if !strings.Contains(stdout.String(), "would delete 1 lists and 1 bookmarks") {
+		t.Errorf("Unexpected output: %s", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run(append(args, "--yes"), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr.String())
+	}
+	expected := "/api/v1/bookmarks/bookmark-created,/api/v1/lists/list-created"
+	if strings.Join(deleted, ",") != expected {
+		t.Errorf("Expected deletions %s, got %v", expected, deleted)
+	}
+
+	reopened, err := ledger.Open(path)
+	if err != nil {
+		t.Fatalf("ledger.Open failed: %v", err)
+	}
+	if lists, bookmarks := reopened.Counts(); lists != 0 || bookmarks != 0 {
+		t.Errorf("Expected an empty checkpoint after cleanup, got %d lists and %d bookmarks", lists, bookmarks)
+	}
+}
+
+func TestRunPreflightRejectsInvalidTokens(t *testing.T) {
+	clearConfigEnv(t)
+
+	raindropServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/user" {
+			t.Errorf("Expected only the preflight request to Raindrop, got %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer good-raindrop" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintln(w, `{"result": true, "user": {"_id": 1, "fullName": "Test User"}}`)
+	}))
+	defer raindropServer.Close()
+
+	karakeepServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/version":
+			fmt.Fprintln(w, `{"version": "0.25.0"}`)
+		case "/api/v1/users/me":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			t.Errorf("Expected only preflight requests to Karakeep, got %s", r.URL.Path)
+		}
+	}))
+	defer karakeepServer.Close()
+
+	tests := []struct {
+		name          string
+		raindropToken string
+		expectedErr   string
+	}{
+		{"raindrop token rejected", "bad-raindrop", "RAINDROP_API_TOKEN was rejected by Raindrop.io"},
+		{"karakeep token rejected", "good-raindrop", "KARAKEEP_API_TOKEN was rejected by Karakeep"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run([]string{
+				"import",
+				"--raindrop-token", tt.raindropToken, "--raindrop-url", raindropServer.URL,
+				"--karakeep-token", "any", "--karakeep-url", karakeepServer.URL,
+				"--checkpoint", filepath.Join(t.TempDir(), "checkpoint.json"),
+			}, &stdout, &stderr)
+			if code != exitConfig {
+				t.Errorf("Expected exit code %d, got %d", exitConfig, code)
+			}
+			if !strings.Contains(stderr.String(), tt.expectedErr) || !strings.Contains(stderr.String(), "Create ") {
+				t.Errorf("Expected an actionable message containing %q, got %q", tt.expectedErr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunImportFailureReportAndRetry(t *testing.T) {
+	clearConfigEnv(t)
+
+	var collectionFetches int
+	raindropServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/v1/user":
+			fmt.Fprintln(w, `{"result": true, "user": {"_id": 1, "fullName": "Test User"}}`)
+		case r.URL.Path == "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}]}`)
+		case r.URL.Path == "/rest/v1/raindrop/101":
+			fmt.Fprintln(w, `{"result": true, "item": {"_id": 101, "title": "Flaky", "link": "https://example.com/flaky"}}`)
+		case r.URL.Path == "/rest/v1/raindrops/1" && r.URL.Query().Get("page") == "0":
+			collectionFetches++
+			fmt.Fprintln(w, `{"items": [{"_id": 100, "title": "Fine", "link": "https://example.com/fine"}, {"_id": 101, "title": "Flaky", "link": "https://example.com/flaky"}]}`)
+		default:
+			fmt.Fprintln(w, `{"items": []}`)
+		}
+	}))
+	defer raindropServer.Close()
+
+	flaky := true
+	var created []string
+	karakeepServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/version":
+			fmt.Fprintln(w, `{"version": "0.25.0"}`)
+		case r.URL.Path == "/api/v1/users/me":
+			fmt.Fprintln(w, `{"id": "user-1", "name": "Test User"}`)
+		case r.Method == "GET":
+			fmt.Fprintln(w, `[]`)
+		case r.URL.Path == "/api/v1/lists":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "list-1", "name": "Reading"}`)
+		case r.URL.Path == "/api/v1/bookmarks":
+			var bookmark struct{ URL string }
+			json.NewDecoder(r.Body).Decode(&bookmark)
+			if flaky && strings.HasSuffix(bookmark.URL, "/flaky") {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			created = append(created, bookmark.URL)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": "bookmark-%d"}`, len(created))
+		default:
+			fmt.Fprintln(w, `{}`)
+		}
+	}))
+	defer karakeepServer.Close()
+
+	dir := t.TempDir()
+	reportPath := filepath.Join(dir, "failures.jsonl")
+	args := []string{
+		"import",
+		"--raindrop-token", "r", "--raindrop-url", raindropServer.URL,
+		"--karakeep-token", "k", "--karakeep-url", karakeepServer.URL,
+		"--checkpoint", filepath.Join(dir, "checkpoint.json"),
+		"--failure-report", reportPath,
+		"--rate-limit", "0", "--max-retries", "0",
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run(args, &stdout, &stderr); code != exitPartial {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitPartial, code, stderr.String())
+	}
+	for _, expected := range []string{"Import summary", "Reading", "bookmark   Flaky <https://example.com/flaky> in Reading", "Wrote 1 failures to " + reportPath} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, stdout.String())
+		}
+	}
+
+	report, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("Failed to read the failure report: %v", err)
+	}
+	var failure map[string]any
+	if err := json.Unmarshal(report, &failure); err != nil {
+		t.Fatalf("Expected one JSON object in the failure report, got %q: %v", report, err)
+	}
+	for key, expected := range map[string]any{"stage": "bookmark", "raindropId": 101.0, "url": "https://example.com/flaky", "collectionTitle": "Reading", "status": 502.0} {
+		if failure[key] != expected {
+			t.Errorf("Expected %s to be %v in the failure report, got %v", key, expected, failure[key])
+		}
+	}
+
+	flaky = false
+	stdout.Reset()
+	if code := run(append(args, "--retry-failed"), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr.String())
+	}
+	if collectionFetches != 1 {
+		t.Errorf("Expected the retry to fetch only the failed bookmark, but the collection was fetched %d times", collectionFetches)
+	}
+	if strings.Join(created, ",") != "https://example.com/fine,https://example.com/flaky" {
+		t.Errorf("Expected only the failed bookmark to be created again, got %v", created)
+	}
+	if _, err := os.Stat(reportPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the failure report to be removed after a successful retry, got %v", err)
+	}
+
+	stdout.Reset()
+	if code := run(append(args, "--retry-failed"), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code 0 without a failure report, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "nothing to retry") {
+		t.Errorf("Unexpected output: %s", stdout.String())
+	}
+}
+
+func TestRunImportStopsWhenTokenIsRejected(t *testing.T) {
+	clearConfigEnv(t)
+
+	raindropServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/v1/user":
+			fmt.Fprintln(w, `{"result": true, "user": {"_id": 1, "fullName": "Test User"}}`)
+		case r.URL.Path == "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}, {"_id": 2, "title": "Work"}]}`)
+		case r.URL.Query().Get("page") == "0":
+			fmt.Fprintln(w, `{"items": [{"_id": 100, "title": "First", "link": "https://example.com/1"}, {"_id": 101, "title": "Second", "link": "https://example.com/2"}]}`)
+		default:
+			fmt.Fprintln(w, `{"items": []}`)
+		}
+	}))
+	defer raindropServer.Close()
+
+	bookmarkRequests := 0
+	karakeepServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/version":
+			fmt.Fprintln(w, `{"version": "0.25.0"}`)
+		case r.URL.Path == "/api/v1/users/me":
+			fmt.Fprintln(w, `{"id": "user-1", "name": "Test User"}`)
+		case r.Method == "GET":
+			fmt.Fprintln(w, `[]`)
+		case r.URL.Path == "/api/v1/lists":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "list-1", "name": "Reading"}`)
+		case r.URL.Path == "/api/v1/bookmarks":
+			bookmarkRequests++
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			fmt.Fprintln(w, `{}`)
+		}
+	}))
+	defer karakeepServer.Close()
+
+	dir := t.TempDir()
+	args := []string{
+		"import",
+		"--raindrop-token", "r", "--raindrop-url", raindropServer.URL,
+		"--karakeep-token", "k", "--karakeep-url", karakeepServer.URL,
+		"--checkpoint", filepath.Join(dir, "checkpoint.json"),
+		"--failure-report", filepath.Join(dir, "failures.jsonl"),
+		"--rate-limit", "0", "--workers", "1",
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run(args, &stdout, &stderr); code != exitConfig {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitConfig, code, stderr.String())
+	}
+	if bookmarkRequests != 1 {
+		t.Errorf("Expected the import to stop after the first rejected bookmark, got %d requests", bookmarkRequests)
+	}
+	for _, expected := range []string{"import stopped, the checkpoint was saved", "KARAKEEP_API_TOKEN was rejected"} {
+		if !strings.Contains(stderr.String(), expected) {
+			t.Errorf("Expected stderr to contain %q, got:\n%s", expected, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ashebanow/rainbridge/internal/config"
+	"github.com/ashebanow/rainbridge/internal/importer"
+	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/ledger"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+	"github.com/ashebanow/rainbridge/internal/retry"
+)
+
+func runImport(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	cfg, flags, err := commandConfig("import", "Imports all Raindrop.io collections and bookmarks into Karakeep. An\ninterrupted import resumes from the checkpoint file. Items that fail to\nimport are written to the failure report, and the command exits with status\n4. Run \"rainbridge import --retry-failed\" to retry only those items.", stderr)
+	if err != nil {
+		return err
+	}
+	dryRun := flags.Bool("dry-run", false, "print the migration plan without writing to Karakeep (same as the plan command)")
+	planOutput := flags.String("plan-output", "", "with --dry-run, write the plan as JSON to this file (\"-\" for stdout)")
+	retryFailed := flags.Bool("retry-failed", false, "retry only the items listed in the failure report")
+	if err := parseConfigFlags(flags, args, cfg); err != nil {
+		return err
+	}
+	if *dryRun && *retryFailed {
+		return usageError("--dry-run and --retry-failed cannot be combined")
+	}
+
+	var failures []importer.ItemError
+	if *retryFailed {
+		if failures, err = importer.ReadFailureReport(cfg.FailureReportPath); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				fmt.Fprintf(stdout, "No failure report at %s, nothing to retry.\n", cfg.FailureReportPath)
+				return nil
+			}
+			return err
+		}
+	}
+
+	imp, err := newImporter(ctx, cfg, true)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		return writePlan(ctx, imp, *planOutput, stdout)
+	}
+
+	var result *importer.ImportResult
+	if *retryFailed {
+		result, err = imp.RetryFailures(ctx, failures)
+	} else {
+		result, err = imp.RunImport(ctx)
+	}
+	return finishRun("import", result, err, cfg, *retryFailed, stdout)
+}
+
+func runSync(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	cfg, flags, err := commandConfig("sync", "Imports the Raindrop.io bookmarks added or edited since the last import or\nsync, and updates the Karakeep bookmarks they were imported into. Run it\nperiodically to keep Karakeep up to date while Raindrop.io is still in use.\nBookmarks deleted or moved in Raindrop.io are handled as --deleted-policy\nand --moved-policy say. Items that fail are written to the failure report,\nas by import.", stderr)
+	if err != nil {
+		return err
+	}
+	if err := parseConfigFlags(flags, args, cfg); err != nil {
+		return err
+	}
+
+	imp, err := newImporter(ctx, cfg, true)
+	if err != nil {
+		return err
+	}
+
+	result, err := imp.Sync(ctx)
+	return finishRun("sync", result, err, cfg, false, stdout)
+}
+
+func runExport(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	cfg, flags, err := commandConfig("export", "Exports the Karakeep lists and bookmarks into Raindrop.io, the reverse of\nimport. Lists become collections, and bookmarks keep their tags, notes,\nfavourites and highlights. Exported items are recorded in the checkpoint\nfile, so running export again only exports what was added since, and\nimport and sync do not copy them back.", stderr)
+	if err != nil {
+		return err
+	}
+	if err := parseConfigFlags(flags, args, cfg); err != nil {
+		return err
+	}
+
+	imp, err := newImporter(ctx, cfg, true)
+	if err != nil {
+		return err
+	}
+
+	result, err := imp.Export(ctx)
+	result.PrintSummary(stdout)
+	if errors.Is(err, context.Canceled) {
+		return interruptedError("export interrupted, the checkpoint was saved; run export again to resume")
+	}
+	if tokenErr := rejectedToken(err, "export stopped, the checkpoint was saved"); tokenErr != nil {
+		return tokenErr
+	}
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+	if result.HasFailures() {
+		return partialError("%d items failed to export, run \"rainbridge export\" again to retry them", result.Failures())
+	}
+	return nil
+}
+
+// finishRun prints the summary of an import, retry or sync named name,
+// writes the failure report and returns the error the command exits with.
+// A retry that stopped early keeps the report it read instead.
+func finishRun(name string, result *importer.ImportResult, err error, cfg *config.Config, retrying bool, stdout io.Writer) error {
+	result.PrintSummary(stdout)
+	interrupted := errors.Is(err, context.Canceled)
+	tokenErr := rejectedToken(err, name+" stopped, the checkpoint was saved")
+	if (interrupted || tokenErr != nil) && retrying {
+		// The report still lists the failures that were not retried yet.
+		fmt.Fprintf(stdout, "Kept the failure report at %s\n", cfg.FailureReportPath)
+	} else if reportErr := importer.WriteFailureReport(cfg.FailureReportPath, result.Errors); reportErr != nil {
+		log.Printf("Could not write the failure report: %v", reportErr)
+	} else if result.HasFailures() {
+		fmt.Fprintf(stdout, "Wrote %d failures to %s\n", len(result.Errors), cfg.FailureReportPath)
+	}
+	if interrupted {
+		return interruptedError("%s interrupted, the checkpoint was saved; run the same command again to resume", name)
+	}
+	if tokenErr != nil {
+		return tokenErr
+	}
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	if result.HasFailures() {
+		// A sync with failures does not move its starting point, so the
+		// next one tries them again.
+		retry := "rainbridge import --retry-failed"
+		if name == "sync" {
+			retry = "rainbridge sync"
+		}
+		return partialError("%d items failed to %s, run \"%s\" to retry them", result.Failures(), name, retry)
+	}
+	return nil
+}
+
+func runPlan(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	cfg, flags, err := commandConfig("plan", "Prints the lists, bookmarks and tags an import would create, without\nwriting anything to Karakeep.", stderr)
+	if err != nil {
+		return err
+	}
+	output := flags.String("output", "", "write the plan as JSON to this file (\"-\" for stdout)")
+	if err := parseConfigFlags(flags, args, cfg); err != nil {
+		return err
+	}
+
+	imp, err := newImporter(ctx, cfg, true)
+	if err != nil {
+		return err
+	}
+	return writePlan(ctx, imp, *output, stdout)
+}
+
+func runVerify(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	cfg, flags, err := commandConfig("verify", "Checks that every list and bookmark recorded in the checkpoint file still\nexists in Karakeep. Exits with status 4 if any are missing.", stderr)
+	if err != nil {
+		return err
+	}
+	if err := parseConfigFlags(flags, args, cfg); err != nil {
+		return err
+	}
+
+	imp, err := newImporter(ctx, cfg, false)
+	if err != nil {
+		return err
+	}
+
+	report, err := imp.Verify(ctx)
+	if err != nil {
+		return fmt.Errorf("verify failed: %w", err)
+	}
+	report.Print(stdout)
+	if !report.OK() {
+		return partialError("%d lists and %d bookmarks are missing", len(report.MissingLists), len(report.MissingBookmarks))
+	}
+	return nil
+}
+
+func runCleanup(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	cfg, flags, err := commandConfig("cleanup", "Deletes the lists and bookmarks created by previous imports, as recorded in\nthe checkpoint file. Lists and bookmarks that already existed in Karakeep are\nleft alone. Nothing is deleted unless --yes is given.", stderr)
+	if err != nil {
+		return err
+	}
+	yes := flags.Bool("yes", false, "delete without asking for confirmation")
+	if err := parseConfigFlags(flags, args, cfg); err != nil {
+		return err
+	}
+
+	imp, err := newImporter(ctx, cfg, false)
+	if err != nil {
+		return err
+	}
+
+	lists, bookmarks := imp.CleanupCounts()
+	if !*yes {
+		fmt.Fprintf(stdout, "This would delete %d lists and %d bookmarks from Karakeep.\n", lists, bookmarks)
+		fmt.Fprintln(stdout, "Run \"rainbridge cleanup --yes\" to delete them.")
+		return nil
+	}
+
+	result, err := imp.Cleanup(ctx)
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintf(stdout, "Deleted %d lists and %d bookmarks before being interrupted.\n", result.ListsDeleted, result.BookmarksDeleted)
+		return interruptedError("cleanup interrupted, run cleanup again to delete the rest")
+	}
+	if tokenErr := rejectedToken(err, "cleanup stopped"); tokenErr != nil {
+		fmt.Fprintf(stdout, "Deleted %d lists and %d bookmarks before the API key was rejected.\n", result.ListsDeleted, result.BookmarksDeleted)
+		return tokenErr
+	}
+	if err != nil {
+		return fmt.Errorf("cleanup failed: %w", err)
+	}
+	fmt.Fprintf(stdout, "Deleted %d lists and %d bookmarks, left %d pre-existing items in place.\n",
+		result.ListsDeleted, result.BookmarksDeleted, result.Kept)
+	if result.Failed > 0 {
+		return partialError("%d items could not be deleted, run cleanup again to retry", result.Failed)
+	}
+	return nil
+}
+
+func runConfig(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	cfg, flags, err := commandConfig("config", "Prints the configuration that results from the environment, the .env file\nand the given flags. Tokens are masked.", stderr)
+	if err != nil {
+		return err
+	}
+	if err := parseConfigFlags(flags, args, cfg); err != nil {
+		return err
+	}
+
+	settings := []struct{ name, value string }{
+		{"RAINDROP_API_TOKEN", maskToken(cfg.RaindropToken)},
+		{"KARAKEEP_API_TOKEN", maskToken(cfg.KarakeepToken)},
+		{"RAINDROP_BASE_URL", cfg.RaindropBaseURL},
+		{"KARAKEEP_BASE_URL", cfg.KarakeepBaseURL},
+		{"RAINBRIDGE_CHECKPOINT_FILE", cfg.CheckpointPath},
+		{"RAINBRIDGE_FAILURE_REPORT", cfg.FailureReportPath},
+		{"RAINBRIDGE_LIST_POLICY", cfg.ListPolicy},
+		{"RAINBRIDGE_BOOKMARK_POLICY", cfg.BookmarkPolicy},
+		{"RAINBRIDGE_DELETED_POLICY", cfg.DeletedPolicy},
+		{"RAINBRIDGE_MOVED_POLICY", cfg.MovedPolicy},
+		{"RAINBRIDGE_NESTED_COLLECTIONS", fmt.Sprint(cfg.NestedCollections)},
+		{"RAINBRIDGE_IMPORT_UNSORTED", fmt.Sprint(cfg.ImportUnsorted)},
+		{"RAINBRIDGE_UNSORTED_LIST", fmt.Sprintf("%q", cfg.UnsortedListName)},
+		{"RAINBRIDGE_IMPORT_TRASH", fmt.Sprint(cfg.ImportTrash)},
+		{"RAINBRIDGE_TRASH_LIST", fmt.Sprintf("%q", cfg.TrashListName)},
+		{"RAINBRIDGE_ARCHIVE_COLLECTIONS", fmt.Sprintf("%q", strings.Join(cfg.ArchiveCollections, ","))},
+		{"RAINBRIDGE_WORKERS", fmt.Sprint(cfg.Workers)},
+		{"RAINBRIDGE_RATE_LIMIT", fmt.Sprint(cfg.RateLimit)},
+		{"RAINBRIDGE_MAX_RETRIES", fmt.Sprint(cfg.MaxRetries)},
+	}
+	for _, setting := range settings {
+		fmt.Fprintf(stdout, "%-31s %s\n", setting.name, setting.value)
+	}
+	return nil
+}
+
+// commandConfig loads the configuration from the environment and creates the
+// flag set for a command that takes the configuration flags.
+func commandConfig(name, usage string, stderr io.Writer) (*config.Config, *flag.FlagSet, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, configError(err)
+	}
+	flags := newFlagSet(name, usage, stderr)
+	addConfigFlags(flags, cfg)
+	return cfg, flags, nil
+}
+
+// parseConfigFlags parses the flags of a command created by commandConfig
+// and validates the resulting configuration.
+func parseConfigFlags(flags *flag.FlagSet, args []string, cfg *config.Config) error {
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usageError("unexpected arguments: %q", flags.Args())
+	}
+	if err := cfg.Validate(); err != nil {
+		return configError(err)
+	}
+	return nil
+}
+
+// newImporter creates an importer from the configuration after checking that
+// the services accept the tokens. needsRaindrop is false for commands that
+// only talk to Karakeep.
+func newImporter(ctx context.Context, cfg *config.Config, needsRaindrop bool) (*importer.Importer, error) {
+	if err := checkTokensPresent(cfg, needsRaindrop); err != nil {
+		return nil, err
+	}
+
+	listPolicy, err := importer.ParseDuplicatePolicy(cfg.ListPolicy)
+	if err != nil {
+		return nil, configError(fmt.Errorf("invalid list policy: %w", err))
+	}
+	bookmarkPolicy, err := importer.ParseDuplicatePolicy(cfg.BookmarkPolicy)
+	if err != nil {
+		return nil, configError(fmt.Errorf("invalid bookmark policy: %w", err))
+	}
+	deletedPolicy, err := importer.ParseDeletedPolicy(cfg.DeletedPolicy)
+	if err != nil {
+		return nil, configError(err)
+	}
+	movedPolicy, err := importer.ParseMovedPolicy(cfg.MovedPolicy)
+	if err != nil {
+		return nil, configError(err)
+	}
+
+	checkpoint, err := ledger.Open(cfg.CheckpointPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+
+	retryPolicy := retry.Default()
+	retryPolicy.MaxRetries = cfg.MaxRetries
+
+	raindropClient := raindrop.NewClient(cfg.RaindropToken)
+	raindropClient.SetBaseURL(cfg.RaindropBaseURL)
+	raindropClient.SetRateLimit(cfg.RateLimit, cfg.Workers)
+	raindropClient.SetRetryPolicy(retryPolicy)
+	raindropClient.SetUserAgent(userAgent())
+	karakeepClient := karakeep.NewClient(cfg.KarakeepToken)
+	karakeepClient.SetBaseURL(cfg.KarakeepBaseURL)
+	karakeepClient.SetRateLimit(cfg.RateLimit, cfg.Workers)
+	karakeepClient.SetRetryPolicy(retryPolicy)
+	karakeepClient.SetUserAgent(userAgent())
+
+	if err := preflight(ctx, raindropClient, karakeepClient, cfg, needsRaindrop); err != nil {
+		return nil, err
+	}
+
+	imp := importer.NewImporter(raindropClient, karakeepClient)
+	imp.Ledger = checkpoint
+	imp.ListPolicy = listPolicy
+	imp.BookmarkPolicy = bookmarkPolicy
+	imp.DeletedPolicy = deletedPolicy
+	imp.MovedPolicy = movedPolicy
+	imp.NestedCollections = cfg.NestedCollections
+	imp.ImportUnsorted = cfg.ImportUnsorted
+	imp.UnsortedListName = cfg.UnsortedListName
+	imp.ImportTrash = cfg.ImportTrash
+	imp.TrashListName = cfg.TrashListName
+	imp.ArchiveCollections = cfg.ArchiveCollections
+	imp.Workers = cfg.Workers
+	return imp, nil
+}
+
+// writePlan builds the migration plan and prints it, or writes it as JSON to
+// path when one is given.
+func writePlan(ctx context.Context, imp *importer.Importer, path string, stdout io.Writer) error {
+	plan, err := imp.BuildPlan(ctx)
+	if err != nil {
+		return fmt.Errorf("planning failed: %w", err)
+	}
+
+	switch path {
+	case "":
+		plan.Print(stdout)
+		return nil
+	case "-":
+		return plan.WriteJSON(stdout)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := plan.WriteJSON(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	plan.Print(stdout)
+	log.Printf("Wrote plan to %s", path)
+	return nil
+}
+
+// maskToken shows whether a token is set without revealing it.
+func maskToken(token string) string {
+	switch {
+	case token == "":
+		return "(not set)"
+	case len(token) <= 8:
+		return "(set)"
+	default:
+		return "(set, ends in " + token[len(token)-4:] + ")"
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ashebanow/rainbridge/internal/config"
+)
+
+// newFlagSet creates the flag set for a command. usage is printed above the
+// flag defaults in the command's help.
+func newFlagSet(name, usage string, output io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("rainbridge "+name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprintf(output, "Usage: rainbridge %s [flags]\n", name)
+		if usage != "" {
+			fmt.Fprintf(output, "\n%s\n", usage)
+		}
+		fmt.Fprintln(output, "\nFlags:")
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses args, converting errors already reported by the flag
+// package into errFlagParse.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return &cliError{code: exitUsage, err: errFlagParse}
+}
+
+// addConfigFlags registers a flag for every configuration option. The flags
+// default to the values loaded from the environment, so flags take
+// precedence over environment variables.
+func addConfigFlags(flags *flag.FlagSet, cfg *config.Config) {
+	flags.Var(secretValue{&cfg.RaindropToken}, "raindrop-token", "Raindrop.io API token (RAINDROP_API_TOKEN)")
+	flags.Var(secretValue{&cfg.KarakeepToken}, "karakeep-token", "Karakeep API token (KARAKEEP_API_TOKEN)")
+	flags.StringVar(&cfg.RaindropBaseURL, "raindrop-url", cfg.RaindropBaseURL, "Raindrop.io API base URL (RAINDROP_BASE_URL)")
+	flags.StringVar(&cfg.KarakeepBaseURL, "karakeep-url", cfg.KarakeepBaseURL, "Karakeep API base URL, such as https://karakeep.example.com/api/v1 (KARAKEEP_BASE_URL)")
+	flags.StringVar(&cfg.CheckpointPath, "checkpoint", cfg.CheckpointPath, "checkpoint file used to resume imports (RAINBRIDGE_CHECKPOINT_FILE)")
+	flags.StringVar(&cfg.FailureReportPath, "failure-report", cfg.FailureReportPath, "JSON Lines file listing the items that failed to import (RAINBRIDGE_FAILURE_REPORT)")
+	flags.StringVar(&cfg.ListPolicy, "list-policy", cfg.ListPolicy, "what to do with lists that already exist: skip, update or duplicate (RAINBRIDGE_LIST_POLICY)")
+	flags.StringVar(&cfg.BookmarkPolicy, "bookmark-policy", cfg.BookmarkPolicy, "what to do with bookmarks that already exist: skip, update or duplicate (RAINBRIDGE_BOOKMARK_POLICY)")
+	flags.StringVar(&cfg.DeletedPolicy, "deleted-policy", cfg.DeletedPolicy, "what sync does with bookmarks deleted in Raindrop.io: ignore, archive or delete (RAINBRIDGE_DELETED_POLICY)")
+	flags.StringVar(&cfg.MovedPolicy, "moved-policy", cfg.MovedPolicy, "what sync does with bookmarks moved to another collection: ignore or move (RAINBRIDGE_MOVED_POLICY)")
+	flags.BoolVar(&cfg.NestedCollections, "nested-collections", cfg.NestedCollections, "import nested collections as nested lists (RAINBRIDGE_NESTED_COLLECTIONS)")
+	flags.BoolVar(&cfg.ImportUnsorted, "import-unsorted", cfg.ImportUnsorted, "import the Unsorted collection (RAINBRIDGE_IMPORT_UNSORTED)")
+	flags.StringVar(&cfg.UnsortedListName, "unsorted-list", cfg.UnsortedListName, "list for Unsorted bookmarks, empty for none (RAINBRIDGE_UNSORTED_LIST)")
+	flags.BoolVar(&cfg.ImportTrash, "import-trash", cfg.ImportTrash, "import the Trash collection (RAINBRIDGE_IMPORT_TRASH)")
+	flags.StringVar(&cfg.TrashListName, "trash-list", cfg.TrashListName, "list for Trash bookmarks (RAINBRIDGE_TRASH_LIST)")
+	flags.IntVar(&cfg.Workers, "workers", cfg.Workers, "number of bookmarks imported concurrently (RAINBRIDGE_WORKERS)")
+	flags.Float64Var(&cfg.RateLimit, "rate-limit", cfg.RateLimit, "maximum requests per second to each of Raindrop.io and Karakeep, 0 for no limit (RAINBRIDGE_RATE_LIMIT)")
+	flags.IntVar(&cfg.MaxRetries, "max-retries", cfg.MaxRetries, "times a rate limited or transiently failed request is retried (RAINBRIDGE_MAX_RETRIES)")
+	flags.Var(listValue{&cfg.ArchiveCollections}, "archive-collections", "comma-separated collection names or IDs whose bookmarks are archived (RAINBRIDGE_ARCHIVE_COLLECTIONS)")
+}
+
+// secretValue is a string flag whose value is never shown in the help.
+type secretValue struct {
+	value *string
+}
+
+func (s secretValue) String() string { return "" }
+
+func (s secretValue) Set(value string) error {
+	*s.value = value
+	return nil
+}
+
+// listValue is a comma-separated list flag.
+type listValue struct {
+	values *[]string
+}
+
+func (l listValue) String() string {
+	if l.values == nil {
+		return ""
+	}
+	return strings.Join(*l.values, ",")
+}
+
+func (l listValue) Set(value string) error {
+	*l.values = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l.values = append(*l.values, item)
+		}
+	}
+	return nil
+}
@@ -1,26 +1,196 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Exit codes returned by rainbridge.
+const (
+	exitOK      = 0
+	exitFailure = 1 // the command failed
+	exitUsage   = 2 // invalid command line
+	exitConfig  = 3 // invalid or incomplete configuration
+	exitPartial = 4 // the command finished, but some items failed
 
-	"github.com/ashebanow/rainbridge/internal/config"
-	"github.com/ashebanow/rainbridge/internal/importer"
-	"github.com/ashebanow/rainbridge/internal/karakeep"
-	"github.com/ashebanow/rainbridge/internal/raindrop"
+	exitInterrupted = 130 // stopped by SIGINT or SIGTERM
 )
 
+// command is a rainbridge subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string, stdout, stderr io.Writer) error
+}
+
+// commands lists the subcommands in the order they are shown in the help.
+var commands = []command{
+	{"import", "Import Raindrop.io bookmarks into Karakeep", runImport},
+	{"sync", "Import what was added or edited in Raindrop.io since the last run", runSync},
+	{"export", "Export Karakeep bookmarks back into Raindrop.io", runExport},
+	{"plan", "Show what an import would do without writing anything", runPlan},
+	{"verify", "Check that everything in the checkpoint still exists in Karakeep", runVerify},
+	{"cleanup", "Delete the lists and bookmarks created by previous imports", runCleanup},
+	{"config", "Show the effective configuration", runConfig},
+	{"version", "Show version information", runVersion},
+}
+
 func main() {
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the process exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rainbridge", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	showVersion := flags.Bool("version", false, "show version information and exit")
+	flags.Usage = func() { printUsage(stderr) }
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if *showVersion {
+		fmt.Fprintln(stdout, versionString())
+		return exitOK
+	}
+
+	if flags.NArg() == 0 {
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	name, rest := flags.Arg(0), flags.Args()[1:]
+	if name == "help" {
+		return runHelp(rest, stdout, stderr)
 	}
 
-	raindropClient := raindrop.NewClient(cfg.RaindropToken)
-	karakeepClient := karakeep.NewClient(cfg.KarakeepToken)
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(stderr, "rainbridge: unknown command %q\n\n", name)
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	// The first SIGINT or SIGTERM cancels ctx, so that the command can finish
+	// the items in progress and save its checkpoint. A second one ends the
+	// process at once.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stopNotice := context.AfterFunc(ctx, func() {
+		stop()
+		fmt.Fprintln(stderr, "rainbridge: interrupted, finishing the items in progress (press Ctrl-C again to quit now)")
+	})
+	defer stopNotice()
 
-	importer := importer.NewImporter(raindropClient, karakeepClient)
+	return exitCode(cmd.run(ctx, rest, stdout, stderr), stderr)
+}
 
-	if err := importer.RunImport(); err != nil {
-		log.Fatalf("Import failed: %v", err)
+// runHelp prints the help for a command, or the general usage.
+func runHelp(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stdout)
+		return exitOK
+	}
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		fmt.Fprintf(stderr, "rainbridge: unknown command %q\n", args[0])
+		return exitUsage
+	}
+	return exitCode(cmd.run(context.Background(), []string{"-h"}, stdout, stdout), stderr)
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "rainbridge imports bookmarks from Raindrop.io into Karakeep.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintln(w, "  rainbridge <command> [flags]")
+	fmt.Fprintln(w, "  rainbridge --version")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run \"rainbridge help <command>\" for the flags of a command. Settings can")
+	fmt.Fprintln(w, "also be given with the environment variables named in the flag help, or")
+	fmt.Fprintln(w, "in a .env file.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Exit codes:")
+	fmt.Fprintln(w, "  0    success")
+	fmt.Fprintln(w, "  1    the command failed")
+	fmt.Fprintln(w, "  2    invalid command line")
+	fmt.Fprintln(w, "  3    invalid or incomplete configuration")
+	fmt.Fprintln(w, "  4    some bookmarks or lists could not be processed")
+	fmt.Fprintln(w, "  130  interrupted; run the command again to resume")
+}
+
+// cliError is an error that carries the exit code to return for it.
+type cliError struct {
+	code int
+	err  error
+}
+
+func (e *cliError) Error() string { return e.err.Error() }
+
+func (e *cliError) Unwrap() error { return e.err }
+
+// errFlagParse is returned for flags the flag package has already reported.
+var errFlagParse = errors.New("invalid flags")
+
+// usageError returns an error that exits with exitUsage.
+func usageError(format string, args ...any) error {
+	return &cliError{code: exitUsage, err: fmt.Errorf(format, args...)}
+}
+
+// configError returns an error that exits with exitConfig.
+func configError(err error) error {
+	return &cliError{code: exitConfig, err: err}
+}
+
+// partialError returns an error that exits with exitPartial.
+func partialError(format string, args ...any) error {
+	return &cliError{code: exitPartial, err: fmt.Errorf(format, args...)}
+}
+
+// interruptedError returns an error that exits with exitInterrupted.
+func interruptedError(format string, args ...any) error {
+	return &cliError{code: exitInterrupted, err: fmt.Errorf(format, args...)}
+}
+
+// exitCode reports err and returns the exit code for it.
+func exitCode(err error, stderr io.Writer) int {
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+
+	code := exitFailure
+	var cliErr *cliError
+	switch {
+	case errors.As(err, &cliErr):
+		code = cliErr.code
+	case errors.Is(err, context.Canceled):
+		code = exitInterrupted
+	}
+	if !errors.Is(err, errFlagParse) {
+		fmt.Fprintf(stderr, "rainbridge: %v\n", err)
 	}
+	return code
 }
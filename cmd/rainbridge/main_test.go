@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"strings"
@@ -756,3 +759,49 @@ func TestMainWithMockErrors(t *testing.T) {
 		})
 	}
 }
+
+// TestProbeKarakeep tests the startup check of the Karakeep server
+func TestProbeKarakeep(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "version reported",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"version": "0.25.0"}`)
+			},
+		},
+		{
+			name: "version unknown",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			client := karakeep.NewClient("test-token")
+			client.SetBaseURL(server.URL + "/api/v1")
+			if err := probeKarakeep(context.Background(), client, server.URL+"/api/v1"); err != nil {
+				t.Errorf("probeKarakeep() error = %v, expected nil", err)
+			}
+		})
+	}
+
+	t.Run("server unreachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		baseURL := server.URL + "/api/v1"
+		server.Close()
+
+		client := karakeep.NewClient("test-token")
+		client.SetBaseURL(baseURL)
+		if err := probeKarakeep(context.Background(), client, baseURL); err == nil {
+			t.Error("Expected an error for an unreachable server")
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ashebanow/rainbridge/internal/config"
+	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+// How to get new tokens, shown when a token is missing or rejected.
+const (
+	raindropTokenHelp = "Create a test token for an app at https://app.raindrop.io/settings/integrations " +
+		"and set RAINDROP_API_TOKEN or pass --raindrop-token."
+	karakeepTokenHelp = "Create an API key in Karakeep under Settings > API Keys " +
+		"and set KARAKEEP_API_TOKEN or pass --karakeep-token."
+)
+
+// checkTokensPresent fails with a configuration error when a token the
+// command needs is not set.
+func checkTokensPresent(cfg *config.Config, needsRaindrop bool) error {
+	if needsRaindrop && cfg.RaindropToken == "" {
+		return configError(errors.New("no Raindrop.io token. " + raindropTokenHelp))
+	}
+	if cfg.KarakeepToken == "" {
+		return configError(errors.New("no Karakeep token. " + karakeepTokenHelp))
+	}
+	return nil
+}
+
+// preflight checks that both services are reachable and accept their tokens
+// before any work starts. A rejected token is a configuration error.
+func preflight(ctx context.Context, raindropClient *raindrop.Client, karakeepClient *karakeep.Client, cfg *config.Config, needsRaindrop bool) error {
+	if needsRaindrop {
+		user, err := raindropClient.GetUser(ctx)
+		if errors.Is(err, raindrop.ErrUnauthorized) {
+			return configError(fmt.Errorf("RAINDROP_API_TOKEN was rejected by Raindrop.io (%v). %s", err, raindropTokenHelp))
+		}
+		if err != nil {
+			return fmt.Errorf("cannot reach Raindrop.io at %s: %w", cfg.RaindropBaseURL, err)
+		}
+		log.Printf("Authenticated with Raindrop.io as %s", displayName(user.FullName, user.Email))
+	}
+
+	if err := probeKarakeep(ctx, karakeepClient, cfg.KarakeepBaseURL); err != nil {
+		return fmt.Errorf("cannot reach Karakeep at %s: %w", cfg.KarakeepBaseURL, err)
+	}
+	user, err := karakeepClient.GetCurrentUser(ctx)
+	if errors.Is(err, karakeep.ErrUnauthorized) {
+		return configError(fmt.Errorf("KARAKEEP_API_TOKEN was rejected by Karakeep at %s (%v). %s", cfg.KarakeepBaseURL, err, karakeepTokenHelp))
+	}
+	if err != nil {
+		return fmt.Errorf("cannot check Karakeep token at %s: %w", cfg.KarakeepBaseURL, err)
+	}
+	log.Printf("Authenticated with Karakeep as %s", displayName(user.Name, user.Email))
+
+	return nil
+}
+
+// rejectedToken returns a configuration error naming the token that stopped
+// working when err wraps raindrop.ErrUnauthorized or karakeep.ErrUnauthorized,
+// and nil otherwise. stopped says what happened to the command.
+func rejectedToken(err error, stopped string) error {
+	switch {
+	case errors.Is(err, raindrop.ErrUnauthorized):
+		return configError(fmt.Errorf("%s: RAINDROP_API_TOKEN was rejected by Raindrop.io (%v). %s", stopped, err, raindropTokenHelp))
+	case errors.Is(err, karakeep.ErrUnauthorized):
+		return configError(fmt.Errorf("%s: KARAKEEP_API_TOKEN was rejected by Karakeep (%v). %s", stopped, err, karakeepTokenHelp))
+	}
+	return nil
+}
+
+// probeKarakeep checks that the Karakeep server is reachable and reports its
+// version. A server that does not report a version is only warned about.
+func probeKarakeep(ctx context.Context, client *karakeep.Client, baseURL string) error {
+	version, err := client.ServerVersion(ctx)
+	if errors.Is(err, karakeep.ErrVersionUnknown) {
+		log.Printf("Warning: could not determine the Karakeep server version at %s: %v", baseURL, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Using Karakeep %s at %s", version, baseURL)
+	return nil
+}
+
+func displayName(name, email string) string {
+	switch {
+	case name != "" && email != "":
+		return fmt.Sprintf("%s <%s>", name, email)
+	case name != "":
+		return name
+	case email != "":
+		return email
+	default:
+		return "unknown user"
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information, set at link time with
+//
+//	go build -ldflags "-X main.version=1.2.3 -X main.commit=abc1234 -X main.date=2025-01-01T00:00:00Z"
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+// buildVersion returns the version set at link time. Binaries installed
+// with "go install" have no ldflags, so their module version is used instead.
+func buildVersion() string {
+	if version == "dev" {
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			return info.Main.Version
+		}
+	}
+	return version
+}
+
+// versionString describes the build.
+func versionString() string {
+	return fmt.Sprintf("rainbridge %s (commit %s, built %s, %s)", buildVersion(), commit, date, runtime.Version())
+}
+
+// userAgent is the User-Agent header sent to both APIs.
+func userAgent() string {
+	return "rainbridge/" + buildVersion()
+}
+
+func runVersion(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("version", "", stderr)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usageError("version takes no arguments")
+	}
+	fmt.Fprintln(stdout, versionString())
+	return nil
+}
@@ -1,15 +1,104 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
 	"github.com/joho/godotenv"
 )
 
+const (
+	// DefaultCheckpointPath is the ledger file used when RAINBRIDGE_CHECKPOINT_FILE is not set.
+	DefaultCheckpointPath = "rainbridge-checkpoint.json"
+	// DefaultFailureReportPath is the failure report written when
+	// RAINBRIDGE_FAILURE_REPORT is not set.
+	DefaultFailureReportPath = "rainbridge-failures.jsonl"
+	// DefaultDuplicatePolicy is used when RAINBRIDGE_LIST_POLICY or
+	// RAINBRIDGE_BOOKMARK_POLICY is not set.
+	DefaultDuplicatePolicy = "skip"
+	// DefaultDeletedPolicy is used when RAINBRIDGE_DELETED_POLICY is not set.
+	DefaultDeletedPolicy = "ignore"
+	// DefaultMovedPolicy is used when RAINBRIDGE_MOVED_POLICY is not set.
+	DefaultMovedPolicy = "ignore"
+	// DefaultUnsortedListName is the list Unsorted bookmarks are imported into
+	// when RAINBRIDGE_UNSORTED_LIST is not set.
+	DefaultUnsortedListName = "Unsorted"
+	// DefaultTrashListName is the list Trash bookmarks are imported into when
+	// RAINBRIDGE_TRASH_LIST is not set.
+	DefaultTrashListName = "Raindrop Trash"
+	// DefaultWorkers is the number of bookmarks imported concurrently when
+	// RAINBRIDGE_WORKERS is not set.
+	DefaultWorkers = 4
+	// DefaultRateLimit is the maximum number of requests per second sent to
+	// each service when RAINBRIDGE_RATE_LIMIT is not set.
+	DefaultRateLimit = 10.0
+	// DefaultMaxRetries is the number of times a failed request is retried
+	// when RAINBRIDGE_MAX_RETRIES is not set.
+	DefaultMaxRetries = 5
+)
+
 // Config holds the application configuration.
 type Config struct {
 	RaindropToken string
 	KarakeepToken string
+
+	// RaindropBaseURL and KarakeepBaseURL are the API endpoints to use. A
+	// self-hosted Karakeep server is typically at https://host/api/v1.
+	RaindropBaseURL string
+	KarakeepBaseURL string
+
+	// CheckpointPath is the ledger file used to resume interrupted imports.
+	CheckpointPath string
+
+	// FailureReportPath is the JSON Lines file listing the items that failed
+	// to import, which "import --retry-failed" reads back.
+	FailureReportPath string
+
+	// ListPolicy and BookmarkPolicy name the policy (skip, update or
+	// duplicate) for lists and bookmarks that already exist in Karakeep.
+	ListPolicy     string
+	BookmarkPolicy string
+
+	// DeletedPolicy (ignore, archive or delete) and MovedPolicy (ignore or
+	// move) name what sync does with bookmarks that were deleted or moved
+	// in Raindrop.io after they were imported.
+	DeletedPolicy string
+	MovedPolicy   string
+
+	// NestedCollections recreates the Raindrop collection hierarchy as
+	// nested Karakeep lists.
+	NestedCollections bool
+
+	// ImportUnsorted imports the Raindrop Unsorted collection into the list
+	// UnsortedListName, or into no list when UnsortedListName is empty.
+	ImportUnsorted   bool
+	UnsortedListName string
+
+	// ImportTrash imports the Raindrop Trash collection into the list TrashListName.
+	ImportTrash   bool
+	TrashListName string
+
+	// ArchiveCollections lists the collections, by title or ID, whose
+	// bookmarks are archived in Karakeep.
+	ArchiveCollections []string
+
+	// Workers is the number of bookmarks imported concurrently.
+	Workers int
+
+	// RateLimit is the maximum number of requests per second sent by all
+	// workers together to each of Raindrop.io and Karakeep, or 0 for no
+	// limit.
+	RateLimit float64
+
+	// MaxRetries is the number of times a request that was rate limited or
+	// failed with a timeout, a dropped connection or a 5xx gateway error is
+	// sent again, or 0 to never retry.
+	MaxRetries int
 }
 
 // Load loads the configuration from environment variables or a .env file.
@@ -18,9 +107,156 @@ func Load() (*Config, error) {
 	_ = godotenv.Load()
 
 	cfg := &Config{
-		RaindropToken: os.Getenv("RAINDROP_API_TOKEN"),
-		KarakeepToken: os.Getenv("KARAKEEP_API_TOKEN"),
+		RaindropToken:     os.Getenv("RAINDROP_API_TOKEN"),
+		KarakeepToken:     os.Getenv("KARAKEEP_API_TOKEN"),
+		CheckpointPath:    getEnv("RAINBRIDGE_CHECKPOINT_FILE", DefaultCheckpointPath),
+		FailureReportPath: getEnv("RAINBRIDGE_FAILURE_REPORT", DefaultFailureReportPath),
+		ListPolicy:        getEnv("RAINBRIDGE_LIST_POLICY", DefaultDuplicatePolicy),
+		BookmarkPolicy:    getEnv("RAINBRIDGE_BOOKMARK_POLICY", DefaultDuplicatePolicy),
+		DeletedPolicy:     getEnv("RAINBRIDGE_DELETED_POLICY", DefaultDeletedPolicy),
+		MovedPolicy:       getEnv("RAINBRIDGE_MOVED_POLICY", DefaultMovedPolicy),
+		RaindropBaseURL:   getEnv("RAINDROP_BASE_URL", raindrop.DefaultBaseURL),
+		KarakeepBaseURL:   getEnv("KARAKEEP_BASE_URL", karakeep.DefaultBaseURL),
+	}
+
+	var err error
+	if cfg.NestedCollections, err = getEnvBool("RAINBRIDGE_NESTED_COLLECTIONS", true); err != nil {
+		return nil, err
+	}
+
+	if cfg.ImportUnsorted, err = getEnvBool("RAINBRIDGE_IMPORT_UNSORTED", false); err != nil {
+		return nil, err
+	}
+	// An explicitly empty RAINBRIDGE_UNSORTED_LIST imports Unsorted without a list.
+	cfg.UnsortedListName = DefaultUnsortedListName
+	if value, ok := os.LookupEnv("RAINBRIDGE_UNSORTED_LIST"); ok {
+		cfg.UnsortedListName = value
+	}
+
+	if cfg.ImportTrash, err = getEnvBool("RAINBRIDGE_IMPORT_TRASH", false); err != nil {
+		return nil, err
+	}
+	cfg.TrashListName = getEnv("RAINBRIDGE_TRASH_LIST", DefaultTrashListName)
+	cfg.ArchiveCollections = getEnvList("RAINBRIDGE_ARCHIVE_COLLECTIONS")
+
+	if cfg.Workers, err = getEnvInt("RAINBRIDGE_WORKERS", DefaultWorkers); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit, err = getEnvFloat("RAINBRIDGE_RATE_LIMIT", DefaultRateLimit); err != nil {
+		return nil, err
+	}
+	if cfg.MaxRetries, err = getEnvInt("RAINBRIDGE_MAX_RETRIES", DefaultMaxRetries); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
 	return cfg, nil
 }
+
+// Validate checks the base URLs and normalizes them, so that it can be called
+// again after the configuration has been changed, e.g. by command line flags.
+// It does not require the API tokens to be set.
+func (c *Config) Validate() error {
+	var err error
+	if c.RaindropBaseURL, err = normalizeBaseURL("RAINDROP_BASE_URL", c.RaindropBaseURL, "/rest/v1"); err != nil {
+		return err
+	}
+	if c.KarakeepBaseURL, err = normalizeBaseURL("KARAKEEP_BASE_URL", c.KarakeepBaseURL, "/api/v1"); err != nil {
+		return err
+	}
+	if c.Workers < 1 {
+		return fmt.Errorf("invalid RAINBRIDGE_WORKERS %d: must be at least 1", c.Workers)
+	}
+	if c.RateLimit < 0 {
+		return fmt.Errorf("invalid RAINBRIDGE_RATE_LIMIT %g: must not be negative", c.RateLimit)
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("invalid RAINBRIDGE_MAX_RETRIES %d: must not be negative", c.MaxRetries)
+	}
+	return nil
+}
+
+// getEnv returns the value of the environment variable key, or fallback if it is unset or empty.
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvBool parses the environment variable key as a boolean, returning
+// fallback if it is unset or empty.
+func getEnvBool(key string, fallback bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: expected true or false", key, value)
+	}
+	return parsed, nil
+}
+
+// getEnvInt parses the environment variable key as an integer, returning
+// fallback if it is unset or empty.
+func getEnvInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: expected a whole number", key, value)
+	}
+	return parsed, nil
+}
+
+// getEnvFloat parses the environment variable key as a number, returning
+// fallback if it is unset or empty.
+func getEnvFloat(key string, fallback float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: expected a number", key, value)
+	}
+	return parsed, nil
+}
+
+// normalizeBaseURL validates value, the setting named key, as an http or
+// https URL. A URL without a path gets apiPath appended, so that a bare server
+// address can be given. Trailing slashes are removed.
+func normalizeBaseURL(key, value, apiPath string) (string, error) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid %s %q: expected an http or https URL", key, value)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid %s %q: must not have a query or fragment", key, value)
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	if u.Path == "" {
+		u.Path = apiPath
+	}
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// getEnvList splits the comma-separated environment variable key into its
+// non-empty, trimmed elements.
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
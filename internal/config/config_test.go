@@ -541,4 +541,340 @@ func BenchmarkLoadWithLargeTokens(b *testing.B) {
 			b.Fatal("Expected large tokens to be loaded")
 		}
 	}
-}
\ No newline at end of file
+}
+// TestLoadCheckpointPath tests the checkpoint file default and override
+func TestLoadCheckpointPath(t *testing.T) {
+	original := os.Getenv("RAINBRIDGE_CHECKPOINT_FILE")
+	defer os.Setenv("RAINBRIDGE_CHECKPOINT_FILE", original)
+
+	os.Unsetenv("RAINBRIDGE_CHECKPOINT_FILE")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if cfg.CheckpointPath != DefaultCheckpointPath {
+		t.Errorf("Load() CheckpointPath = %q, expected %q", cfg.CheckpointPath, DefaultCheckpointPath)
+	}
+
+	os.Setenv("RAINBRIDGE_CHECKPOINT_FILE", "/tmp/custom-checkpoint.json")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if cfg.CheckpointPath != "/tmp/custom-checkpoint.json" {
+		t.Errorf("Load() CheckpointPath = %q, expected %q", cfg.CheckpointPath, "/tmp/custom-checkpoint.json")
+	}
+}
+
+// TestLoadFailureReportPath tests the failure report default and override
+func TestLoadFailureReportPath(t *testing.T) {
+	t.Setenv("RAINBRIDGE_FAILURE_REPORT", "")
+	os.Unsetenv("RAINBRIDGE_FAILURE_REPORT")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if cfg.FailureReportPath != DefaultFailureReportPath {
+		t.Errorf("Load() FailureReportPath = %q, expected %q", cfg.FailureReportPath, DefaultFailureReportPath)
+	}
+
+	t.Setenv("RAINBRIDGE_FAILURE_REPORT", "/tmp/failures.jsonl")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if cfg.FailureReportPath != "/tmp/failures.jsonl" {
+		t.Errorf("Load() FailureReportPath = %q, expected %q", cfg.FailureReportPath, "/tmp/failures.jsonl")
+	}
+}
+
+// TestLoadDuplicatePolicies tests the duplicate policy defaults and overrides
+func TestLoadDuplicatePolicies(t *testing.T) {
+	originalList := os.Getenv("RAINBRIDGE_LIST_POLICY")
+	originalBookmark := os.Getenv("RAINBRIDGE_BOOKMARK_POLICY")
+	defer func() {
+		os.Setenv("RAINBRIDGE_LIST_POLICY", originalList)
+		os.Setenv("RAINBRIDGE_BOOKMARK_POLICY", originalBookmark)
+	}()
+
+	os.Unsetenv("RAINBRIDGE_LIST_POLICY")
+	os.Setenv("RAINBRIDGE_BOOKMARK_POLICY", "update")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if cfg.ListPolicy != DefaultDuplicatePolicy {
+		t.Errorf("Load() ListPolicy = %q, expected %q", cfg.ListPolicy, DefaultDuplicatePolicy)
+	}
+	if cfg.BookmarkPolicy != "update" {
+		t.Errorf("Load() BookmarkPolicy = %q, expected %q", cfg.BookmarkPolicy, "update")
+	}
+}
+
+// TestLoadSyncPolicies tests that sync leaves deleted and moved bookmarks alone by default
+func TestLoadSyncPolicies(t *testing.T) {
+	originalDeleted, deletedSet := os.LookupEnv("RAINBRIDGE_DELETED_POLICY")
+	originalMoved, movedSet := os.LookupEnv("RAINBRIDGE_MOVED_POLICY")
+	defer func() {
+		if deletedSet {
+			os.Setenv("RAINBRIDGE_DELETED_POLICY", originalDeleted)
+		} else {
+			os.Unsetenv("RAINBRIDGE_DELETED_POLICY")
+		}
+		if movedSet {
+			os.Setenv("RAINBRIDGE_MOVED_POLICY", originalMoved)
+		} else {
+			os.Unsetenv("RAINBRIDGE_MOVED_POLICY")
+		}
+	}()
+
+	os.Unsetenv("RAINBRIDGE_DELETED_POLICY")
+	os.Unsetenv("RAINBRIDGE_MOVED_POLICY")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if cfg.DeletedPolicy != "ignore" || cfg.MovedPolicy != "ignore" {
+		t.Errorf("Expected deleted and moved policies to default to ignore, got %q/%q",
+			cfg.DeletedPolicy, cfg.MovedPolicy)
+	}
+}
+
+// TestLoadNestedCollections tests parsing of RAINBRIDGE_NESTED_COLLECTIONS
+func TestLoadNestedCollections(t *testing.T) {
+	original := os.Getenv("RAINBRIDGE_NESTED_COLLECTIONS")
+	defer os.Setenv("RAINBRIDGE_NESTED_COLLECTIONS", original)
+
+	os.Unsetenv("RAINBRIDGE_NESTED_COLLECTIONS")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if !cfg.NestedCollections {
+		t.Error("Load() NestedCollections = false, expected true by default")
+	}
+
+	os.Setenv("RAINBRIDGE_NESTED_COLLECTIONS", "false")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if cfg.NestedCollections {
+		t.Error("Load() NestedCollections = true, expected false")
+	}
+
+	os.Setenv("RAINBRIDGE_NESTED_COLLECTIONS", "sometimes")
+	if _, err := Load(); err == nil {
+		t.Error("Load() error = nil, expected error for invalid boolean")
+	}
+}
+
+// TestLoadSystemCollections tests the Unsorted and Trash settings
+func TestLoadSystemCollections(t *testing.T) {
+	keys := []string{"RAINBRIDGE_IMPORT_UNSORTED", "RAINBRIDGE_UNSORTED_LIST", "RAINBRIDGE_IMPORT_TRASH", "RAINBRIDGE_TRASH_LIST"}
+	originals := make(map[string]string)
+	for _, key := range keys {
+		if value, ok := os.LookupEnv(key); ok {
+			originals[key] = value
+		}
+		os.Unsetenv(key)
+	}
+	defer func() {
+		for _, key := range keys {
+			if value, ok := originals[key]; ok {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+	}()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if cfg.ImportUnsorted || cfg.UnsortedListName != DefaultUnsortedListName {
+		t.Errorf("Expected Unsorted to be opt-in with list %q, got %v/%q",
+			DefaultUnsortedListName, cfg.ImportUnsorted, cfg.UnsortedListName)
+	}
+	if cfg.ImportTrash || cfg.TrashListName != DefaultTrashListName {
+		t.Errorf("Expected Trash to be opt-in with list %q, got %v/%q",
+			DefaultTrashListName, cfg.ImportTrash, cfg.TrashListName)
+	}
+
+	os.Setenv("RAINBRIDGE_IMPORT_UNSORTED", "true")
+	os.Setenv("RAINBRIDGE_UNSORTED_LIST", "")
+	os.Setenv("RAINBRIDGE_IMPORT_TRASH", "true")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if !cfg.ImportUnsorted {
+		t.Error("Expected RAINBRIDGE_IMPORT_UNSORTED=true to enable Unsorted import")
+	}
+	if cfg.UnsortedListName != "" {
+		t.Errorf("Expected empty RAINBRIDGE_UNSORTED_LIST to disable the list, got %q", cfg.UnsortedListName)
+	}
+	if !cfg.ImportTrash {
+		t.Error("Expected RAINBRIDGE_IMPORT_TRASH=true to enable Trash import")
+	}
+}
+
+// TestLoadArchiveCollections tests parsing RAINBRIDGE_ARCHIVE_COLLECTIONS
+func TestLoadArchiveCollections(t *testing.T) {
+	original, wasSet := os.LookupEnv("RAINBRIDGE_ARCHIVE_COLLECTIONS")
+	defer func() {
+		if wasSet {
+			os.Setenv("RAINBRIDGE_ARCHIVE_COLLECTIONS", original)
+		} else {
+			os.Unsetenv("RAINBRIDGE_ARCHIVE_COLLECTIONS")
+		}
+	}()
+
+	os.Unsetenv("RAINBRIDGE_ARCHIVE_COLLECTIONS")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if len(cfg.ArchiveCollections) != 0 {
+		t.Errorf("Expected no archive collections by default, got %v", cfg.ArchiveCollections)
+	}
+
+	os.Setenv("RAINBRIDGE_ARCHIVE_COLLECTIONS", " Old Stuff, ,12345 ,")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if len(cfg.ArchiveCollections) != 2 || cfg.ArchiveCollections[0] != "Old Stuff" || cfg.ArchiveCollections[1] != "12345" {
+		t.Errorf("Expected [Old Stuff 12345], got %q", cfg.ArchiveCollections)
+	}
+}
+
+// TestLoadBaseURLs tests RAINDROP_BASE_URL and KARAKEEP_BASE_URL
+func TestLoadBaseURLs(t *testing.T) {
+	keys := []string{"RAINDROP_BASE_URL", "KARAKEEP_BASE_URL"}
+	originals := make(map[string]string)
+	for _, key := range keys {
+		if value, ok := os.LookupEnv(key); ok {
+			originals[key] = value
+		}
+	}
+	defer func() {
+		for _, key := range keys {
+			if value, ok := originals[key]; ok {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+	}()
+
+	tests := []struct {
+		name             string
+		raindrop         string
+		karakeep         string
+		expectedRaindrop string
+		expectedKarakeep string
+		expectError      bool
+	}{
+		{
+			name:             "defaults",
+			expectedRaindrop: "https://api.raindrop.io/rest/v1",
+			expectedKarakeep: "https://api.karakeep.app/v1",
+		},
+		{
+			name:             "bare self-hosted server",
+			karakeep:         "http://karakeep.local:3000/",
+			expectedRaindrop: "https://api.raindrop.io/rest/v1",
+			expectedKarakeep: "http://karakeep.local:3000/api/v1",
+		},
+		{
+			name:             "explicit API paths",
+			raindrop:         "https://proxy.example.com/raindrop/rest/v1",
+			karakeep:         "https://example.com/karakeep/api/v1/",
+			expectedRaindrop: "https://proxy.example.com/raindrop/rest/v1",
+			expectedKarakeep: "https://example.com/karakeep/api/v1",
+		},
+		{name: "missing scheme", karakeep: "karakeep.local/api/v1", expectError: true},
+		{name: "unsupported scheme", raindrop: "ftp://example.com", expectError: true},
+		{name: "query string", karakeep: "https://example.com/api/v1?x=1", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("RAINDROP_BASE_URL", tt.raindrop)
+			os.Setenv("KARAKEEP_BASE_URL", tt.karakeep)
+
+			cfg, err := Load()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, got config %+v", cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v, expected nil", err)
+			}
+			if cfg.RaindropBaseURL != tt.expectedRaindrop {
+				t.Errorf("Expected RaindropBaseURL %q, got %q", tt.expectedRaindrop, cfg.RaindropBaseURL)
+			}
+			if cfg.KarakeepBaseURL != tt.expectedKarakeep {
+				t.Errorf("Expected KarakeepBaseURL %q, got %q", tt.expectedKarakeep, cfg.KarakeepBaseURL)
+			}
+		})
+	}
+}
+
+// TestLoadConcurrency tests RAINBRIDGE_WORKERS, RAINBRIDGE_RATE_LIMIT and
+// RAINBRIDGE_MAX_RETRIES
+func TestLoadConcurrency(t *testing.T) {
+	for _, key := range []string{"RAINBRIDGE_WORKERS", "RAINBRIDGE_RATE_LIMIT", "RAINBRIDGE_MAX_RETRIES"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if cfg.Workers != DefaultWorkers || cfg.RateLimit != DefaultRateLimit {
+		t.Errorf("Expected %d workers at %g requests per second by default, got %d at %g",
+			DefaultWorkers, DefaultRateLimit, cfg.Workers, cfg.RateLimit)
+	}
+	if cfg.MaxRetries != DefaultMaxRetries {
+		t.Errorf("Expected %d retries by default, got %d", DefaultMaxRetries, cfg.MaxRetries)
+	}
+
+	t.Setenv("RAINBRIDGE_WORKERS", "8")
+	t.Setenv("RAINBRIDGE_RATE_LIMIT", "2.5")
+	t.Setenv("RAINBRIDGE_MAX_RETRIES", "0")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+	if cfg.Workers != 8 || cfg.RateLimit != 2.5 {
+		t.Errorf("Expected 8 workers at 2.5 requests per second, got %d at %g", cfg.Workers, cfg.RateLimit)
+	}
+	if cfg.MaxRetries != 0 {
+		t.Errorf("Expected retries to be disabled, got %d", cfg.MaxRetries)
+	}
+
+	for key, value := range map[string]string{
+		"RAINBRIDGE_WORKERS":     "0",
+		"RAINBRIDGE_RATE_LIMIT":  "-1",
+		"RAINBRIDGE_MAX_RETRIES": "-1",
+	} {
+		t.Run(key+"="+value, func(t *testing.T) {
+			t.Setenv(key, value)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), key) {
+				t.Errorf("Load() error = %v, expected an error naming %s", err, key)
+			}
+		})
+	}
+	t.Setenv("RAINBRIDGE_WORKERS", "many")
+	if _, err := Load(); err == nil {
+		t.Error("Load() error = nil, expected error for a non-numeric worker count")
+	}
+}
@@ -0,0 +1,227 @@
+// Package httpx is the HTTP transport shared by the Raindrop.io and Karakeep
+// API clients. It authenticates requests, paces them with a rate limiter and
+// retries the ones that failed for reasons that are likely to pass.
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ashebanow/rainbridge/internal/ratelimit"
+	"github.com/ashebanow/rainbridge/internal/retry"
+)
+
+// DefaultTimeout limits how long a single request may take, including
+// reading the response body.
+const DefaultTimeout = time.Minute
+
+// DefaultUserAgent is sent when Client.UserAgent is empty.
+const DefaultUserAgent = "rainbridge"
+
+// Sleeper interface for dependency injection of sleep functionality
+type Sleeper interface {
+	Sleep(duration time.Duration)
+}
+
+// RealSleeper implements Sleeper using time.Sleep
+type RealSleeper struct{}
+
+func (r RealSleeper) Sleep(duration time.Duration) {
+	time.Sleep(duration)
+}
+
+// SleepContext sleeps for duration, or until ctx is done.
+func (r RealSleeper) SleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Client sends requests to one API. Its fields may be changed between
+// requests but not while requests are in flight. Every field is optional.
+type Client struct {
+	// HTTPClient sends the requests. When nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// Token is sent as a bearer token in the Authorization header.
+	Token string
+
+	// UserAgent is sent in the User-Agent header, DefaultUserAgent if empty.
+	UserAgent string
+
+	// Sleeper waits between retries. When nil, RealSleeper is used.
+	Sleeper Sleeper
+
+	// Limiter paces requests. It may be shared with other clients.
+	Limiter *ratelimit.Limiter
+
+	// Retry decides which failed requests are sent again. When nil,
+	// retry.RateLimitOnly is used.
+	Retry *retry.Policy
+
+	// OnRequest is called before every attempt to send a request, and
+	// OnResponse with every response received, including the ones that are
+	// retried.
+	OnRequest  func(*http.Request)
+	OnResponse func(*http.Response)
+}
+
+// New returns a Client that authenticates with token, times out after
+// DefaultTimeout and only slows down when the server reports that its quota
+// is running out.
+func New(token string) *Client {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		Token:      token,
+		Sleeper:    RealSleeper{},
+		Limiter:    ratelimit.New(0, 0),
+	}
+}
+
+// NewRequest creates a request for ctx. A non-nil body is sent as JSON.
+func (c *Client) NewRequest(ctx context.Context, method, url string, body any) (*http.Request, error) {
+	if body == nil {
+		return http.NewRequestWithContext(ctx, method, url, nil)
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// Do sends an authenticated request, waiting for the rate limiter first.
+// Rate limited requests are retried after the delay the server asks for, or
+// with exponential backoff when it does not say. When the retry policy
+// allows it, timeouts, dropped connections and 500, 502, 503 and 504
+// responses are retried the same way, except that POST and PATCH requests
+// are only sent again after a 503, or after a connection failure when the
+// server cannot have seen them. The request body is rebuilt for every attempt, so that
+// POST and PUT requests are sent again in full. Waiting stops as soon as the
+// request's context is done.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	policy := retry.RateLimitOnly
+	if c.Retry != nil {
+		policy = *c.Retry
+	}
+	maxRetries := policy.MaxRetries
+	ctx := req.Context()
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	userAgent := c.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	var wait time.Duration
+	for attempt := 0; ; attempt++ {
+		if reserved := c.Limiter.Reserve(); reserved > wait {
+			wait = reserved
+		}
+		if wait > 0 {
+			if err := c.sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+		}
+		if attempt > 0 {
+			if err := retry.Rewind(req); err != nil {
+				return nil, err
+			}
+		}
+
+		if c.OnRequest != nil {
+			c.OnRequest(req)
+		}
+		resp, err := c.httpClient().Do(req)
+		if err != nil {
+			if attempt == maxRetries || ctx.Err() != nil || !policy.ShouldRetryError(req.Method, err) {
+				return nil, err
+			}
+			wait = policy.Backoff(attempt)
+			log.Printf("Request failed (%v), retrying in %v (attempt %d/%d)", err, wait, attempt+1, maxRetries)
+			continue
+		}
+		if c.OnResponse != nil {
+			c.OnResponse(resp)
+		}
+		serverDelay, serverSaid := c.Limiter.Observe(resp)
+
+		rateLimited := resp.StatusCode == http.StatusTooManyRequests
+		if !rateLimited && !policy.ShouldRetryStatus(req.Method, resp.StatusCode) {
+			return resp, nil
+		}
+
+		// If this was the last attempt, a server error is returned to the
+		// caller like any other status, but rate limiting is an error.
+		if attempt == maxRetries {
+			if !rateLimited {
+				return resp, nil
+			}
+			drain(resp)
+			return nil, &RateLimitError{Retries: maxRetries, Status: resp.Status}
+		}
+
+		// Close the response body since we're retrying
+		drain(resp)
+
+		reason := "Rate limited (429)"
+		if !rateLimited {
+			reason = "Server error (" + resp.Status + ")"
+		}
+		if serverSaid {
+			wait = serverDelay
+			log.Printf("%s, retrying in %v as asked by the server (attempt %d/%d)", reason, wait, attempt+1, maxRetries)
+			continue
+		}
+
+		wait = policy.Backoff(attempt)
+		log.Printf("%s, retrying in %v (attempt %d/%d)", reason, wait, attempt+1, maxRetries)
+	}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// sleep waits for d before a retry. It returns early with an error when ctx
+// is done, or checks ctx afterwards if the sleeper cannot be interrupted.
+func (c *Client) sleep(ctx context.Context, d time.Duration) error {
+	var sleeper Sleeper = RealSleeper{}
+	if c.Sleeper != nil {
+		sleeper = c.Sleeper
+	}
+	if s, ok := sleeper.(interface {
+		SleepContext(context.Context, time.Duration) error
+	}); ok {
+		return s.SleepContext(ctx, d)
+	}
+	sleeper.Sleep(d)
+	return ctx.Err()
+}
+
+// drain reads a little of a response that is thrown away, so that the
+// connection can be reused, and closes it.
+func drain(resp *http.Response) {
+	io.CopyN(io.Discard, resp.Body, 4<<10)
+	resp.Body.Close()
+}
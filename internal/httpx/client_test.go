@@ -0,0 +1,203 @@
+//go:build !integration
+
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ashebanow/rainbridge/internal/retry"
+)
+
+type recordingSleeper struct {
+	sleeps []time.Duration
+}
+
+func (s *recordingSleeper) Sleep(d time.Duration) { s.sleeps = append(s.sleeps, d) }
+
+func TestDoSetsHeadersAndCallsHooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Expected the bearer token, got %q", auth)
+		}
+		if ua := r.Header.Get("User-Agent"); ua != "rainbridge/1.2.3" {
+			t.Errorf("Expected the user agent, got %q", ua)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected a JSON content type, got %q", ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"name":"Reading"}` {
+			t.Errorf("Unexpected body %s", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	var requests, responses int
+	client := New("secret")
+	client.UserAgent = "rainbridge/1.2.3"
+	client.OnRequest = func(*http.Request) { requests++ }
+	client.OnResponse = func(resp *http.Response) {
+		if resp.StatusCode == http.StatusCreated {
+			responses++
+		}
+	}
+
+	req, err := client.NewRequest(context.Background(), "POST", server.URL, map[string]string{"name": "Reading"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if requests != 1 || responses != 1 {
+		t.Errorf("Expected each hook to be called once, got %d and %d", requests, responses)
+	}
+}
+
+func TestDoRetriesWithPolicy(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sleeper := &recordingSleeper{}
+	client := &Client{Sleeper: sleeper}
+
+	// Without a policy, only rate limiting is retried.
+	req, _ := client.NewRequest(context.Background(), "GET", server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+	if attempts != 1 {
+		t.Errorf("Expected 1 attempt without a policy, got %d", attempts)
+	}
+
+	attempts = 0
+	client.Retry = &retry.Policy{MaxRetries: 3, BaseDelay: time.Second, Transient: true}
+	req, _ = client.NewRequest(context.Background(), "GET", server.URL, nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+	if attempts != 4 || len(sleeper.sleeps) != 3 {
+		t.Errorf("Expected 4 attempts and 3 sleeps, got %d and %v", attempts, sleeper.sleeps)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected the last response to be returned, got %s", resp.Status)
+	}
+}
+
+func TestDoDoesNotResendPostsAfterServerErrors(t *testing.T) {
+	var attempts atomic.Int32
+	status := http.StatusBadGateway
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := &Client{Sleeper: &recordingSleeper{}, Retry: &retry.Policy{MaxRetries: 2, BaseDelay: time.Second, Transient: true}}
+	req, _ := client.NewRequest(context.Background(), "POST", server.URL, map[string]string{"name": "Reading"})
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+	if attempts.Load() != 1 || resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected a single attempt returning 502, got %d attempts and %s", attempts.Load(), resp.Status)
+	}
+
+	// A 503 means the request was not handled, so it is sent again.
+	attempts.Store(0)
+	status = http.StatusServiceUnavailable
+	req, _ = client.NewRequest(context.Background(), "POST", server.URL, map[string]string{"name": "Reading"})
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+	if attempts.Load() != 3 {
+		t.Errorf("Expected 3 attempts after 503, got %d", attempts.Load())
+	}
+}
+
+func TestDoDoesNotResendHandledPosts(t *testing.T) {
+	// The server handles every request, then drops the connection before
+	// answering.
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts[r.Method]++
+		mu.Unlock()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	client := &Client{Sleeper: &recordingSleeper{}, Retry: &retry.Policy{MaxRetries: 2, BaseDelay: time.Second, Transient: true}}
+	for _, method := range []string{"GET", "POST", "PATCH"} {
+		req, _ := client.NewRequest(context.Background(), method, server.URL, map[string]string{"name": "Reading"})
+		if _, err := client.Do(req); err == nil {
+			t.Errorf("Expected %s to fail", method)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts["GET"] != 3 || attempts["POST"] != 1 || attempts["PATCH"] != 1 {
+		t.Errorf("Expected only GET to be retried, got %v", attempts)
+	}
+}
+
+func TestDoReportsRateLimiting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := &Client{Sleeper: &recordingSleeper{}, Retry: &retry.Policy{MaxRetries: 2, BaseDelay: time.Second}}
+	req, _ := client.NewRequest(context.Background(), "GET", server.URL, nil)
+	_, err := client.Do(req)
+
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) || rateLimitErr.Retries != 2 {
+		t.Fatalf("Expected a RateLimitError after 2 retries, got %v", err)
+	}
+	if err.Error() != "rate limited after 2 retries: 429 Too Many Requests" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	err := NewAPIError("get collections", &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"})
+	if err.Error() != "failed to get collections: 502 Bad Gateway" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected an APIError with status 502, got %v", err)
+	}
+}
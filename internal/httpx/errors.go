@@ -0,0 +1,160 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ashebanow/rainbridge/internal/ratelimit"
+	"github.com/ashebanow/rainbridge/internal/retry"
+)
+
+// MaxBodyLength is the longest response body kept in an APIError, in
+// characters. Longer bodies are truncated.
+const MaxBodyLength = 300
+
+// APIError is returned when an API answers with an unexpected HTTP status.
+type APIError struct {
+	// Op describes the request that failed, such as "get collections".
+	Op         string
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	// RetryAfter is the delay asked for by the Retry-After header, or 0.
+	RetryAfter time.Duration
+	// Body is the explanation the server gave, decoded from its JSON error
+	// message when it sent one, and truncated to MaxBodyLength.
+	Body string
+	// Err is a sentinel error that explains the status, if any.
+	Err error
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("failed to %s: %s: %v", e.Op, e.Status, e.Err)
+	}
+	return fmt.Sprintf("failed to %s: %s", e.Op, e.Status)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// NewAPIError returns the error for an unexpected response to the request
+// described by op. It reads what is left of the response body, but does not
+// close it.
+func NewAPIError(op string, resp *http.Response) *APIError {
+	err := &APIError{Op: op, StatusCode: resp.StatusCode, Status: resp.Status}
+	if resp.Request != nil {
+		err.Method = resp.Request.Method
+		err.Path = resp.Request.URL.Path
+	}
+	if delay, ok := ratelimit.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		err.RetryAfter = delay
+	}
+	if resp.Body != nil {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		err.Body = decodeBody(body)
+	}
+	return err
+}
+
+// decodeBody returns the message of a JSON error body, or the body itself
+// with its whitespace collapsed, truncated to MaxBodyLength.
+func decodeBody(body []byte) string {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return ""
+	}
+
+	message := strings.Join(strings.Fields(string(body)), " ")
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) == nil {
+		// Raindrop.io sends errorMessage, Karakeep sends message and both
+		// may send a short error code.
+		for _, key := range []string{"message", "errorMessage", "error"} {
+			var text string
+			if json.Unmarshal(fields[key], &text) == nil && text != "" {
+				message = text
+				break
+			}
+		}
+	}
+
+	if utf8.RuneCountInString(message) > MaxBodyLength {
+		message = string([]rune(message)[:MaxBodyLength]) + "…"
+	}
+	return message
+}
+
+// RateLimitError is returned by Client.Do when a request is still rate
+// limited after the last retry.
+type RateLimitError struct {
+	Retries int
+	Status  string
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited after %d retries: %s", e.Retries, e.Status)
+}
+
+// Kind classifies an error by what the caller can do about it.
+type Kind string
+
+const (
+	// KindOther is any error that is not classified.
+	KindOther Kind = ""
+	// KindAuth means the token was rejected. Nothing else will work either.
+	KindAuth Kind = "auth"
+	// KindValidation means the request was refused as invalid. Sending it
+	// again will fail the same way.
+	KindValidation Kind = "validation"
+	// KindNotFound means the item the request refers to does not exist.
+	KindNotFound Kind = "not_found"
+	// KindConflict means the request conflicts with an item that exists.
+	KindConflict Kind = "conflict"
+	// KindTransient means the request may succeed if it is sent later.
+	KindTransient Kind = "transient"
+)
+
+// Classify returns the kind of err.
+func Classify(err error) Kind {
+	if err == nil {
+		return KindOther
+	}
+
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return KindTransient
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.StatusCode; {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return KindAuth
+		case code == http.StatusNotFound || code == http.StatusGone:
+			return KindNotFound
+		case code == http.StatusConflict:
+			return KindConflict
+		case code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || retry.IsTransientStatus(code):
+			return KindTransient
+		case code == http.StatusBadRequest || code == http.StatusRequestEntityTooLarge ||
+			code == http.StatusRequestURITooLong || code == http.StatusUnprocessableEntity:
+			return KindValidation
+		}
+		return KindOther
+	}
+
+	if retry.IsTransientError(err) {
+		return KindTransient
+	}
+	return KindOther
+}
@@ -0,0 +1,108 @@
+//go:build !integration
+
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		expectedBody string
+	}{
+		{"Karakeep message", `{"code": "BAD_REQUEST", "message": "Invalid tag"}`, "Invalid tag"},
+		{"Raindrop.io message", `{"result": false, "error": "notFound", "errorMessage": "Collection not found"}`, "Collection not found"},
+		{"Error code only", `{"error": "forbidden"}`, "forbidden"},
+		{"Unknown JSON", `{"ok": false}`, `{"ok": false}`},
+		{"Plain text", "upstream\n  timed out\n", "upstream timed out"},
+		{"Empty", "", ""},
+		{"Long", strings.Repeat("x", MaxBodyLength+10), strings.Repeat("x", MaxBodyLength) + "…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "7")
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			resp, err := http.Post(server.URL+"/v1/bookmarks?x=1", "application/json", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			apiErr := NewAPIError("create bookmark", resp)
+			if apiErr.Method != "POST" || apiErr.Path != "/v1/bookmarks" || apiErr.StatusCode != http.StatusBadRequest {
+				t.Errorf("Unexpected request details: %+v", apiErr)
+			}
+			if apiErr.RetryAfter != 7*time.Second {
+				t.Errorf("Expected a Retry-After of 7s, got %v", apiErr.RetryAfter)
+			}
+			if apiErr.Body != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, apiErr.Body)
+			}
+			if apiErr.Error() != "failed to create bookmark: 400 Bad Request" {
+				t.Errorf("Unexpected message %q", apiErr.Error())
+			}
+		})
+	}
+}
+
+func TestAPIErrorUnwrapsSentinel(t *testing.T) {
+	sentinel := errors.New("token rejected")
+	apiErr := NewAPIError("get user", &http.Response{StatusCode: http.StatusUnauthorized, Status: "401 Unauthorized", Body: io.NopCloser(strings.NewReader(""))})
+	apiErr.Err = sentinel
+
+	err := fmt.Errorf("preflight: %w", apiErr)
+	if !errors.Is(err, sentinel) {
+		t.Errorf("Expected the sentinel to be found in %v", err)
+	}
+	if apiErr.Error() != "failed to get user: 401 Unauthorized: token rejected" {
+		t.Errorf("Unexpected message %q", apiErr.Error())
+	}
+}
+
+func TestClassify(t *testing.T) {
+	status := func(code int) error {
+		return fmt.Errorf("wrapped: %w", &APIError{Op: "test", StatusCode: code, Status: http.StatusText(code)})
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected Kind
+	}{
+		{"Nil", nil, KindOther},
+		{"Unauthorized", status(http.StatusUnauthorized), KindAuth},
+		{"Forbidden", status(http.StatusForbidden), KindAuth},
+		{"Bad request", status(http.StatusBadRequest), KindValidation},
+		{"Unprocessable", status(http.StatusUnprocessableEntity), KindValidation},
+		{"Not found", status(http.StatusNotFound), KindNotFound},
+		{"Conflict", status(http.StatusConflict), KindConflict},
+		{"Server error", status(http.StatusBadGateway), KindTransient},
+		{"Teapot", status(http.StatusTeapot), KindOther},
+		{"Rate limited", &RateLimitError{Retries: 5, Status: "429 Too Many Requests"}, KindTransient},
+		{"Unexpected EOF", io.ErrUnexpectedEOF, KindTransient},
+		{"Other", errors.New("boom"), KindOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.expected {
+				t.Errorf("Classify(%v) = %q, expected %q", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
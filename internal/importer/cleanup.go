@@ -0,0 +1,113 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/ashebanow/rainbridge/internal/ledger"
+)
+
+// CleanupResult summarizes what Cleanup removed.
+type CleanupResult struct {
+	BookmarksDeleted int
+	ListsDeleted     int
+	// Kept counts the pre-existing lists and bookmarks that were forgotten
+	// but left in Karakeep.
+	Kept   int
+	Failed int
+}
+
+// CleanupCounts reports how many lists and bookmarks Cleanup would delete.
+// Lists and bookmarks that already existed in Karakeep are never deleted.
+func (i *Importer) CleanupCounts() (lists, bookmarks int) {
+	if i.Ledger == nil {
+		return 0, 0
+	}
+	for _, rec := range i.Ledger.Lists() {
+		if !rec.Existing {
+			lists++
+		}
+	}
+	for _, rec := range i.Ledger.Bookmarks() {
+		if !rec.Existing {
+			bookmarks++
+		}
+	}
+	return lists, bookmarks
+}
+
+// Cleanup deletes the lists and bookmarks that were created by previous
+// imports, as recorded in the ledger, and removes them from the ledger.
+// Pre-existing lists and bookmarks that an import reused are left in
+// Karakeep and only removed from the ledger. Items that fail to delete stay
+// in the ledger so that Cleanup can be retried. When ctx is cancelled, or
+// Karakeep rejects the API key, Cleanup stops after the current deletion and
+// saves the ledger.
+func (i *Importer) Cleanup(ctx context.Context) (*CleanupResult, error) {
+	ctx, done := i.begin(ctx)
+	defer done()
+	if i.Ledger == nil {
+		i.Ledger = ledger.New()
+	}
+	result := &CleanupResult{}
+
+	bookmarks := i.Ledger.Bookmarks()
+	for _, raindropID := range sortedIDs(bookmarks) {
+		if ctx.Err() != nil {
+			return result, i.interrupted(ctx)
+		}
+		rec := bookmarks[raindropID]
+		if !rec.Existing {
+			if err := i.KarakeepClient.DeleteBookmark(context.WithoutCancel(ctx), rec.KarakeepID); err != nil {
+				i.stopOnAuthFailure(err)
+				log.Printf("Failed to delete bookmark %s: %v", rec.KarakeepID, err)
+				result.Failed++
+				continue
+			}
+			result.BookmarksDeleted++
+		} else {
+			result.Kept++
+		}
+		i.Ledger.ForgetBookmark(raindropID)
+	}
+
+	// Lists may reference bookmarks that failed to delete, but deleting a
+	// list leaves its bookmarks alone, so lists are always attempted.
+	lists := i.Ledger.Lists()
+	for _, collectionID := range sortedIDs(lists) {
+		if ctx.Err() != nil {
+			return result, i.interrupted(ctx)
+		}
+		rec := lists[collectionID]
+		if !rec.Existing {
+			if err := i.KarakeepClient.DeleteList(context.WithoutCancel(ctx), rec.KarakeepID); err != nil {
+				i.stopOnAuthFailure(err)
+				log.Printf("Failed to delete list %s: %v", rec.KarakeepID, err)
+				result.Failed++
+				continue
+			}
+			result.ListsDeleted++
+		} else {
+			result.Kept++
+		}
+		i.Ledger.ForgetList(collectionID)
+	}
+
+	if err := i.Ledger.Save(); err != nil {
+		return result, fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return result, nil
+}
+
+// sortedIDs returns the keys of a ledger snapshot in ascending order, so
+// that cleanup runs in a predictable order.
+func sortedIDs[T any](records map[int64]T) []int64 {
+	ids := make([]int64, 0, len(records))
+	for id := range records {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+	return ids
+}
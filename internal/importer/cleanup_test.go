@@ -0,0 +1,57 @@
+//go:build !integration
+
+package importer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ashebanow/rainbridge/internal/ledger"
+)
+
+func TestCleanupKeepsFailedAndExistingItems(t *testing.T) {
+	importer := newTestImporter(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("Unexpected request to Raindrop: %s", r.URL.Path)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "DELETE" {
+				t.Errorf("Unexpected request to Karakeep: %s %s", r.Method, r.URL.Path)
+			}
+			if r.URL.Path == "/v1/bookmarks/bookmark-broken" {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprintln(w, `{}`)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+	importer.Ledger = ledger.New()
+	importer.Ledger.RecordList(1, "list-1")
+	importer.Ledger.RecordExistingList(2, "list-2")
+	importer.Ledger.RecordBookmark(101, 1, "bookmark-101")
+	importer.Ledger.RecordBookmark(102, 1, "bookmark-broken")
+	importer.Ledger.RecordExistingBookmark(103, 2, "bookmark-103")
+
+	if lists, bookmarks := importer.CleanupCounts(); lists != 1 || bookmarks != 2 {
+		t.Errorf("Expected 1 list and 2 bookmarks to clean up, got %d and %d", lists, bookmarks)
+	}
+
+	result, err := importer.Cleanup(context.Background())
+	if err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+
+	expected := CleanupResult{BookmarksDeleted: 1, ListsDeleted: 1, Kept: 2, Failed: 1}
+	if *result != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *result)
+	}
+	if _, ok := importer.Ledger.Bookmark(102); !ok {
+		t.Error("Expected the bookmark that failed to delete to stay in the ledger")
+	}
+	if lists, bookmarks := importer.Ledger.Counts(); lists != 0 || bookmarks != 1 {
+		t.Errorf("Expected only the failed bookmark to remain, got %d lists and %d bookmarks", lists, bookmarks)
+	}
+}
@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+// fetchCollections fetches the collections to import, ordered so that every
+// collection comes after its parent, followed by the enabled system collections.
+func (i *Importer) fetchCollections(ctx context.Context) ([]raindrop.Collection, error) {
+	var collections []raindrop.Collection
+	var err error
+	if i.NestedCollections {
+		collections, err = i.RaindropClient.GetAllCollections(ctx)
+		collections = orderCollections(collections)
+	} else {
+		collections, err = i.RaindropClient.GetCollections(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if i.ImportUnsorted {
+		collections = append(collections, raindrop.Collection{ID: raindrop.UnsortedCollectionID, Title: i.UnsortedListName})
+	}
+	if i.ImportTrash {
+		collections = append(collections, raindrop.Collection{ID: raindrop.TrashCollectionID, Title: i.TrashListName})
+	}
+
+	return collections, nil
+}
+
+// hasList reports whether bookmarks of the collection are added to a list.
+// Only Unsorted can be imported without one.
+func (i *Importer) hasList(collection raindrop.Collection) bool {
+	return collection.ID != raindrop.UnsortedCollectionID || i.UnsortedListName != ""
+}
+
+// archived reports whether bookmarks of the collection are archived in Karakeep.
+func (i *Importer) archived(collection raindrop.Collection) bool {
+	if collection.ID == raindrop.TrashCollectionID {
+		return true
+	}
+	for _, name := range i.ArchiveCollections {
+		if strings.EqualFold(name, collection.Title) || name == strconv.FormatInt(collection.ID, 10) {
+			return true
+		}
+	}
+	return false
+}
+
+// orderCollections sorts collections parents-first, keeping the original
+// order among siblings. Collections whose parent is unknown are treated as
+// root collections.
+func orderCollections(collections []raindrop.Collection) []raindrop.Collection {
+	known := make(map[int64]bool, len(collections))
+	for _, collection := range collections {
+		known[collection.ID] = true
+	}
+
+	children := make(map[int64][]raindrop.Collection)
+	var roots []raindrop.Collection
+	for _, collection := range collections {
+		if parentID := collection.ParentID(); parentID != 0 && known[parentID] {
+			children[parentID] = append(children[parentID], collection)
+		} else {
+			collection.Parent = nil
+			roots = append(roots, collection)
+		}
+	}
+
+	ordered := make([]raindrop.Collection, 0, len(collections))
+	queue := roots
+	for len(queue) > 0 {
+		collection := queue[0]
+		queue = queue[1:]
+		ordered = append(ordered, collection)
+		queue = append(queue, children[collection.ID]...)
+	}
+
+	return ordered
+}
+
+// parentListID returns the Karakeep list the collection's list should be
+// nested under, or "" for a top-level list. It reports false when the list
+// should be nested but the parent collection has no list yet.
+func (i *Importer) parentListID(collection raindrop.Collection) (string, bool) {
+	if !i.NestedCollections || collection.ParentID() == 0 {
+		return "", true
+	}
+	return i.Ledger.ListID(collection.ParentID())
+}
+
+// listKey identifies a list by its parent and name, since nested lists with
+// the same name can exist under different parents.
+func listKey(parentID, name string) string {
+	return fmt.Sprintf("%s/%s", parentID, name)
+}
@@ -0,0 +1,208 @@
+//go:build !integration
+
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+func TestOrderCollections(t *testing.T) {
+	collections := []raindrop.Collection{
+		{ID: 3, Title: "Grandchild", Parent: &raindrop.CollectionRef{ID: 2}},
+		{ID: 1, Title: "Root"},
+		{ID: 4, Title: "Orphan", Parent: &raindrop.CollectionRef{ID: 99}},
+		{ID: 2, Title: "Child", Parent: &raindrop.CollectionRef{ID: 1}},
+		{ID: 5, Title: "Second Child", Parent: &raindrop.CollectionRef{ID: 1}},
+	}
+
+	var titles []string
+	for _, collection := range orderCollections(collections) {
+		titles = append(titles, collection.Title)
+	}
+
+	expected := "Root,Orphan,Child,Second Child,Grandchild"
+	if strings.Join(titles, ",") != expected {
+		t.Errorf("Expected order %s, got %s", expected, strings.Join(titles, ","))
+	}
+}
+
+func TestRunImportWithNestedCollections(t *testing.T) {
+	var createdLists []karakeep.List
+	importer := newTestImporter(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/rest/v1/collections":
+				fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Work"}]}`)
+			case "/rest/v1/collections/childrens":
+				fmt.Fprintln(w, `{"items": [
+					{"_id": 3, "title": "Go", "parent": {"$id": 2}},
+					{"_id": 2, "title": "Programming", "parent": {"$id": 1}}
+				]}`)
+			default:
+				fmt.Fprintln(w, `{"items": []}`)
+			}
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/lists" {
+				t.Errorf("Unexpected request to Karakeep: %s %s", r.Method, r.URL.Path)
+				return
+			}
+			var list karakeep.List
+			json.NewDecoder(r.Body).Decode(&list)
+			createdLists = append(createdLists, list)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": "list-%d", "name": %q}`, len(createdLists), list.Name)
+		},
+	)
+	importer.NestedCollections = true
+
+	if _, err := importer.RunImport(context.Background()); err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+
+	if len(createdLists) != 3 {
+		t.Fatalf("Expected 3 lists, got %d", len(createdLists))
+	}
+
+	expected := []karakeep.List{
+		{Name: "Work"},
+		{Name: "Programming", ParentID: "list-1"},
+		{Name: "Go", ParentID: "list-2"},
+	}
+	for n, list := range expected {
+		if createdLists[n] != list {
+			t.Errorf("List %d: expected %+v, got %+v", n, list, createdLists[n])
+		}
+	}
+}
+
+func TestNestedListsWithoutParentList(t *testing.T) {
+	// Karakeep has a top-level "Articles" list, which is not the list of the
+	// nested collection with the same name.
+	var createdLists []karakeep.List
+	karakeepHandler := func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v1/lists":
+			fmt.Fprintln(w, `[{"id": "top-articles", "name": "Articles"}]`)
+		case r.Method == "POST" && r.URL.Path == "/v1/lists":
+			var list karakeep.List
+			json.NewDecoder(r.Body).Decode(&list)
+			createdLists = append(createdLists, list)
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			t.Errorf("Unexpected request to Karakeep: %s %s", r.Method, r.URL.Path)
+		}
+	}
+	raindropHandler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}]}`)
+		case "/rest/v1/collections/childrens":
+			fmt.Fprintln(w, `{"items": [{"_id": 2, "title": "Articles", "parent": {"$id": 1}}]}`)
+		default:
+			fmt.Fprintln(w, `{"items": []}`)
+		}
+	}
+
+	importer := newTestImporter(t, raindropHandler, karakeepHandler)
+	importer.NestedCollections = true
+	importer.ListPolicy = PolicySkip
+
+	plan, err := importer.BuildPlan(context.Background())
+	if err != nil {
+		t.Fatalf("BuildPlan failed: %v", err)
+	}
+	if len(plan.Lists) != 2 || plan.Lists[1].Action != ActionCreate || plan.Counts.ListsToReuse != 0 {
+		t.Errorf("Expected the nested list to be created, got %+v", plan.Lists)
+	}
+
+	// When the parent list cannot be created, the nested one fails too
+	// instead of being created at the top level.
+	result, err := importer.RunImport(context.Background())
+	if err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+	if len(createdLists) != 1 || createdLists[0].Name != "Reading" {
+		t.Errorf("Expected only the parent list to be created, got %+v", createdLists)
+	}
+	if result.ListsFailed != 2 || result.ListsReused != 0 {
+		t.Errorf("Expected both lists to fail, got %+v", result)
+	}
+	if _, ok := importer.Ledger.ListID(2); ok {
+		t.Error("Expected no list to be recorded for the nested collection")
+	}
+}
+
+func TestRunImportWithSystemCollections(t *testing.T) {
+	var createdLists []string
+	var memberships []string
+	bookmarkCreations := 0
+	importer := newTestImporter(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			page := r.URL.Query().Get("page")
+			switch {
+			case r.URL.Path == "/rest/v1/collections":
+				fmt.Fprintln(w, `{"items": []}`)
+			case r.URL.Path == "/rest/v1/raindrops/-1" && page == "0":
+				fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Loose", "link": "https://example.com/loose"}]}`)
+			case r.URL.Path == "/rest/v1/raindrops/-99" && page == "0":
+				fmt.Fprintln(w, `{"items": [{"_id": 2, "title": "Deleted", "link": "https://example.com/deleted"}]}`)
+			default:
+				fmt.Fprintln(w, `{"items": []}`)
+			}
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/v1/lists":
+				var list karakeep.List
+				json.NewDecoder(r.Body).Decode(&list)
+				createdLists = append(createdLists, list.Name)
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintln(w, `{"id": "trash-list", "name": "Raindrop Trash"}`)
+			case r.URL.Path == "/v1/bookmarks":
+				bookmarkCreations++
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, `{"id": "bookmark-%d"}`, bookmarkCreations)
+			case strings.HasPrefix(r.URL.Path, "/v1/lists/"):
+				memberships = append(memberships, r.URL.Path)
+				fmt.Fprintln(w, `{}`)
+			default:
+				fmt.Fprintln(w, `{}`)
+			}
+		},
+	)
+	importer.ImportUnsorted = true
+	importer.ImportTrash = true
+	importer.TrashListName = "Raindrop Trash"
+
+	if _, err := importer.RunImport(context.Background()); err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+
+	if strings.Join(createdLists, ",") != "Raindrop Trash" {
+		t.Errorf("Expected only the trash list to be created, got %v", createdLists)
+	}
+	if bookmarkCreations != 2 {
+		t.Errorf("Expected 2 bookmarks, got %d", bookmarkCreations)
+	}
+	if strings.Join(memberships, ",") != "/v1/lists/trash-list/bookmarks/bookmark-2" {
+		t.Errorf("Expected only the trash bookmark to be added to a list, got %v", memberships)
+	}
+
+	// A second run must not try to add the listless Unsorted bookmark to a list.
+	memberships = nil
+	if _, err := importer.RunImport(context.Background()); err != nil {
+		t.Fatalf("Second RunImport failed: %v", err)
+	}
+	if bookmarkCreations != 2 || len(memberships) != 0 {
+		t.Errorf("Expected second run to do nothing, got %d creations and memberships %v", bookmarkCreations, memberships)
+	}
+}
@@ -0,0 +1,158 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// DuplicatePolicy controls what the importer does when a list or bookmark it
+// is about to create already exists in Karakeep.
+type DuplicatePolicy string
+
+const (
+	// PolicyDuplicate creates the item even if an equivalent one exists. This
+	// is the behaviour of an Importer created by NewImporter.
+	PolicyDuplicate DuplicatePolicy = "duplicate"
+	// PolicySkip reuses the existing item instead of creating a new one.
+	PolicySkip DuplicatePolicy = "skip"
+	// PolicyUpdate reuses the existing item and overwrites its fields with
+	// the Raindrop data. Lists have nothing to update, so for lists this is
+	// the same as PolicySkip.
+	PolicyUpdate DuplicatePolicy = "update"
+)
+
+// ParseDuplicatePolicy parses a policy name as accepted on the command line.
+func ParseDuplicatePolicy(value string) (DuplicatePolicy, error) {
+	switch policy := DuplicatePolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case PolicyDuplicate, PolicySkip, PolicyUpdate:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid duplicate policy %q (expected skip, update or duplicate)", value)
+	}
+}
+
+// matchesExisting reports whether the policy requires looking up existing items.
+func (p DuplicatePolicy) matchesExisting() bool {
+	return p == PolicySkip || p == PolicyUpdate
+}
+
+// existingState indexes the lists and bookmarks that were already in Karakeep
+// when the import started, plus the bookmarks created so far by this run.
+// Bookmarks are looked up and added concurrently by the import workers.
+type existingState struct {
+	lists     map[string]string           // listKey(parent ID, name) -> list ID
+	bookmarks map[string]existingBookmark // normalized URL -> bookmark
+	mu        sync.RWMutex
+}
+
+// existingBookmark is an entry in the bookmark index.
+type existingBookmark struct {
+	id string
+	// imported is set for bookmarks created by the current run, which never
+	// need updating.
+	imported bool
+}
+
+// loadExisting fetches the current Karakeep lists and bookmarks for the entity
+// types whose policy needs them.
+func (i *Importer) loadExisting(ctx context.Context) error {
+	i.existing = &existingState{
+		lists:     make(map[string]string),
+		bookmarks: make(map[string]existingBookmark),
+	}
+
+	if i.ListPolicy.matchesExisting() {
+		fmt.Println("Loading existing lists from Karakeep...")
+		for list, err := range i.KarakeepClient.Lists(ctx) {
+			if err != nil {
+				return fmt.Errorf("failed to get existing lists: %w", err)
+			}
+			key := listKey(list.ParentID, list.Name)
+			if _, ok := i.existing.lists[key]; !ok {
+				i.existing.lists[key] = list.ID
+			}
+		}
+	}
+
+	if i.BookmarkPolicy.matchesExisting() {
+		fmt.Println("Loading existing bookmarks from Karakeep...")
+		for bookmark, err := range i.KarakeepClient.Bookmarks(ctx) {
+			if err != nil {
+				return fmt.Errorf("failed to get existing bookmarks: %w", err)
+			}
+			i.existing.rememberBookmark(bookmark.URL, bookmark.ID, false)
+		}
+	}
+
+	return nil
+}
+
+// list returns the ID of an existing list with the given listKey.
+func (s *existingState) list(key string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	id, ok := s.lists[key]
+	return id, ok
+}
+
+// bookmark returns the existing bookmark for the given URL.
+func (s *existingState) bookmark(rawURL string) (existingBookmark, bool) {
+	if s == nil || rawURL == "" {
+		return existingBookmark{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	bookmark, ok := s.bookmarks[normalizeURL(rawURL)]
+	return bookmark, ok
+}
+
+// rememberBookmark adds a bookmark to the index, keeping the first one seen
+// for a URL. imported marks bookmarks created by the current run.
+func (s *existingState) rememberBookmark(rawURL, id string, imported bool) {
+	if s == nil || rawURL == "" {
+		return
+	}
+	key := normalizeURL(rawURL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.bookmarks[key]; !ok {
+		s.bookmarks[key] = existingBookmark{id: id, imported: imported}
+	}
+}
+
+// normalizeURL reduces a URL to a canonical form so that trivially different
+// spellings of the same address compare equal: the scheme and host are
+// lower-cased, a leading "www.", default ports, fragments, trailing slashes
+// and utm_* tracking parameters are dropped, and query parameters are sorted.
+func normalizeURL(rawURL string) string {
+	trimmed := strings.TrimSpace(rawURL)
+	u, err := url.Parse(trimmed)
+	if err != nil || u.Host == "" {
+		return trimmed
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,187 @@
+//go:build !integration
+
+package importer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"https://example.com/page", "https://example.com/page/", true},
+		{"https://Example.COM/page", "https://example.com/page", true},
+		{"https://www.example.com/page", "https://example.com/page", true},
+		{"https://example.com:443/page", "https://example.com/page", true},
+		{"https://example.com/page#section", "https://example.com/page", true},
+		{"https://example.com/page?b=2&a=1", "https://example.com/page?a=1&b=2", true},
+		{"https://example.com/page?utm_source=x&id=1", "https://example.com/page?id=1", true},
+		{"  https://example.com/page  ", "https://example.com/page", true},
+		{"https://example.com/Page", "https://example.com/page", false},
+		{"https://example.com/page?id=1", "https://example.com/page?id=2", false},
+		{"http://example.com/page", "https://example.com/page", false},
+		{"https://example.com:8080/page", "https://example.com/page", false},
+	}
+
+	for _, tt := range tests {
+		got := normalizeURL(tt.a) == normalizeURL(tt.b)
+		if got != tt.equal {
+			t.Errorf("normalizeURL(%q) == normalizeURL(%q) is %v, expected %v (%q vs %q)",
+				tt.a, tt.b, got, tt.equal, normalizeURL(tt.a), normalizeURL(tt.b))
+		}
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	for _, value := range []string{"skip", "update", "duplicate", " SKIP "} {
+		if _, err := ParseDuplicatePolicy(value); err != nil {
+			t.Errorf("ParseDuplicatePolicy(%q) returned error: %v", value, err)
+		}
+	}
+	if _, err := ParseDuplicatePolicy("merge"); err == nil {
+		t.Error("Expected error for unknown policy, got nil")
+	}
+}
+
+// dedupeKarakeepServer mocks a Karakeep instance that already holds a
+// "Reading" list and a bookmark for https://example.com/existing.
+type dedupeKarakeepServer struct {
+	listCreations     int
+	bookmarkCreations int
+	updates           []string
+	memberships       []string
+}
+
+func (s *dedupeKarakeepServer) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v1/lists":
+			fmt.Fprintln(w, `[{"id": "existing-list", "name": "Reading"}]`)
+		case r.Method == "GET" && r.URL.Path == "/v1/bookmarks":
+			fmt.Fprintln(w, `[{"id": "existing-bookmark", "url": "https://www.example.com/existing/", "title": "Old"}]`)
+		case r.Method == "POST" && r.URL.Path == "/v1/lists":
+			s.listCreations++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": "new-list-%d", "name": "New"}`, s.listCreations)
+		case r.Method == "POST" && r.URL.Path == "/v1/bookmarks":
+			s.bookmarkCreations++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": "new-bookmark-%d", "title": "New"}`, s.bookmarkCreations)
+		case r.Method == "PATCH":
+			s.updates = append(s.updates, r.URL.Path)
+			fmt.Fprintln(w, `{"id": "existing-bookmark"}`)
+		case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/v1/lists/"):
+			s.memberships = append(s.memberships, r.URL.Path)
+			fmt.Fprintln(w, `{}`)
+		default:
+			t.Errorf("Unexpected request to Karakeep: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func dedupeRaindropHandler(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/rest/v1/collections":
+		fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}, {"_id": 2, "title": "Cooking"}]}`)
+	case r.URL.Path == "/rest/v1/raindrops/1" && r.URL.Query().Get("page") == "0":
+		fmt.Fprintln(w, `{"items": [
+			{"_id": 101, "title": "Existing", "link": "https://example.com/existing"},
+			{"_id": 102, "title": "Fresh", "link": "https://example.com/fresh"}
+		]}`)
+	case r.URL.Path == "/rest/v1/raindrops/2" && r.URL.Query().Get("page") == "0":
+		fmt.Fprintln(w, `{"items": [{"_id": 201, "title": "Fresh Again", "link": "https://example.com/fresh#top"}]}`)
+	default:
+		fmt.Fprintln(w, `{"items": []}`)
+	}
+}
+
+func TestRunImportSkipsExistingItems(t *testing.T) {
+	karakeep := &dedupeKarakeepServer{}
+	importer := newTestImporter(t, dedupeRaindropHandler, karakeep.handler(t))
+	importer.ListPolicy = PolicySkip
+	importer.BookmarkPolicy = PolicySkip
+
+	if _, err := importer.RunImport(context.Background()); err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+
+	if karakeep.listCreations != 1 {
+		t.Errorf("Expected only the Cooking list to be created, got %d list creations", karakeep.listCreations)
+	}
+	if karakeep.bookmarkCreations != 1 {
+		t.Errorf("Expected only one new bookmark, got %d bookmark creations", karakeep.bookmarkCreations)
+	}
+	if len(karakeep.updates) != 0 {
+		t.Errorf("Expected no updates with skip policy, got %v", karakeep.updates)
+	}
+
+	sort.Strings(karakeep.memberships)
+	expected := []string{
+		"/v1/lists/existing-list/bookmarks/existing-bookmark",
+		"/v1/lists/existing-list/bookmarks/new-bookmark-1",
+		"/v1/lists/new-list-1/bookmarks/new-bookmark-1",
+	}
+	if strings.Join(karakeep.memberships, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected memberships %v, got %v", expected, karakeep.memberships)
+	}
+
+	list, ok := importer.Ledger.List(1)
+	if !ok || !list.Existing {
+		t.Errorf("Expected reused list to be marked as existing in the ledger, got %+v", list)
+	}
+	bookmark, ok := importer.Ledger.Bookmark(101)
+	if !ok || !bookmark.Existing || bookmark.KarakeepID != "existing-bookmark" {
+		t.Errorf("Expected reused bookmark to be marked as existing in the ledger, got %+v", bookmark)
+	}
+}
+
+func TestRunImportUpdatesExistingBookmarks(t *testing.T) {
+	karakeep := &dedupeKarakeepServer{}
+	importer := newTestImporter(t, dedupeRaindropHandler, karakeep.handler(t))
+	importer.BookmarkPolicy = PolicyUpdate
+
+	if _, err := importer.RunImport(context.Background()); err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+
+	if karakeep.listCreations != 2 {
+		t.Errorf("Expected lists to be duplicated with the default list policy, got %d creations", karakeep.listCreations)
+	}
+	if len(karakeep.updates) != 1 || karakeep.updates[0] != "/v1/bookmarks/existing-bookmark" {
+		t.Errorf("Expected the existing bookmark to be updated, got %v", karakeep.updates)
+	}
+	if karakeep.bookmarkCreations != 1 {
+		t.Errorf("Expected only one new bookmark, got %d bookmark creations", karakeep.bookmarkCreations)
+	}
+}
+
+func TestBuildPlanWithDuplicatePolicies(t *testing.T) {
+	karakeep := &dedupeKarakeepServer{}
+	importer := newTestImporter(t, dedupeRaindropHandler, karakeep.handler(t))
+	importer.ListPolicy = PolicySkip
+	importer.BookmarkPolicy = PolicySkip
+
+	plan, err := importer.BuildPlan(context.Background())
+	if err != nil {
+		t.Fatalf("BuildPlan failed: %v", err)
+	}
+
+	expected := PlanCounts{
+		ListsToCreate:     1,
+		ListsToReuse:      1,
+		BookmarksToCreate: 1,
+		BookmarksToReuse:  2,
+		MembershipsToAdd:  3,
+	}
+	if plan.Counts != expected {
+		t.Errorf("Expected counts %+v, got %+v", expected, plan.Counts)
+	}
+}
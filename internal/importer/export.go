@@ -0,0 +1,351 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/ashebanow/rainbridge/internal/httpx"
+	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/ledger"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+// errListNotExported is the failure of a bookmark whose list could not be
+// turned into a collection.
+var errListNotExported = errors.New("its list could not be exported")
+
+// ExportResult summarizes an export from Karakeep to Raindrop.io.
+type ExportResult struct {
+	CollectionsCreated int
+	CollectionsSkipped int
+	CollectionsFailed  int
+
+	BookmarksCreated  int
+	BookmarksSkipped  int
+	BookmarksFailed   int
+	HighlightsCreated int
+
+	// Errors lists every failure, in the order they occurred. The
+	// collection of an error is named after the Karakeep list.
+	Errors []ItemError
+
+	Elapsed time.Duration
+}
+
+// Failures returns the number of lists and bookmarks that failed to export.
+func (r *ExportResult) Failures() int {
+	return r.CollectionsFailed + r.BookmarksFailed
+}
+
+// HasFailures reports whether anything failed to export.
+func (r *ExportResult) HasFailures() bool {
+	return r.Failures() > 0
+}
+
+// addBookmarkFailure records a bookmark that could not be exported.
+func (r *ExportResult) addBookmarkFailure(collection raindrop.Collection, bookmark *karakeep.Bookmark, err error) {
+	r.BookmarksFailed++
+	e := newItemError(FailedBookmark, collection, err)
+	e.Title = bookmark.Title
+	e.URL = bookmark.URL
+	r.Errors = append(r.Errors, e)
+}
+
+// PrintSummary writes the totals followed by every failure.
+func (r *ExportResult) PrintSummary(w io.Writer) {
+	fmt.Fprintf(w, "\nExport summary (%s)\n\n", r.Elapsed.Round(time.Second))
+	fmt.Fprintf(w, "Collections: %d created, %d already exported, %d failed\n",
+		r.CollectionsCreated, r.CollectionsSkipped, r.CollectionsFailed)
+	fmt.Fprintf(w, "Bookmarks: %d created, %d skipped, %d failed\n",
+		r.BookmarksCreated, r.BookmarksSkipped, r.BookmarksFailed)
+	if r.HighlightsCreated > 0 {
+		fmt.Fprintf(w, "Highlights: %d created\n", r.HighlightsCreated)
+	}
+
+	if len(r.Errors) == 0 {
+		fmt.Fprintln(w, "No failures.")
+		return
+	}
+
+	fmt.Fprintf(w, "\n%d failures:\n", len(r.Errors))
+	for _, e := range r.Errors {
+		message := e.Error
+		if e.Detail != "" {
+			message += " (" + e.Detail + ")"
+		}
+		if e.Stage == FailedList {
+			fmt.Fprintf(w, "  %-10s %s: %s\n", e.Stage, e.CollectionTitle, message)
+		} else {
+			fmt.Fprintf(w, "  %-10s %s <%s> in %s: %s\n", e.Stage, e.Title, e.URL, e.CollectionTitle, message)
+		}
+	}
+}
+
+// exportGroup holds the bookmarks of one Karakeep list, to create in its
+// Raindrop.io collection.
+type exportGroup struct {
+	collection raindrop.Collection
+	// listID is the Karakeep list the bookmarks are in, or "" for Unsorted.
+	listID    string
+	bookmarks []*karakeep.Bookmark
+}
+
+// Export copies the Karakeep lists and bookmarks into Raindrop.io, the
+// reverse of RunImport. Each manual list becomes a collection, nested like
+// the list, and each bookmark is created in the collection of the first list
+// it is in, or in Unsorted when it is in none, with its tags, note,
+// favourite flag and highlights. Raindrop.io has no archive, so archived
+// bookmarks are exported like the others. Bookmarks without a URL, such as
+// notes and images, are skipped. The list imported from the Trash is
+// treated as no list at all.
+//
+// Exported lists and bookmarks are recorded in the ledger as items that
+// already existed in Karakeep, so running Export again only exports what was
+// added since, RunImport and Sync do not copy them back and Cleanup keeps
+// them. Lists and bookmarks the ledger knows from an import are not exported
+// again; bookmarks added to such a list go to the collection it came from.
+// Cancellation and rejected tokens are handled as in RunImport.
+func (i *Importer) Export(ctx context.Context) (*ExportResult, error) {
+	ctx, done := i.begin(ctx)
+	defer done()
+	start := time.Now()
+	result := &ExportResult{}
+	defer func() { result.Elapsed = time.Since(start) }()
+
+	if i.Ledger == nil {
+		i.Ledger = ledger.New()
+	}
+	// fetchFailed is the error of a fetch that ended the export.
+	fetchFailed := func(what string, err error) error {
+		i.stopOnAuthFailure(err)
+		if ctx.Err() != nil {
+			return i.interrupted(ctx)
+		}
+		return fmt.Errorf("failed to get %s: %w", what, err)
+	}
+
+	// The lists imported from system collections other than Unsorted, such
+	// as the Trash, are not exported, so that nothing is created there.
+	collections := make(map[string]raindrop.Collection)
+	systemLists := make(map[string]bool)
+	for collectionID, record := range i.Ledger.Lists() {
+		if collectionID <= 0 && collectionID != raindrop.UnsortedCollectionID {
+			systemLists[record.KarakeepID] = true
+			continue
+		}
+		collections[record.KarakeepID] = raindrop.Collection{ID: collectionID}
+	}
+
+	fmt.Println("Fetching lists from Karakeep...")
+	var lists []*karakeep.List
+	for list, err := range i.KarakeepClient.Lists(ctx) {
+		if err != nil {
+			return result, fetchFailed("lists", err)
+		}
+		if list.Type != karakeep.ListSmart && !systemLists[list.ID] {
+			lists = append(lists, list)
+		}
+	}
+	lists = orderLists(lists)
+
+	for _, list := range lists {
+		if ctx.Err() != nil {
+			return result, i.interrupted(ctx)
+		}
+		if collection, ok := collections[list.ID]; ok {
+			collection.Title = list.Name
+			collections[list.ID] = collection
+			result.CollectionsSkipped++
+			continue
+		}
+		// A list whose parent failed is exported at the root.
+		var parentID int64
+		if parent, ok := collections[list.ParentID]; ok && parent.ID > 0 {
+			parentID = parent.ID
+		}
+		created, err := i.RaindropClient.CreateCollection(context.WithoutCancel(ctx), list.Name, parentID)
+		i.stopOnAuthFailure(err)
+		if err != nil {
+			log.Printf("Failed to create collection '%s': %v", list.Name, err)
+			result.CollectionsFailed++
+			result.Errors = append(result.Errors, newItemError(FailedList, raindrop.Collection{Title: list.Name}, err))
+			continue
+		}
+		fmt.Printf("  - Created collection: %s\n", list.Name)
+		result.CollectionsCreated++
+		i.Ledger.RecordExistingList(created.ID, list.ID)
+		collections[list.ID] = raindrop.Collection{ID: created.ID, Title: list.Name}
+	}
+	if err := i.Ledger.Save(); err != nil {
+		return result, fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	fmt.Println("Fetching list contents from Karakeep...")
+	memberOf := make(map[string]*karakeep.List)
+	for _, list := range lists {
+		for bookmark, err := range i.KarakeepClient.ListBookmarks(ctx, list.ID) {
+			if err != nil {
+				return result, fetchFailed(fmt.Sprintf("bookmarks of list %s", list.Name), err)
+			}
+			if _, ok := memberOf[bookmark.ID]; !ok {
+				memberOf[bookmark.ID] = list
+			}
+		}
+	}
+
+	highlights, err := i.exportHighlights(ctx)
+	if err != nil {
+		return result, fetchFailed("highlights", err)
+	}
+
+	fmt.Println("Fetching bookmarks from Karakeep...")
+	exported := make(map[string]bool)
+	for _, record := range i.Ledger.Bookmarks() {
+		exported[record.KarakeepID] = true
+	}
+	unsorted := raindrop.Collection{ID: raindrop.UnsortedCollectionID, Title: "Unsorted"}
+	var groups []*exportGroup
+	byList := make(map[string]*exportGroup)
+	for bookmark, err := range i.KarakeepClient.Bookmarks(ctx) {
+		if err != nil {
+			return result, fetchFailed("bookmarks", err)
+		}
+		if exported[bookmark.ID] || bookmark.URL == "" {
+			result.BookmarksSkipped++
+			continue
+		}
+
+		collection, listID := unsorted, ""
+		if list := memberOf[bookmark.ID]; list != nil {
+			var ok bool
+			if collection, ok = collections[list.ID]; !ok {
+				result.addBookmarkFailure(raindrop.Collection{Title: list.Name}, bookmark, errListNotExported)
+				continue
+			}
+			listID = list.ID
+		}
+		group := byList[listID]
+		if group == nil {
+			group = &exportGroup{collection: collection, listID: listID}
+			byList[listID] = group
+			groups = append(groups, group)
+		}
+		group.bookmarks = append(group.bookmarks, bookmark)
+	}
+
+	for _, group := range groups {
+		for n := 0; n < len(group.bookmarks); n += raindrop.MaxBatchSize {
+			if ctx.Err() != nil {
+				return result, i.interrupted(ctx)
+			}
+			batch := group.bookmarks[n:min(n+raindrop.MaxBatchSize, len(group.bookmarks))]
+			i.exportBatch(context.WithoutCancel(ctx), group, batch, highlights, result)
+			if err := i.Ledger.Save(); err != nil {
+				return result, fmt.Errorf("failed to save checkpoint: %w", err)
+			}
+		}
+	}
+
+	if result.HasFailures() {
+		fmt.Printf("\nExport finished with %d failures.\n", result.Failures())
+	} else {
+		fmt.Println("\nExport complete!")
+	}
+	return result, nil
+}
+
+// exportHighlights fetches the Karakeep highlights as Raindrop.io highlights,
+// by bookmark ID. A server without a highlights API has none.
+func (i *Importer) exportHighlights(ctx context.Context) (map[string][]raindrop.NewHighlight, error) {
+	fmt.Println("Fetching highlights from Karakeep...")
+	highlights := make(map[string][]raindrop.NewHighlight)
+	for highlight, err := range i.KarakeepClient.Highlights(ctx) {
+		if httpx.Classify(err) == httpx.KindNotFound {
+			fmt.Println("Karakeep does not support highlights, exporting bookmarks without them")
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		highlights[highlight.BookmarkID] = append(highlights[highlight.BookmarkID], raindrop.NewHighlight{
+			Text:  highlight.Text,
+			Note:  highlight.Note,
+			Color: highlight.Color,
+		})
+	}
+	return highlights, nil
+}
+
+// exportBatch creates a batch of bookmarks of one group in Raindrop.io with a
+// single request, and records them in the ledger.
+func (i *Importer) exportBatch(ctx context.Context, group *exportGroup, batch []*karakeep.Bookmark, highlights map[string][]raindrop.NewHighlight, result *ExportResult) {
+	items := make([]raindrop.NewRaindrop, len(batch))
+	for n, bookmark := range batch {
+		items[n] = newRaindrop(bookmark, group.collection.ID, highlights[bookmark.ID])
+	}
+
+	created, err := i.RaindropClient.CreateRaindrops(ctx, items)
+	i.stopOnAuthFailure(err)
+	if err != nil {
+		log.Printf("Failed to create %d bookmarks in collection '%s': %v", len(batch), group.collection.Title, err)
+		for _, bookmark := range batch {
+			result.addBookmarkFailure(group.collection, bookmark, err)
+		}
+		return
+	}
+
+	for n, item := range created {
+		i.Ledger.RecordExistingBookmark(item.ID, group.collection.ID, batch[n].ID)
+		if group.listID != "" {
+			i.Ledger.RecordMembership(item.ID, group.listID)
+		}
+		result.BookmarksCreated++
+		result.HighlightsCreated += len(items[n].Highlights)
+	}
+	fmt.Printf("  - Created %d bookmarks in collection: %s\n", len(created), group.collection.Title)
+}
+
+// newRaindrop converts a Karakeep bookmark into the Raindrop.io bookmark to
+// create, the reverse of newBookmark. Karakeep highlight colors are also
+// Raindrop.io colors.
+func newRaindrop(bookmark *karakeep.Bookmark, collectionID int64, highlights []raindrop.NewHighlight) raindrop.NewRaindrop {
+	return raindrop.NewRaindrop{
+		Link:       bookmark.URL,
+		Title:      bookmark.Title,
+		Excerpt:    bookmark.Description,
+		Note:       bookmark.Note,
+		Tags:       bookmark.Tags,
+		Important:  bookmark.Favourited,
+		Created:    bookmark.CreatedAt,
+		Collection: raindrop.CollectionRef{ID: collectionID},
+		Highlights: highlights,
+	}
+}
+
+// orderLists sorts lists parents-first, keeping the original order among
+// siblings, as orderCollections does for collections.
+func orderLists(lists []*karakeep.List) []*karakeep.List {
+	known := make(map[string]bool, len(lists))
+	for _, list := range lists {
+		known[list.ID] = true
+	}
+
+	children := make(map[string][]*karakeep.List)
+	var ordered []*karakeep.List
+	for _, list := range lists {
+		if list.ParentID != "" && known[list.ParentID] {
+			children[list.ParentID] = append(children[list.ParentID], list)
+		} else {
+			ordered = append(ordered, list)
+		}
+	}
+
+	for n := 0; n < len(ordered); n++ {
+		ordered = append(ordered, children[ordered[n].ID]...)
+	}
+	return ordered
+}
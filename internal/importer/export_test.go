@@ -0,0 +1,201 @@
+//go:build !integration
+
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/ledger"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+func TestOrderLists(t *testing.T) {
+	lists := []*karakeep.List{
+		{ID: "child", ParentID: "root"},
+		{ID: "root"},
+		{ID: "orphan", ParentID: "missing"},
+		{ID: "grandchild", ParentID: "child"},
+	}
+	var ids []string
+	for _, list := range orderLists(lists) {
+		ids = append(ids, list.ID)
+	}
+	if fmt.Sprint(ids) != "[root orphan child grandchild]" {
+		t.Errorf("Expected parents before children, got %v", ids)
+	}
+}
+
+func TestExport(t *testing.T) {
+	karakeepHandler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/lists":
+			// The child is listed before its parent, and the smart list is
+			// not exported.
+			fmt.Fprintln(w, `{"lists": [
+				{"id": "l2", "name": "Go", "parentId": "l1", "type": "manual"},
+				{"id": "l1", "name": "Reading", "type": "manual"},
+				{"id": "l3", "name": "Favourites", "type": "smart"}
+			]}`)
+		case "/v1/lists/l1/bookmarks":
+			fmt.Fprintln(w, `{"bookmarks": [{"id": "b1"}, {"id": "b2"}]}`)
+		case "/v1/lists/l2/bookmarks":
+			fmt.Fprintln(w, `{"bookmarks": [{"id": "b3"}, {"id": "b1"}]}`)
+		case "/v1/highlights":
+			fmt.Fprintln(w, `{"highlights": [{"id": "h1", "bookmarkId": "b1", "text": "Quote", "note": "Why", "color": "yellow"}]}`)
+		case "/v1/bookmarks":
+			fmt.Fprintln(w, `{"bookmarks": [
+				{"id": "b1", "title": "First", "note": "Read again", "favourited": true, "tags": [{"name": "go"}],
+				 "content": {"type": "link", "url": "https://example.com/1", "description": "One"}},
+				{"id": "b2", "content": {"type": "link", "url": "https://example.com/2"}},
+				{"id": "b3", "content": {"type": "link", "url": "https://example.com/3"}},
+				{"id": "b4", "content": {"type": "link", "url": "https://example.com/4"}},
+				{"id": "b5", "content": {"type": "text", "text": "A note"}}
+			]}`)
+		default:
+			t.Errorf("Unexpected Karakeep request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+
+	var batches [][]raindrop.NewRaindrop
+	nextID := int64(100)
+	raindropHandler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/v1/collection":
+			var body struct {
+				Title  string                  `json:"title"`
+				Parent *raindrop.CollectionRef `json:"parent"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			switch {
+			case body.Title == "Reading" && body.Parent == nil:
+				fmt.Fprintln(w, `{"item": {"_id": 11, "title": "Reading"}}`)
+			case body.Title == "Go" && body.Parent != nil && body.Parent.ID == 11:
+				fmt.Fprintln(w, `{"item": {"_id": 12, "title": "Go"}}`)
+			default:
+				t.Errorf("Unexpected collection %+v", body)
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		case "/rest/v1/raindrops":
+			var body struct {
+				Items []raindrop.NewRaindrop `json:"items"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			batches = append(batches, body.Items)
+			var created []raindrop.Raindrop
+			for range body.Items {
+				nextID++
+				created = append(created, raindrop.Raindrop{ID: nextID})
+			}
+			json.NewEncoder(w).Encode(map[string]any{"items": created})
+		default:
+			t.Errorf("Unexpected Raindrop request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+
+	checkpoint := ledger.New()
+	// b2 was imported from Raindrop.io, so it is already there.
+	checkpoint.RecordBookmark(7, 1, "b2")
+
+	importer := newTestImporter(t, raindropHandler, karakeepHandler)
+	importer.Ledger = checkpoint
+
+	result, err := importer.Export(context.Background())
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	if result.CollectionsCreated != 2 || result.BookmarksCreated != 3 || result.BookmarksSkipped != 2 || result.HighlightsCreated != 1 || result.HasFailures() {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	// One batch per collection: b1 in Reading, the first list it is in, b3
+	// in Go and b4, which is in no list, in Unsorted.
+	if len(batches) != 3 {
+		t.Fatalf("Expected 3 batches, got %d", len(batches))
+	}
+	first := batches[0][0]
+	if first.Link != "https://example.com/1" || first.Title != "First" || first.Excerpt != "One" || first.Note != "Read again" ||
+		!first.Important || fmt.Sprint(first.Tags) != "[go]" || first.Collection.ID != 11 {
+		t.Errorf("Unexpected first raindrop %+v", first)
+	}
+	if len(first.Highlights) != 1 || first.Highlights[0] != (raindrop.NewHighlight{Text: "Quote", Note: "Why", Color: "yellow"}) {
+		t.Errorf("Unexpected highlights %+v", first.Highlights)
+	}
+	if batches[1][0].Collection.ID != 12 || batches[2][0].Collection.ID != raindrop.UnsortedCollectionID {
+		t.Errorf("Expected b3 in Go and b4 in Unsorted, got %+v and %+v", batches[1][0], batches[2][0])
+	}
+
+	if rec, ok := checkpoint.Bookmark(101); !ok || rec.KarakeepID != "b1" || rec.CollectionID != 11 || rec.ListID != "l1" || !rec.Existing {
+		t.Errorf("Expected b1 to be recorded as an existing bookmark, got %+v (found=%v)", rec, ok)
+	}
+	if list, ok := checkpoint.List(12); !ok || list.KarakeepID != "l2" || !list.Existing {
+		t.Errorf("Expected the Go list to be recorded as an existing list, got %+v (found=%v)", list, ok)
+	}
+
+	// A second export has nothing left to do.
+	batches = nil
+	result, err = importer.Export(context.Background())
+	if err != nil {
+		t.Fatalf("Second export failed: %v", err)
+	}
+	if result.CollectionsCreated != 0 || result.CollectionsSkipped != 2 || result.BookmarksCreated != 0 || len(batches) != 0 {
+		t.Errorf("Expected the second export to skip everything, got %+v", result)
+	}
+}
+
+func TestExportSkipsTrashList(t *testing.T) {
+	karakeepHandler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/lists":
+			fmt.Fprintln(w, `{"lists": [{"id": "trash", "name": "Raindrop Trash", "type": "manual"}]}`)
+		case "/v1/highlights":
+			fmt.Fprintln(w, `{"highlights": []}`)
+		case "/v1/bookmarks":
+			fmt.Fprintln(w, `{"bookmarks": [{"id": "b1", "content": {"type": "link", "url": "https://example.com/1"}}]}`)
+		default:
+			t.Errorf("Unexpected Karakeep request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+
+	var batches [][]raindrop.NewRaindrop
+	raindropHandler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/raindrops" {
+			t.Errorf("Unexpected Raindrop request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var body struct {
+			Items []raindrop.NewRaindrop `json:"items"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		batches = append(batches, body.Items)
+		fmt.Fprintln(w, `{"items": [{"_id": 101}]}`)
+	}
+
+	checkpoint := ledger.New()
+	checkpoint.RecordList(raindrop.TrashCollectionID, "trash")
+
+	importer := newTestImporter(t, raindropHandler, karakeepHandler)
+	importer.Ledger = checkpoint
+
+	result, err := importer.Export(context.Background())
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	// The bookmark in the Trash list goes to Unsorted, not to the Trash.
+	if result.CollectionsCreated != 0 || result.BookmarksCreated != 1 || result.HasFailures() {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if len(batches) != 1 || batches[0][0].Collection.ID != raindrop.UnsortedCollectionID {
+		t.Errorf("Expected the bookmark in Unsorted, got %+v", batches)
+	}
+}
@@ -0,0 +1,107 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+// importHighlights creates a Karakeep highlight for each Raindrop highlight of
+// a newly created bookmark. If the server has no highlights API, the remaining
+// highlights are appended to the bookmark note instead, and later bookmarks
+// get their highlights written into the note when they are created. It returns
+// the highlights that could not be created.
+//
+// Raindrop.io does not say where in the page a highlight is, and Karakeep has
+// not crawled the page yet, so every highlight is anchored at the start of
+// the bookmark's content with the length of its text. Karakeep lists them
+// with their text, but does not mark them at the right place in the reader.
+func (i *Importer) importHighlights(ctx context.Context, bookmarkID string, item raindrop.Raindrop) []highlightFailure {
+	var failures []highlightFailure
+	for n, highlight := range item.Highlights {
+		_, err := i.KarakeepClient.CreateHighlight(ctx, &karakeep.Highlight{
+			BookmarkID:  bookmarkID,
+			StartOffset: 0,
+			EndOffset:   highlightLength(highlight.Text),
+			Color:       highlightColor(highlight.Color),
+			Text:        highlight.Text,
+			Note:        highlight.Note,
+		})
+		if errors.Is(err, karakeep.ErrHighlightsUnsupported) {
+			fmt.Println("Karakeep does not support highlights, appending them to bookmark notes instead")
+			i.highlightsUnsupported.Store(true)
+			if err := i.appendHighlightsToNote(ctx, bookmarkID, item, item.Highlights[n:]); err != nil {
+				for _, remaining := range item.Highlights[n:] {
+					failures = append(failures, highlightFailure{id: remaining.ID, err: err})
+				}
+			}
+			return failures
+		}
+		if err != nil {
+			log.Printf("Failed to create highlight for bookmark '%s': %v", item.Title, err)
+			failures = append(failures, highlightFailure{id: highlight.ID, err: err})
+		}
+	}
+	return failures
+}
+
+// highlightLength returns the length of a highlight's text as Karakeep
+// counts offsets, in UTF-16 code units like JavaScript strings.
+func highlightLength(text string) int {
+	return len(utf16.Encode([]rune(text)))
+}
+
+// appendHighlightsToNote adds highlights to the note of an existing bookmark.
+func (i *Importer) appendHighlightsToNote(ctx context.Context, bookmarkID string, item raindrop.Raindrop, highlights []raindrop.Highlight) error {
+	note := noteWithHighlights(item.Note, highlights)
+	if _, err := i.KarakeepClient.UpdateBookmark(ctx, bookmarkID, &karakeep.BookmarkUpdate{Note: &note}); err != nil {
+		log.Printf("Failed to add highlights to note of bookmark '%s': %v", item.Title, err)
+		return err
+	}
+	return nil
+}
+
+// noteWithHighlights returns note followed by the highlights as Markdown
+// quotes, each followed by its annotation, if any.
+func noteWithHighlights(note string, highlights []raindrop.Highlight) string {
+	if len(highlights) == 0 {
+		return note
+	}
+
+	var b strings.Builder
+	if note != "" {
+		b.WriteString(note)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Highlights:")
+	for _, highlight := range highlights {
+		b.WriteString("\n\n> ")
+		b.WriteString(strings.ReplaceAll(strings.TrimSpace(highlight.Text), "\n", "\n> "))
+		if highlight.Note != "" {
+			b.WriteString("\n\n")
+			b.WriteString(highlight.Note)
+		}
+	}
+	return b.String()
+}
+
+// highlightColor maps a Raindrop highlight color onto the closest of the
+// colors Karakeep supports.
+func highlightColor(color string) string {
+	switch color {
+	case "red", "pink", "orange", "brown":
+		return karakeep.HighlightRed
+	case "green", "teal", "cyan":
+		return karakeep.HighlightGreen
+	case "blue", "indigo", "purple":
+		return karakeep.HighlightBlue
+	default:
+		return karakeep.HighlightYellow
+	}
+}
@@ -0,0 +1,175 @@
+//go:build !integration
+
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+func TestNoteWithHighlights(t *testing.T) {
+	highlights := []raindrop.Highlight{
+		{Text: "First line\nsecond line"},
+		{Text: "Another", Note: "Annotated"},
+	}
+
+	tests := []struct {
+		name       string
+		note       string
+		highlights []raindrop.Highlight
+		expected   string
+	}{
+		{"no highlights", "My note", nil, "My note"},
+		{"no note", "", highlights[1:], "Highlights:\n\n> Another\n\nAnnotated"},
+		{"note and highlights", "My note", highlights,
+			"My note\n\nHighlights:\n\n> First line\n> second line\n\n> Another\n\nAnnotated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := noteWithHighlights(tt.note, tt.highlights); got != tt.expected {
+				t.Errorf("noteWithHighlights() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHighlightColor(t *testing.T) {
+	tests := map[string]string{
+		"":       karakeep.HighlightYellow,
+		"yellow": karakeep.HighlightYellow,
+		"pink":   karakeep.HighlightRed,
+		"teal":   karakeep.HighlightGreen,
+		"purple": karakeep.HighlightBlue,
+		"gray":   karakeep.HighlightYellow,
+	}
+	for color, expected := range tests {
+		if got := highlightColor(color); got != expected {
+			t.Errorf("highlightColor(%q) = %q, expected %q", color, got, expected)
+		}
+	}
+}
+
+func TestHighlightLength(t *testing.T) {
+	tests := map[string]int{
+		"":        0,
+		"Alpha":   5,
+		"café":    4,
+		"日本語":     3,
+		"Hi 👋":    5,
+		"a\nb\tc": 5,
+	}
+	for text, expected := range tests {
+		if got := highlightLength(text); got != expected {
+			t.Errorf("highlightLength(%q) = %d, expected %d", text, got, expected)
+		}
+	}
+}
+
+// highlightRaindropHandler serves two bookmarks with highlights in a single collection.
+func highlightRaindropHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/rest/v1/collections" {
+		fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}]}`)
+	} else if r.URL.Path == "/rest/v1/raindrops/1" && r.URL.Query().Get("page") == "0" {
+		fmt.Fprintln(w, `{"items": [
+			{"_id": 101, "title": "One", "link": "https://example.com/1", "note": "Note",
+			 "highlights": [{"text": "Alpha", "color": "green"}, {"text": "Beta", "note": "Why"}]},
+			{"_id": 102, "title": "Two", "link": "https://example.com/2",
+			 "highlights": [{"text": "Gamma"}]}
+		]}`)
+	} else {
+		fmt.Fprintln(w, `{"items": []}`)
+	}
+}
+
+func TestRunImportCreatesHighlights(t *testing.T) {
+	var highlights []karakeep.Highlight
+	bookmarkCreations := 0
+	importer := newTestImporter(t, highlightRaindropHandler, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/lists":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "list-1", "name": "Reading"}`)
+		case "/v1/bookmarks":
+			bookmarkCreations++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": "bookmark-%d"}`, bookmarkCreations)
+		case "/v1/highlights":
+			var highlight karakeep.Highlight
+			json.NewDecoder(r.Body).Decode(&highlight)
+			highlights = append(highlights, highlight)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "highlight"}`)
+		default:
+			fmt.Fprintln(w, `{}`)
+		}
+	})
+
+	if _, err := importer.RunImport(context.Background()); err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+
+	if len(highlights) != 3 {
+		t.Fatalf("Expected 3 highlights, got %d", len(highlights))
+	}
+	first := highlights[0]
+	if first.BookmarkID != "bookmark-1" || first.Text != "Alpha" || first.Color != karakeep.HighlightGreen || first.EndOffset != 5 {
+		t.Errorf("Unexpected first highlight: %+v", first)
+	}
+	if highlights[1].Note != "Why" || highlights[2].BookmarkID != "bookmark-2" {
+		t.Errorf("Unexpected highlights: %+v", highlights)
+	}
+}
+
+func TestRunImportAppendsHighlightsToNoteWhenUnsupported(t *testing.T) {
+	highlightRequests := 0
+	var createdNotes []string
+	var updatedNotes []string
+	importer := newTestImporter(t, highlightRaindropHandler, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/lists":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "list-1", "name": "Reading"}`)
+		case r.URL.Path == "/v1/bookmarks":
+			var bookmark karakeep.Bookmark
+			json.NewDecoder(r.Body).Decode(&bookmark)
+			createdNotes = append(createdNotes, bookmark.Note)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": "bookmark-%d"}`, len(createdNotes))
+		case r.URL.Path == "/v1/highlights":
+			highlightRequests++
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintln(w, `{}`)
+		case r.Method == "PATCH":
+			var update karakeep.BookmarkUpdate
+			json.NewDecoder(r.Body).Decode(&update)
+			if update.Note != nil {
+				updatedNotes = append(updatedNotes, r.URL.Path+": "+*update.Note)
+			}
+			fmt.Fprintln(w, `{}`)
+		default:
+			fmt.Fprintln(w, `{}`)
+		}
+	})
+
+	if _, err := importer.RunImport(context.Background()); err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+
+	if highlightRequests != 1 {
+		t.Errorf("Expected highlights API to be tried once, got %d requests", highlightRequests)
+	}
+	expectedUpdate := "/v1/bookmarks/bookmark-1: Note\n\nHighlights:\n\n> Alpha\n\n> Beta\n\nWhy"
+	if len(updatedNotes) != 1 || updatedNotes[0] != expectedUpdate {
+		t.Errorf("Expected note update %q, got %q", expectedUpdate, updatedNotes)
+	}
+	if len(createdNotes) != 2 || createdNotes[0] != "Note" || createdNotes[1] != "Highlights:\n\n> Gamma" {
+		t.Errorf("Unexpected notes on created bookmarks: %q", createdNotes)
+	}
+}
@@ -1,17 +1,74 @@
 package importer
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"log"
+	"sync/atomic"
+	"time"
 
+	"github.com/ashebanow/rainbridge/internal/httpx"
 	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/ledger"
 	"github.com/ashebanow/rainbridge/internal/raindrop"
 )
 
+// checkpointInterval is the number of bookmarks imported between ledger saves.
+const checkpointInterval = 25
+
 // Importer holds the clients for the Raindrop and Karakeep APIs.
 type Importer struct {
 	RaindropClient *raindrop.Client
 	KarakeepClient *karakeep.Client
+
+	// Ledger records completed work so that an interrupted import can be
+	// resumed. When nil, an in-memory ledger is used for the current run.
+	Ledger *ledger.Ledger
+
+	// ListPolicy and BookmarkPolicy control how lists and bookmarks that
+	// already exist in Karakeep are handled. Lists are matched by name and
+	// parent list, and bookmarks by normalized URL. The zero value behaves
+	// like PolicyDuplicate.
+	ListPolicy     DuplicatePolicy
+	BookmarkPolicy DuplicatePolicy
+
+	// DeletedPolicy and MovedPolicy control what Sync does with bookmarks
+	// that were deleted in Raindrop.io, or moved to another collection,
+	// after they were imported. The zero value behaves like SyncIgnore.
+	DeletedPolicy SyncPolicy
+	MovedPolicy   SyncPolicy
+
+	// NestedCollections imports nested Raindrop collections as nested
+	// Karakeep lists. When false, only root collections are imported.
+	NestedCollections bool
+
+	// ImportUnsorted imports the Raindrop Unsorted system collection into a
+	// list named UnsortedListName, or into no list when that is empty.
+	ImportUnsorted   bool
+	UnsortedListName string
+
+	// ImportTrash imports the Raindrop Trash system collection into a list
+	// named TrashListName.
+	ImportTrash   bool
+	TrashListName string
+
+	// ArchiveCollections names the collections, by title or numeric ID,
+	// whose bookmarks are archived in Karakeep. Bookmarks from Trash are
+	// always archived.
+	ArchiveCollections []string
+
+	// Workers is the number of bookmarks imported concurrently. Values below
+	// 2 import one bookmark at a time.
+	Workers int
+
+	existing *existingState
+	// stop ends the current run, with the error that ended it as the cause.
+	stop context.CancelCauseFunc
+	// highlightsUnsupported is set once Karakeep has reported that it has no
+	// highlights API.
+	highlightsUnsupported atomic.Bool
 }
 
 // NewImporter creates a new Importer.
@@ -22,64 +79,346 @@ func NewImporter(raindropClient *raindrop.Client, karakeepClient *karakeep.Clien
 	}
 }
 
-// RunImport performs the full import process.
-func (i *Importer) RunImport() error {
+// RunImport performs the full import process. Collections and bookmarks that
+// are already recorded in the ledger are skipped, so re-running an interrupted
+// import resumes where it stopped.
+//
+// Lists and bookmarks that fail to import are recorded in the returned result
+// and do not stop the import. An error is returned only when the import could
+// not run to the end, in which case the result covers the work done so far.
+//
+// When ctx is cancelled, the lists and bookmarks being imported are finished,
+// the ledger is saved and an error wrapping context.Canceled is returned.
+// When either service rejects its token, the import stops the same way and
+// the returned error wraps raindrop.ErrUnauthorized or
+// karakeep.ErrUnauthorized instead.
+func (i *Importer) RunImport(ctx context.Context) (*ImportResult, error) {
+	ctx, done := i.begin(ctx)
+	defer done()
+	start := time.Now()
+	result := &ImportResult{}
+	defer func() { result.Elapsed = time.Since(start) }()
+
+	if i.Ledger == nil {
+		i.Ledger = ledger.New()
+	}
+
 	// 1. Fetch collections from Raindrop.io
 	fmt.Println("Fetching collections from Raindrop.io...")
-	collections, err := i.RaindropClient.GetCollections()
+	collections, err := i.fetchCollections(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get collections: %w", err)
+		return result, fmt.Errorf("failed to get collections: %w", err)
 	}
 	fmt.Printf("Fetched %d collections.\n", len(collections))
 
+	if err := i.loadExisting(ctx); err != nil {
+		return result, err
+	}
+
 	// 2. Create corresponding lists in Karakeep
 	fmt.Println("Creating lists in Karakeep...")
-	collectionMap := make(map[int64]string)
 	for _, collection := range collections {
-		list := &karakeep.List{Name: collection.Title}
-		createdList, err := i.KarakeepClient.CreateList(list)
-		if err != nil {
-			log.Printf("Failed to create list '%s': %v", collection.Title, err)
-			continue
+		if ctx.Err() != nil {
+			return result, i.interrupted(ctx)
+		}
+		result.collection(collection)
+		if i.hasList(collection) {
+			i.createList(context.WithoutCancel(ctx), collection, result)
 		}
-		collectionMap[collection.ID] = createdList.ID
-		fmt.Printf("Created list: %s\n", createdList.Name)
+	}
+	if err := i.Ledger.Save(); err != nil {
+		return result, fmt.Errorf("failed to save checkpoint: %w", err)
 	}
 
 	// 3. Fetch bookmarks for each collection and import
 	fmt.Println("\nImporting bookmarks...")
 	for _, collection := range collections {
-		fmt.Printf("\nFetching bookmarks for collection: %s\n", collection.Title)
-		raindrops, err := i.RaindropClient.GetRaindropsByCollection(collection.ID)
-		if err != nil {
-			log.Printf("Failed to get raindrops for collection '%s': %v", collection.Title, err)
-			continue
+		if ctx.Err() != nil {
+			return result, i.interrupted(ctx)
+		}
+		if err := i.importCollection(ctx, collection, result); err != nil {
+			return result, err
+		}
+	}
+	// As in Sync, a run with failures leaves the next sync to look at
+	// everything again.
+	if !result.HasFailures() {
+		i.Ledger.RecordSync(start)
+	}
+	if err := i.Ledger.Save(); err != nil {
+		return result, fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	if result.HasFailures() {
+		fmt.Printf("\nImport finished with %d failures.\n", result.Failures())
+	} else {
+		fmt.Println("\nImport complete!")
+	}
+	return result, nil
+}
+
+// createList creates the Karakeep list for a collection, or reuses the one
+// recorded in the ledger or found in Karakeep.
+func (i *Importer) createList(ctx context.Context, collection raindrop.Collection, result *ImportResult) {
+	parentID, hasParent := i.parentListID(collection)
+	action, listID := i.listAction(collection, parentID, hasParent)
+	switch action {
+	case ActionSkip:
+		fmt.Printf("Reusing list: %s (%s)\n", collection.Title, listID)
+		result.addList(collection, action, nil)
+		return
+	case ActionReuse:
+		i.Ledger.RecordExistingList(collection.ID, listID)
+		fmt.Printf("Reusing existing list: %s (%s)\n", collection.Title, listID)
+		result.addList(collection, action, nil)
+		return
+	}
+
+	if !hasParent {
+		// Creating the list at the top level would flatten the collections.
+		err := fmt.Errorf("list of the parent collection was not created")
+		log.Printf("Failed to create list '%s': %v", collection.Title, err)
+		result.addList(collection, action, err)
+		return
+	}
+	list := &karakeep.List{Name: collection.Title, ParentID: parentID}
+	createdList, err := i.KarakeepClient.CreateList(ctx, list)
+	if err != nil {
+		i.stopOnAuthFailure(err)
+		log.Printf("Failed to create list '%s': %v", collection.Title, err)
+		result.addList(collection, action, err)
+		return
+	}
+	i.Ledger.RecordList(collection.ID, createdList.ID)
+	fmt.Printf("Created list: %s\n", createdList.Name)
+	result.addList(collection, action, nil)
+}
+
+// importCollection fetches the bookmarks of a collection and imports them
+// while the next ones are being fetched, saving the ledger as it goes. It
+// only returns an error when the ledger cannot be saved or ctx is cancelled.
+func (i *Importer) importCollection(ctx context.Context, collection raindrop.Collection, result *ImportResult) error {
+	fmt.Printf("\nFetching bookmarks for collection: %s\n", collection.Title)
+	items := i.RaindropClient.Raindrops(ctx, collection.ID, raindrop.PageOptions{})
+	return i.importItems(ctx, collection, items, i.importBookmark, result)
+}
+
+// importItems imports the bookmarks of a collection yielded by items with
+// importOne and records the outcomes in result, as importCollection does.
+func (i *Importer) importItems(ctx context.Context, collection raindrop.Collection, items iter.Seq2[raindrop.Raindrop, error], importOne importFunc, result *ImportResult) error {
+	listID, _ := i.Ledger.ListID(collection.ID)
+	raindrops, outcomes, fetchErr, err := i.importBookmarks(ctx, collection, items, importOne, listID)
+	for n, item := range raindrops {
+		if outcomes[n].action != "" {
+			result.addBookmark(collection, item, outcomes[n])
 		}
+	}
+	if err != nil {
+		return err
+	}
+	i.stopOnAuthFailure(fetchErr)
+	if ctx.Err() != nil {
+		return i.interrupted(ctx)
+	}
+	if fetchErr != nil {
+		log.Printf("Failed to get raindrops for collection '%s': %v", collection.Title, fetchErr)
+		result.addFetchFailure(collection, fetchErr)
+	} else {
 		fmt.Printf("Found %d bookmarks in this collection.\n", len(raindrops))
+	}
 
-		listID := collectionMap[collection.ID]
+	if err := i.Ledger.Save(); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
 
-		for _, raindrop := range raindrops {
-			bookmark := &karakeep.Bookmark{
-				URL:         raindrop.Link,
-				Title:       raindrop.Title,
-				Description: raindrop.Excerpt,
-				Tags:        raindrop.Tags,
-			}
+// interrupted saves the ledger after ctx has been cancelled and returns the
+// error that ends the run.
+func (i *Importer) interrupted(ctx context.Context) error {
+	if err := i.Ledger.Save(); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	cause := context.Cause(ctx)
+	if authFailure(cause) {
+		return cause
+	}
+	return fmt.Errorf("interrupted: %w", cause)
+}
 
-			createdBookmark, err := i.KarakeepClient.CreateBookmark(bookmark)
-			if err != nil {
-				log.Printf("Failed to create bookmark '%s': %v", raindrop.Title, err)
-				continue
-			}
-			fmt.Printf("  - Created bookmark: %s\n", createdBookmark.Title)
+// begin returns the context for a run, which stopOnAuthFailure cancels, and
+// the function to call when the run is over.
+func (i *Importer) begin(ctx context.Context) (context.Context, func()) {
+	ctx, stop := context.WithCancelCause(ctx)
+	i.stop = stop
+	return ctx, func() { stop(nil) }
+}
+
+// stopOnAuthFailure stops the current run when one of errs shows that
+// Raindrop.io or Karakeep rejected its token, since every request that
+// follows would fail the same way. The run then ends as if interrupted.
+func (i *Importer) stopOnAuthFailure(errs ...error) {
+	for _, err := range errs {
+		if authFailure(err) && i.stop != nil {
+			log.Printf("Stopping: %v", err)
+			i.stop(err)
+			return
+		}
+	}
+}
 
-			if err := i.KarakeepClient.AddBookmarkToList(createdBookmark.ID, listID); err != nil {
-				log.Printf("Failed to add bookmark '%s' to list: %v", createdBookmark.Title, err)
+// authFailure reports whether err shows that a token was rejected.
+func authFailure(err error) bool {
+	return errors.Is(err, raindrop.ErrUnauthorized) || errors.Is(err, karakeep.ErrUnauthorized)
+}
+
+// listAction reports how a collection maps onto a Karakeep list, and the ID
+// of the list to use when it is not created. parentID is the list it is
+// nested under, as returned by parentListID. When the parent has no list
+// yet, no existing list can match, so a new one is needed.
+func (i *Importer) listAction(collection raindrop.Collection, parentID string, hasParent bool) (Action, string) {
+	if listID, ok := i.Ledger.ListID(collection.ID); ok {
+		return ActionSkip, listID
+	}
+	if i.ListPolicy.matchesExisting() && hasParent {
+		if listID, ok := i.existing.list(listKey(parentID, collection.Title)); ok {
+			return ActionReuse, listID
+		}
+	}
+	return ActionCreate, ""
+}
+
+// bookmarkAction reports what importing a bookmark involves, given what the
+// ledger says previous runs already did and what already exists in Karakeep,
+// along with the ID of the Karakeep bookmark when it is not created.
+func (i *Importer) bookmarkAction(collection raindrop.Collection, item raindrop.Raindrop) (Action, string) {
+	if record, imported := i.Ledger.Bookmark(item.ID); imported {
+		if record.ListID == "" && i.hasList(collection) {
+			return ActionAddToList, record.KarakeepID
+		}
+		return ActionSkip, record.KarakeepID
+	}
+	if i.BookmarkPolicy.matchesExisting() {
+		if existing, ok := i.existing.bookmark(item.Link); ok {
+			if i.BookmarkPolicy == PolicyUpdate && !existing.imported {
+				return ActionUpdate, existing.id
 			}
+			return ActionReuse, existing.id
 		}
 	}
+	return ActionCreate, ""
+}
+
+// importFunc imports a single bookmark of a collection into the list with
+// the given ID.
+type importFunc func(ctx context.Context, collection raindrop.Collection, item raindrop.Raindrop, listID string) bookmarkOutcome
+
+// importBookmark creates a single bookmark with its highlights and adds it to
+// its list, skipping whichever steps the ledger shows were completed by a
+// previous run and reusing an existing Karakeep bookmark when the policy says
+// so. listID is empty when the collection's list could not be created.
+func (i *Importer) importBookmark(ctx context.Context, collection raindrop.Collection, item raindrop.Raindrop, listID string) bookmarkOutcome {
+	action, bookmarkID := i.bookmarkAction(collection, item)
+	outcome := bookmarkOutcome{action: action}
 
-	fmt.Println("\nImport complete!")
+	switch action {
+	case ActionSkip:
+		return outcome
+	case ActionCreate:
+		bookmark := newBookmark(item)
+		bookmark.Archived = i.archived(collection)
+		if i.highlightsUnsupported.Load() {
+			bookmark.Note = noteWithHighlights(item.Note, item.Highlights)
+		}
+		createdBookmark, err := i.KarakeepClient.CreateBookmark(ctx, bookmark)
+		if err != nil {
+			log.Printf("Failed to create bookmark '%s': %v", item.Title, err)
+			outcome.err = err
+			return outcome
+		}
+		fmt.Printf("  - Created bookmark: %s\n", createdBookmark.Title)
+
+		bookmarkID = createdBookmark.ID
+		i.Ledger.RecordBookmark(item.ID, collection.ID, bookmarkID)
+		outcome.flagsErr = i.ensureFlags(ctx, createdBookmark, bookmark)
+		if i.BookmarkPolicy.matchesExisting() {
+			i.existing.rememberBookmark(item.Link, bookmarkID, true)
+		}
+		if !i.highlightsUnsupported.Load() {
+			outcome.highlightFailures = i.importHighlights(ctx, bookmarkID, item)
+		}
+	case ActionUpdate:
+		if _, err := i.KarakeepClient.UpdateBookmark(ctx, bookmarkID, i.bookmarkUpdate(collection, item)); err != nil {
+			log.Printf("Failed to update bookmark '%s': %v", item.Title, err)
+			outcome.err = err
+			return outcome
+		}
+		fmt.Printf("  - Updated existing bookmark: %s\n", item.Title)
+		i.Ledger.RecordExistingBookmark(item.ID, collection.ID, bookmarkID)
+	case ActionReuse:
+		fmt.Printf("  - Reusing existing bookmark: %s\n", item.Title)
+		i.Ledger.RecordExistingBookmark(item.ID, collection.ID, bookmarkID)
+	}
+
+	if !i.hasList(collection) {
+		return outcome
+	}
+	if listID == "" {
+		outcome.membershipErr = fmt.Errorf("list %q was not created", collection.Title)
+		return outcome
+	}
+
+	// A conflict means the bookmark is already in the list.
+	if err := i.KarakeepClient.AddBookmarkToList(ctx, bookmarkID, listID); err != nil && httpx.Classify(err) != httpx.KindConflict {
+		log.Printf("Failed to add bookmark '%s' to list: %v", item.Title, err)
+		outcome.membershipErr = err
+		return outcome
+	}
+	i.Ledger.RecordMembership(item.ID, listID)
+	return outcome
+}
+
+// bookmarkUpdate returns the update that makes a Karakeep bookmark match a
+// Raindrop bookmark. When Karakeep has no highlights, the note keeps them as
+// when the bookmark was created.
+func (i *Importer) bookmarkUpdate(collection raindrop.Collection, item raindrop.Raindrop) *karakeep.BookmarkUpdate {
+	archived := i.archived(collection)
+	note := item.Note
+	if i.highlightsUnsupported.Load() {
+		note = noteWithHighlights(item.Note, item.Highlights)
+	}
+	return &karakeep.BookmarkUpdate{
+		Title:       &item.Title,
+		Description: &item.Excerpt,
+		Note:        &note,
+		Favourited:  &item.Important,
+		Archived:    &archived,
+	}
+}
+
+// newBookmark converts a Raindrop bookmark into the Karakeep bookmark to create.
+func newBookmark(item raindrop.Raindrop) *karakeep.Bookmark {
+	return &karakeep.Bookmark{
+		URL:         item.Link,
+		Title:       item.Title,
+		Description: item.Excerpt,
+		Note:        item.Note,
+		Tags:        item.Tags,
+		Favourited:  item.Important,
+		CreatedAt:   item.Created,
+	}
+}
+
+// ensureFlags sets the favourite and archived flags of a created bookmark
+// with a separate update when Karakeep did not apply them on creation.
+func (i *Importer) ensureFlags(ctx context.Context, created, requested *karakeep.Bookmark) error {
+	if created.Favourited == requested.Favourited && created.Archived == requested.Archived {
+		return nil
+	}
+	update := &karakeep.BookmarkUpdate{Favourited: &requested.Favourited, Archived: &requested.Archived}
+	if _, err := i.KarakeepClient.UpdateBookmark(ctx, created.ID, update); err != nil {
+		log.Printf("Failed to set favourite and archived flags of bookmark '%s': %v", requested.Title, err)
+		return err
+	}
 	return nil
 }
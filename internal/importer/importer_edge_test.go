@@ -3,6 +3,7 @@
 package importer
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -45,7 +46,7 @@ func TestRunImportWithEmptyData(t *testing.T) {
 
 	importer := NewImporter(raindropClient, karakeepClient)
 
-	err := importer.RunImport()
+	_, err := importer.RunImport(context.Background())
 	if err != nil {
 		t.Fatalf("RunImport failed with empty data: %v", err)
 	}
@@ -89,7 +90,7 @@ func TestRunImportWithNilBookmarks(t *testing.T) {
 
 	importer := NewImporter(raindropClient, karakeepClient)
 
-	err := importer.RunImport()
+	_, err := importer.RunImport(context.Background())
 	if err != nil {
 		t.Fatalf("RunImport failed: %v", err)
 	}
@@ -150,7 +151,7 @@ func TestRunImportWithMissingURLs(t *testing.T) {
 
 	importer := NewImporter(raindropClient, karakeepClient)
 
-	err := importer.RunImport()
+	_, err := importer.RunImport(context.Background())
 	if err != nil {
 		t.Fatalf("RunImport failed: %v", err)
 	}
@@ -214,7 +215,7 @@ func TestRunImportWithVeryLongURLs(t *testing.T) {
 
 	importer := NewImporter(raindropClient, karakeepClient)
 
-	err := importer.RunImport()
+	_, err := importer.RunImport(context.Background())
 	if err != nil {
 		t.Fatalf("RunImport failed: %v", err)
 	}
@@ -283,7 +284,7 @@ func TestRunImportWithSpecialCharacters(t *testing.T) {
 
 	importer := NewImporter(raindropClient, karakeepClient)
 
-	err := importer.RunImport()
+	_, err := importer.RunImport(context.Background())
 	if err != nil {
 		t.Fatalf("RunImport failed: %v", err)
 	}
@@ -354,7 +355,7 @@ func TestRunImportWithUnicode(t *testing.T) {
 
 	importer := NewImporter(raindropClient, karakeepClient)
 
-	err := importer.RunImport()
+	_, err := importer.RunImport(context.Background())
 	if err != nil {
 		t.Fatalf("RunImport failed: %v", err)
 	}
@@ -424,7 +425,7 @@ func TestRunImportWithInvalidURLs(t *testing.T) {
 
 	importer := NewImporter(raindropClient, karakeepClient)
 
-	err := importer.RunImport()
+	_, err := importer.RunImport(context.Background())
 	if err != nil {
 		t.Fatalf("RunImport failed: %v", err)
 	}
@@ -488,7 +489,7 @@ func TestRunImportWithDuplicateBookmarks(t *testing.T) {
 
 	importer := NewImporter(raindropClient, karakeepClient)
 
-	err := importer.RunImport()
+	_, err := importer.RunImport(context.Background())
 	if err != nil {
 		t.Fatalf("RunImport failed: %v", err)
 	}
@@ -540,7 +541,7 @@ func TestRunImportWithDuplicateCollections(t *testing.T) {
 
 	importer := NewImporter(raindropClient, karakeepClient)
 
-	err := importer.RunImport()
+	_, err := importer.RunImport(context.Background())
 	if err != nil {
 		t.Fatalf("RunImport failed: %v", err)
 	}
@@ -624,7 +625,7 @@ func TestRunImportWithLargeDataset(t *testing.T) {
 
 	importer := NewImporter(raindropClient, karakeepClient)
 
-	err := importer.RunImport()
+	_, err := importer.RunImport(context.Background())
 	if err != nil {
 		t.Fatalf("RunImport failed: %v", err)
 	}
@@ -707,7 +708,7 @@ func TestRunImportWithAPIErrors(t *testing.T) {
 
 			importer := NewImporter(raindropClient, karakeepClient)
 
-			err := importer.RunImport()
+			_, err := importer.RunImport(context.Background())
 			if tc.expectError && err == nil {
 				t.Error("Expected error but got none")
 			} else if !tc.expectError && err != nil {
@@ -3,12 +3,18 @@
 package importer
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/ledger"
 	"github.com/ashebanow/rainbridge/internal/raindrop"
 )
 
@@ -64,7 +70,300 @@ func TestRunImport(t *testing.T) {
 
 	importer := NewImporter(raindropClient, karakeepClient)
 
-	if err := importer.RunImport(); err != nil {
+	if _, err := importer.RunImport(context.Background()); err != nil {
 		t.Fatalf("RunImport failed: %v", err)
 	}
 }
+
+func TestRunImportResumesFromLedger(t *testing.T) {
+	raindropServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rest/v1/collections" {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Test Collection"}]}`)
+		} else if r.URL.Path == "/rest/v1/raindrops/1" && r.URL.Query().Get("page") == "0" {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, `{"items": [
+				{"_id": 101, "title": "Done", "link": "https://example.com/done"},
+				{"_id": 102, "title": "Not Listed", "link": "https://example.com/not-listed"},
+				{"_id": 103, "title": "New", "link": "https://example.com/new"}
+			]}`)
+		} else {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, `{"items": []}`)
+		}
+	}))
+	defer raindropServer.Close()
+
+	var listCreations, bookmarkCreations int
+	var memberships []string
+	karakeepServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/lists":
+			listCreations++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "list-new", "name": "Test Collection"}`)
+		case r.URL.Path == "/v1/bookmarks":
+			bookmarkCreations++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "bookmark-103", "title": "New"}`)
+		default:
+			memberships = append(memberships, r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, `{}`)
+		}
+	}))
+	defer karakeepServer.Close()
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	checkpoint, err := ledger.Open(path)
+	if err != nil {
+		t.Fatalf("ledger.Open failed: %v", err)
+	}
+	checkpoint.RecordList(1, "list-123")
+	checkpoint.RecordBookmark(101, 1, "bookmark-101")
+	checkpoint.RecordMembership(101, "list-123")
+	checkpoint.RecordBookmark(102, 1, "bookmark-102")
+
+	raindropClient := raindrop.NewClient("test-token")
+	raindropClient.SetBaseURL(raindropServer.URL + "/rest/v1")
+
+	karakeepClient := karakeep.NewClient("test-token")
+	karakeepClient.SetBaseURL(karakeepServer.URL + "/v1")
+
+	importer := NewImporter(raindropClient, karakeepClient)
+	importer.Ledger = checkpoint
+
+	if _, err := importer.RunImport(context.Background()); err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+
+	if listCreations != 0 {
+		t.Errorf("Expected recorded list to be reused, got %d list creations", listCreations)
+	}
+	if bookmarkCreations != 1 {
+		t.Errorf("Expected only the new bookmark to be created, got %d creations", bookmarkCreations)
+	}
+
+	expected := []string{
+		"/v1/lists/list-123/bookmarks/bookmark-102",
+		"/v1/lists/list-123/bookmarks/bookmark-103",
+	}
+	if strings.Join(memberships, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected memberships %v, got %v", expected, memberships)
+	}
+
+	reopened, err := ledger.Open(path)
+	if err != nil {
+		t.Fatalf("Reopening ledger failed: %v", err)
+	}
+	rec, ok := reopened.Bookmark(103)
+	if !ok || rec.KarakeepID != "bookmark-103" || rec.ListID != "list-123" {
+		t.Errorf("Expected bookmark 103 to be checkpointed, got %+v (found=%v)", rec, ok)
+	}
+}
+
+func TestRunImportCarriesRaindropFields(t *testing.T) {
+	var created karakeep.Bookmark
+	importer := newTestImporter(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/rest/v1/collections" {
+				fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}]}`)
+			} else if r.URL.Path == "/rest/v1/raindrops/1" && r.URL.Query().Get("page") == "0" {
+				fmt.Fprintln(w, `{"items": [{
+					"_id": 101,
+					"title": "Article",
+					"excerpt": "Summary",
+					"note": "My thoughts",
+					"link": "https://example.com/article",
+					"tags": ["go"],
+					"created": "2024-01-15T08:30:00Z"
+				}]}`)
+			} else {
+				fmt.Fprintln(w, `{"items": []}`)
+			}
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v1/lists":
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintln(w, `{"id": "list-1", "name": "Reading"}`)
+			case "/v1/bookmarks":
+				if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+					t.Errorf("Failed to decode bookmark: %v", err)
+				}
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintln(w, `{"id": "bookmark-1"}`)
+			default:
+				fmt.Fprintln(w, `{}`)
+			}
+		},
+	)
+
+	if _, err := importer.RunImport(context.Background()); err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+
+	if created.Description != "Summary" || created.Note != "My thoughts" {
+		t.Errorf("Expected excerpt and note to be carried over, got %+v", created)
+	}
+	if want := time.Date(2024, 1, 15, 8, 30, 0, 0, time.UTC); !created.CreatedAt.Equal(want) {
+		t.Errorf("Expected createdAt %v, got %v", want, created.CreatedAt)
+	}
+}
+
+func TestRunImportSetsFavouriteAndArchived(t *testing.T) {
+	created := make(map[string]karakeep.Bookmark)
+	updates := make(map[string]karakeep.BookmarkUpdate)
+	importer := newTestImporter(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			page := r.URL.Query().Get("page")
+			switch {
+			case r.URL.Path == "/rest/v1/collections":
+				fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}, {"_id": 2, "title": "Old Stuff"}]}`)
+			case r.URL.Path == "/rest/v1/raindrops/1" && page == "0":
+				fmt.Fprintln(w, `{"items": [
+					{"_id": 101, "title": "Starred", "link": "https://example.com/starred", "important": true},
+					{"_id": 102, "title": "Plain", "link": "https://example.com/plain"}
+				]}`)
+			case r.URL.Path == "/rest/v1/raindrops/2" && page == "0":
+				fmt.Fprintln(w, `{"items": [{"_id": 201, "title": "Archived", "link": "https://example.com/archived"}]}`)
+			case r.URL.Path == "/rest/v1/raindrops/-99" && page == "0":
+				fmt.Fprintln(w, `{"items": [{"_id": 301, "title": "Deleted", "link": "https://example.com/deleted"}]}`)
+			default:
+				fmt.Fprintln(w, `{"items": []}`)
+			}
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/v1/lists":
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintln(w, `{"id": "list"}`)
+			case r.URL.Path == "/v1/bookmarks":
+				var bookmark karakeep.Bookmark
+				json.NewDecoder(r.Body).Decode(&bookmark)
+				created[bookmark.Title] = bookmark
+				// Echo the flags back for all but the starred bookmark, to
+				// exercise the fallback update.
+				bookmark.ID = "bookmark-" + bookmark.Title
+				if bookmark.Title == "Starred" {
+					bookmark.Favourited = false
+				}
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(bookmark)
+			case r.Method == "PATCH":
+				var update karakeep.BookmarkUpdate
+				json.NewDecoder(r.Body).Decode(&update)
+				updates[r.URL.Path] = update
+				fmt.Fprintln(w, `{}`)
+			default:
+				fmt.Fprintln(w, `{}`)
+			}
+		},
+	)
+	importer.ImportTrash = true
+	importer.TrashListName = "Raindrop Trash"
+	importer.ArchiveCollections = []string{"old stuff"}
+
+	if _, err := importer.RunImport(context.Background()); err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+
+	expected := map[string][2]bool{
+		"Starred":  {true, false},
+		"Plain":    {false, false},
+		"Archived": {false, true},
+		"Deleted":  {false, true},
+	}
+	for title, flags := range expected {
+		bookmark := created[title]
+		if bookmark.Favourited != flags[0] || bookmark.Archived != flags[1] {
+			t.Errorf("Bookmark %q: expected favourited=%v archived=%v, got %v/%v",
+				title, flags[0], flags[1], bookmark.Favourited, bookmark.Archived)
+		}
+	}
+
+	if len(updates) != 1 {
+		t.Fatalf("Expected only the starred bookmark to be updated, got %v", updates)
+	}
+	update, ok := updates["/v1/bookmarks/bookmark-Starred"]
+	if !ok || update.Favourited == nil || !*update.Favourited || update.Archived == nil || *update.Archived {
+		t.Errorf("Unexpected flag update: %+v", update)
+	}
+}
+
+func TestRunImportReportsFailedFlags(t *testing.T) {
+	patchStatus := http.StatusInternalServerError
+	var patches []karakeep.BookmarkUpdate
+	importer := newTestImporter(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/rest/v1/collections":
+				fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}]}`)
+			case r.URL.Path == "/rest/v1/raindrops/1" && r.URL.Query().Get("page") == "0":
+				fmt.Fprintln(w, `{"items": [{"_id": 101, "title": "Starred", "link": "https://example.com/starred", "important": true}]}`)
+			case r.URL.Path == "/rest/v1/raindrop/101":
+				fmt.Fprintln(w, `{"item": {"_id": 101, "title": "Starred", "link": "https://example.com/starred", "important": true}}`)
+			default:
+				fmt.Fprintln(w, `{"items": []}`)
+			}
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/v1/lists":
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintln(w, `{"id": "list-1"}`)
+			case r.URL.Path == "/v1/bookmarks":
+				// Karakeep ignores the favourite flag on creation.
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintln(w, `{"id": "bookmark-101", "title": "Starred"}`)
+			case r.Method == "PATCH":
+				var update karakeep.BookmarkUpdate
+				json.NewDecoder(r.Body).Decode(&update)
+				patches = append(patches, update)
+				w.WriteHeader(patchStatus)
+				fmt.Fprintln(w, `{}`)
+			default:
+				fmt.Fprintln(w, `{}`)
+			}
+		},
+	)
+	importer.Ledger = ledger.New()
+
+	result, err := importer.RunImport(context.Background())
+	if err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+	if result.FlagFailures != 1 || len(result.Errors) != 1 || result.Errors[0].Stage != FailedFlags || result.Errors[0].RaindropID != 101 {
+		t.Fatalf("Expected the failed flags to be reported, got %+v", result)
+	}
+
+	// Retrying sets the flags of the bookmark that was already created.
+	patchStatus = http.StatusOK
+	patches = nil
+	result, err = importer.RetryFailures(context.Background(), result.Errors)
+	if err != nil {
+		t.Fatalf("RetryFailures failed: %v", err)
+	}
+	if result.HasFailures() || len(patches) != 1 || patches[0].Favourited == nil || !*patches[0].Favourited {
+		t.Errorf("Expected the flags to be set by the retry, got %+v and %+v", result, patches)
+	}
+}
+
+// newTestImporter creates an importer whose clients talk to mock servers. The
+// servers are closed when the test finishes.
+func newTestImporter(t *testing.T, raindropHandler, karakeepHandler http.HandlerFunc) *Importer {
+	t.Helper()
+
+	raindropServer := httptest.NewServer(raindropHandler)
+	t.Cleanup(raindropServer.Close)
+	karakeepServer := httptest.NewServer(karakeepHandler)
+	t.Cleanup(karakeepServer.Close)
+
+	raindropClient := raindrop.NewClient("test-token")
+	raindropClient.SetBaseURL(raindropServer.URL + "/rest/v1")
+
+	karakeepClient := karakeep.NewClient("test-token")
+	karakeepClient.SetBaseURL(karakeepServer.URL + "/v1")
+
+	return NewImporter(raindropClient, karakeepClient)
+}
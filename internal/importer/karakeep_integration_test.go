@@ -3,6 +3,7 @@
 package importer
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -46,7 +47,7 @@ func setupCleanup(t *testing.T, client *karakeep.Client, testPrefix string) {
 func cleanupBookmarks(client *karakeep.Client, testPrefix string) error {
 	log.Println("Cleaning up test bookmarks...")
 	
-	bookmarks, err := client.GetAllBookmarks()
+	bookmarks, err := client.GetAllBookmarks(context.Background())
 	if err != nil {
 		return err
 	}
@@ -56,7 +57,7 @@ func cleanupBookmarks(client *karakeep.Client, testPrefix string) error {
 		// Only delete bookmarks that match our test prefix
 		if strings.HasPrefix(bookmark.Title, testPrefix) {
 			log.Printf("Deleting test bookmark: %s (ID: %s)", bookmark.Title, bookmark.ID)
-			if err := client.DeleteBookmark(bookmark.ID); err != nil {
+			if err := client.DeleteBookmark(context.Background(), bookmark.ID); err != nil {
 				log.Printf("Failed to delete bookmark %s: %v", bookmark.ID, err)
 			} else {
 				deletedCount++
@@ -72,7 +73,7 @@ func cleanupBookmarks(client *karakeep.Client, testPrefix string) error {
 func cleanupLists(client *karakeep.Client, testPrefix string) error {
 	log.Println("Cleaning up test lists...")
 	
-	lists, err := client.GetAllLists()
+	lists, err := client.GetAllLists(context.Background())
 	if err != nil {
 		return err
 	}
@@ -82,7 +83,7 @@ func cleanupLists(client *karakeep.Client, testPrefix string) error {
 		// Only delete lists that match our test prefix
 		if strings.HasPrefix(list.Name, testPrefix) {
 			log.Printf("Deleting test list: %s (ID: %s)", list.Name, list.ID)
-			if err := client.DeleteList(list.ID); err != nil {
+			if err := client.DeleteList(context.Background(), list.ID); err != nil {
 				log.Printf("Failed to delete list %s: %v", list.ID, err)
 			} else {
 				deletedCount++
@@ -126,7 +127,7 @@ func TestKarakeepIntegration(t *testing.T) {
 
 	importer := NewImporter(raindropClient, karakeepClient)
 
-	if err := importer.RunImport(); err != nil {
+	if _, err := importer.RunImport(context.Background()); err != nil {
 		t.Fatalf("RunImport failed: %v", err)
 	}
 }
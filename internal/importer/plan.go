@@ -0,0 +1,302 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+
+	"github.com/ashebanow/rainbridge/internal/ledger"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+// Action describes what an import does with a single list, bookmark or list membership.
+type Action string
+
+const (
+	// ActionCreate means the item will be created in Karakeep.
+	ActionCreate Action = "create"
+	// ActionAddToList means the bookmark already exists in Karakeep but still
+	// has to be added to its list.
+	ActionAddToList Action = "add-to-list"
+	// ActionReuse means an equivalent item already exists in Karakeep and
+	// will be used instead of creating a new one.
+	ActionReuse Action = "reuse"
+	// ActionUpdate means an equivalent bookmark already exists in Karakeep and
+	// will be updated with the Raindrop data.
+	ActionUpdate Action = "update"
+	// ActionSkip means a previous run already imported the item.
+	ActionSkip Action = "skip"
+	// ActionArchive means a sync archived the bookmark because it was
+	// deleted in Raindrop.io.
+	ActionArchive Action = "archive"
+	// ActionDelete means a sync deleted the bookmark because it was deleted
+	// in Raindrop.io.
+	ActionDelete Action = "delete"
+	// ActionMove means a sync moved the bookmark to another list because it
+	// was moved to another collection in Raindrop.io.
+	ActionMove Action = "move"
+)
+
+// ListPlan is the planned action for a Raindrop collection.
+type ListPlan struct {
+	CollectionID       int64  `json:"collectionId"`
+	ParentCollectionID int64  `json:"parentCollectionId,omitempty"`
+	Name               string `json:"name"`
+	Action             Action `json:"action"`
+	ListID             string `json:"listId,omitempty"`
+}
+
+// BookmarkPlan is the planned action for a Raindrop bookmark.
+type BookmarkPlan struct {
+	RaindropID   int64    `json:"raindropId"`
+	CollectionID int64    `json:"collectionId"`
+	URL          string   `json:"url"`
+	Title        string   `json:"title"`
+	Tags         []string `json:"tags,omitempty"`
+	Highlights   int      `json:"highlights,omitempty"`
+	Favourited   bool     `json:"favourited,omitempty"`
+	Archived     bool     `json:"archived,omitempty"`
+	Action       Action   `json:"action"`
+	BookmarkID   string   `json:"bookmarkId,omitempty"`
+}
+
+// MembershipPlan is the planned addition of a bookmark to a Karakeep list.
+type MembershipPlan struct {
+	RaindropID   int64  `json:"raindropId"`
+	CollectionID int64  `json:"collectionId"`
+	ListName     string `json:"listName"`
+	Action       Action `json:"action"`
+}
+
+// PlanCounts summarizes a Plan.
+type PlanCounts struct {
+	ListsToCreate         int `json:"listsToCreate"`
+	ListsToReuse          int `json:"listsToReuse"`
+	ListsToSkip           int `json:"listsToSkip"`
+	BookmarksToCreate     int `json:"bookmarksToCreate"`
+	BookmarksToReuse      int `json:"bookmarksToReuse"`
+	BookmarksToUpdate     int `json:"bookmarksToUpdate"`
+	BookmarksToSkip       int `json:"bookmarksToSkip"`
+	MembershipsToAdd      int `json:"membershipsToAdd"`
+	MembershipsToSkip     int `json:"membershipsToSkip"`
+	HighlightsToCreate    int `json:"highlightsToCreate"`
+	Tags                  int `json:"tags"`
+	CollectionsNotFetched int `json:"collectionsNotFetched"`
+}
+
+// Plan describes everything RunImport would do, without doing any of it.
+type Plan struct {
+	Lists       []ListPlan       `json:"lists"`
+	Bookmarks   []BookmarkPlan   `json:"bookmarks"`
+	Memberships []MembershipPlan `json:"memberships"`
+	Tags        []string         `json:"tags"`
+	Counts      PlanCounts       `json:"counts"`
+}
+
+// BuildPlan fetches everything from Raindrop.io and computes the lists,
+// bookmarks, list memberships and tags that RunImport would create. It reads
+// existing Karakeep lists and bookmarks when the duplicate policies need them,
+// but never writes to Karakeep.
+func (i *Importer) BuildPlan(ctx context.Context) (*Plan, error) {
+	if i.Ledger == nil {
+		i.Ledger = ledger.New()
+	}
+
+	collections, err := i.fetchCollections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collections: %w", err)
+	}
+
+	if err := i.loadExisting(ctx); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Lists:       []ListPlan{},
+		Bookmarks:   []BookmarkPlan{},
+		Memberships: []MembershipPlan{},
+	}
+	tags := make(map[string]struct{})
+
+	for _, collection := range collections {
+		hasList := i.hasList(collection)
+		if hasList {
+			i.planList(plan, collection)
+		}
+
+		raindrops, err := i.RaindropClient.GetRaindropsByCollection(ctx, collection.ID)
+		if err != nil && ctx.Err() != nil {
+			return nil, fmt.Errorf("interrupted: %w", context.Cause(ctx))
+		}
+		if err != nil {
+			log.Printf("Failed to get raindrops for collection '%s': %v", collection.Title, err)
+			plan.Counts.CollectionsNotFetched++
+			continue
+		}
+
+		for _, item := range raindrops {
+			action, bookmarkID := i.bookmarkAction(collection, item)
+
+			bookmarkPlan := BookmarkPlan{
+				RaindropID:   item.ID,
+				CollectionID: collection.ID,
+				URL:          item.Link,
+				Title:        item.Title,
+				Tags:         item.Tags,
+				Highlights:   len(item.Highlights),
+				Favourited:   item.Important,
+				Archived:     i.archived(collection),
+				Action:       action,
+				BookmarkID:   bookmarkID,
+			}
+			membershipAction := ActionCreate
+
+			switch action {
+			case ActionCreate:
+				plan.Counts.BookmarksToCreate++
+				plan.Counts.HighlightsToCreate += len(item.Highlights)
+				if i.BookmarkPolicy.matchesExisting() {
+					i.existing.rememberBookmark(item.Link, "", true)
+				}
+			case ActionReuse:
+				plan.Counts.BookmarksToReuse++
+			case ActionUpdate:
+				plan.Counts.BookmarksToUpdate++
+			case ActionAddToList:
+				bookmarkPlan.Action = ActionSkip
+				plan.Counts.BookmarksToSkip++
+			case ActionSkip:
+				membershipAction = ActionSkip
+				plan.Counts.BookmarksToSkip++
+			}
+			plan.Bookmarks = append(plan.Bookmarks, bookmarkPlan)
+
+			if hasList {
+				if membershipAction == ActionCreate {
+					plan.Counts.MembershipsToAdd++
+				} else {
+					plan.Counts.MembershipsToSkip++
+				}
+				plan.Memberships = append(plan.Memberships, MembershipPlan{
+					RaindropID:   item.ID,
+					CollectionID: collection.ID,
+					ListName:     collection.Title,
+					Action:       membershipAction,
+				})
+			}
+
+			if action == ActionCreate || action == ActionUpdate {
+				for _, tag := range item.Tags {
+					tags[tag] = struct{}{}
+				}
+			}
+		}
+	}
+
+	plan.Tags = make([]string, 0, len(tags))
+	for tag := range tags {
+		plan.Tags = append(plan.Tags, tag)
+	}
+	sort.Strings(plan.Tags)
+	plan.Counts.Tags = len(plan.Tags)
+
+	return plan, nil
+}
+
+// planList adds the planned action for a collection's list. Lists are
+// planned parents-first, so a parent reused by the plan is already known.
+func (i *Importer) planList(plan *Plan, collection raindrop.Collection) {
+	parentID, hasParent := i.parentListID(collection)
+	if !hasParent {
+		parentID, hasParent = plan.listID(collection.ParentID())
+	}
+	action, listID := i.listAction(collection, parentID, hasParent)
+	switch action {
+	case ActionCreate:
+		plan.Counts.ListsToCreate++
+	case ActionReuse:
+		plan.Counts.ListsToReuse++
+	case ActionSkip:
+		plan.Counts.ListsToSkip++
+	}
+	plan.Lists = append(plan.Lists, ListPlan{
+		CollectionID:       collection.ID,
+		ParentCollectionID: collection.ParentID(),
+		Name:               collection.Title,
+		Action:             action,
+		ListID:             listID,
+	})
+}
+
+// listID returns the ID of the existing list planned for a collection.
+func (p *Plan) listID(collectionID int64) (string, bool) {
+	for _, list := range p.Lists {
+		if list.CollectionID == collectionID && list.ListID != "" {
+			return list.ListID, true
+		}
+	}
+	return "", false
+}
+
+// WriteJSON writes the plan as indented JSON.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// Print writes a human-readable version of the plan.
+func (p *Plan) Print(w io.Writer) {
+	fmt.Fprintln(w, "Migration plan (dry run, nothing will be written to Karakeep)")
+	fmt.Fprintf(w, "  Lists:       %d to create, %d existing to reuse, %d already imported\n",
+		p.Counts.ListsToCreate, p.Counts.ListsToReuse, p.Counts.ListsToSkip)
+	fmt.Fprintf(w, "  Bookmarks:   %d to create, %d existing to reuse, %d existing to update, %d already imported\n",
+		p.Counts.BookmarksToCreate, p.Counts.BookmarksToReuse, p.Counts.BookmarksToUpdate, p.Counts.BookmarksToSkip)
+	fmt.Fprintf(w, "  Memberships: %d to add, %d already added\n", p.Counts.MembershipsToAdd, p.Counts.MembershipsToSkip)
+	fmt.Fprintf(w, "  Highlights:  %d to create\n", p.Counts.HighlightsToCreate)
+	fmt.Fprintf(w, "  Tags:        %d\n", p.Counts.Tags)
+	if p.Counts.CollectionsNotFetched > 0 {
+		fmt.Fprintf(w, "  Warning: bookmarks of %d collections could not be fetched\n", p.Counts.CollectionsNotFetched)
+	}
+
+	// Lists are ordered parents-first, so a parent's path is always known
+	// by the time its children are printed.
+	listNames := make(map[int64]string, len(p.Lists))
+	fmt.Fprintln(w, "\nLists:")
+	for _, list := range p.Lists {
+		name := list.Name
+		if parent, ok := listNames[list.ParentCollectionID]; ok {
+			name = parent + " / " + name
+		}
+		listNames[list.CollectionID] = name
+		fmt.Fprintf(w, "  %-11s %s\n", list.Action, name)
+	}
+
+	pendingMemberships := make(map[int64]bool, len(p.Memberships))
+	for _, membership := range p.Memberships {
+		pendingMemberships[membership.RaindropID] = membership.Action == ActionCreate
+	}
+
+	fmt.Fprintln(w, "\nBookmarks:")
+	for _, bookmark := range p.Bookmarks {
+		action := bookmark.Action
+		if action == ActionSkip && pendingMemberships[bookmark.RaindropID] {
+			action = ActionAddToList
+		}
+		listName, ok := listNames[bookmark.CollectionID]
+		if !ok {
+			listName = "no list"
+		}
+		fmt.Fprintf(w, "  %-11s [%s] %s <%s>\n", action, listName, bookmark.Title, bookmark.URL)
+	}
+
+	if len(p.Tags) > 0 {
+		fmt.Fprintln(w, "\nTags:")
+		for _, tag := range p.Tags {
+			fmt.Fprintf(w, "  %s\n", tag)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+//go:build !integration
+
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ashebanow/rainbridge/internal/ledger"
+)
+
+func TestBuildPlan(t *testing.T) {
+	importer := newTestImporter(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/rest/v1/collections":
+				fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}, {"_id": 2, "title": "Recipes"}]}`)
+			case r.URL.Path == "/rest/v1/raindrops/1" && r.URL.Query().Get("page") == "0":
+				fmt.Fprintln(w, `{"items": [
+					{"_id": 101, "title": "Done", "link": "https://example.com/done", "tags": ["old"]},
+					{"_id": 102, "title": "Half Done", "link": "https://example.com/half"},
+					{"_id": 103, "title": "New", "link": "https://example.com/new", "tags": ["go", "reading"]}
+				]}`)
+			case r.URL.Path == "/rest/v1/raindrops/2" && r.URL.Query().Get("page") == "0":
+				fmt.Fprintln(w, `{"items": [{"_id": 201, "title": "Soup", "link": "https://example.com/soup", "tags": ["go"]}]}`)
+			default:
+				fmt.Fprintln(w, `{"items": []}`)
+			}
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("Unexpected request to Karakeep during planning: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+	)
+
+	importer.Ledger = ledger.New()
+	importer.Ledger.RecordList(1, "list-1")
+	importer.Ledger.RecordBookmark(101, 1, "bookmark-101")
+	importer.Ledger.RecordMembership(101, "list-1")
+	importer.Ledger.RecordBookmark(102, 1, "bookmark-102")
+
+	plan, err := importer.BuildPlan(context.Background())
+	if err != nil {
+		t.Fatalf("BuildPlan failed: %v", err)
+	}
+
+	expected := PlanCounts{
+		ListsToCreate:     1,
+		ListsToSkip:       1,
+		BookmarksToCreate: 2,
+		BookmarksToSkip:   2,
+		MembershipsToAdd:  3,
+		MembershipsToSkip: 1,
+		Tags:              2,
+	}
+	if plan.Counts != expected {
+		t.Errorf("Expected counts %+v, got %+v", expected, plan.Counts)
+	}
+
+	if strings.Join(plan.Tags, ",") != "go,reading" {
+		t.Errorf("Expected tags [go reading], got %v", plan.Tags)
+	}
+
+	if plan.Lists[0].Action != ActionSkip || plan.Lists[0].ListID != "list-1" {
+		t.Errorf("Expected recorded list to be skipped, got %+v", plan.Lists[0])
+	}
+	if plan.Lists[1].Action != ActionCreate {
+		t.Errorf("Expected new list to be created, got %+v", plan.Lists[1])
+	}
+	if plan.Memberships[1].Action != ActionCreate || plan.Bookmarks[1].Action != ActionSkip {
+		t.Errorf("Expected half-imported bookmark to only need list membership, got %+v / %+v",
+			plan.Bookmarks[1], plan.Memberships[1])
+	}
+}
+
+func TestPlanOutput(t *testing.T) {
+	plan := &Plan{
+		Lists:       []ListPlan{{CollectionID: 1, Name: "Reading", Action: ActionCreate}},
+		Bookmarks:   []BookmarkPlan{{RaindropID: 101, CollectionID: 1, URL: "https://example.com", Title: "Example", Action: ActionCreate}},
+		Memberships: []MembershipPlan{{RaindropID: 101, CollectionID: 1, ListName: "Reading", Action: ActionCreate}},
+		Tags:        []string{},
+		Counts:      PlanCounts{ListsToCreate: 1, BookmarksToCreate: 1, MembershipsToAdd: 1},
+	}
+
+	var buf bytes.Buffer
+	if err := plan.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	var decoded Plan
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Plan JSON did not decode: %v", err)
+	}
+	if decoded.Counts != plan.Counts || decoded.Bookmarks[0].URL != "https://example.com" {
+		t.Errorf("Plan did not round-trip through JSON: %+v", decoded)
+	}
+
+	buf.Reset()
+	plan.Print(&buf)
+	if !strings.Contains(buf.String(), "create      [Reading] Example <https://example.com>") {
+		t.Errorf("Expected per-item action in printed plan, got:\n%s", buf.String())
+	}
+}
@@ -3,6 +3,7 @@
 package importer
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -42,7 +43,7 @@ func TestRaindropIntegration(t *testing.T) {
 
 	importer := NewImporter(raindropClient, karakeepClient)
 
-	if err := importer.RunImport(); err != nil {
+	if _, err := importer.RunImport(context.Background()); err != nil {
 		t.Fatalf("RunImport failed: %v", err)
 	}
 }
@@ -0,0 +1,220 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ashebanow/rainbridge/internal/httpx"
+	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/ledger"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+// SyncPolicy controls what Sync does with the Karakeep bookmark of a
+// Raindrop.io bookmark that was deleted, or moved to another collection,
+// after it was imported. Bookmarks that were already in Karakeep before the
+// import are left alone when they are deleted, whatever the policy, as
+// Cleanup keeps them too.
+type SyncPolicy string
+
+const (
+	// SyncIgnore leaves the Karakeep bookmark as it is. This is the
+	// behaviour of an Importer created by NewImporter.
+	SyncIgnore SyncPolicy = "ignore"
+	// SyncArchive archives the Karakeep bookmark of a deleted bookmark.
+	SyncArchive SyncPolicy = "archive"
+	// SyncDelete deletes the Karakeep bookmark of a deleted bookmark.
+	SyncDelete SyncPolicy = "delete"
+	// SyncMove moves the Karakeep bookmark of a moved bookmark from the list
+	// of its old collection to the list of its new one.
+	SyncMove SyncPolicy = "move"
+)
+
+// ParseDeletedPolicy parses the policy for deleted bookmarks as accepted on
+// the command line.
+func ParseDeletedPolicy(value string) (SyncPolicy, error) {
+	switch policy := SyncPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case SyncIgnore, SyncArchive, SyncDelete:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid deleted policy %q (expected ignore, archive or delete)", value)
+	}
+}
+
+// ParseMovedPolicy parses the policy for moved bookmarks as accepted on the
+// command line.
+func ParseMovedPolicy(value string) (SyncPolicy, error) {
+	switch policy := SyncPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case SyncIgnore, SyncMove:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid moved policy %q (expected ignore or move)", value)
+	}
+}
+
+// propagateRemovals applies DeletedPolicy to the bookmarks recorded in the
+// ledger. Finding them takes a listing of every bookmark in Raindrop.io, so
+// nothing is fetched unless the policy needs it. A bookmark missing from the
+// listing is only treated as deleted once Raindrop.io confirms that it is
+// gone or in the Trash, so that a listing which changed while it was read
+// deletes nothing.
+func (i *Importer) propagateRemovals(ctx context.Context, collections []raindrop.Collection, result *ImportResult) error {
+	if i.DeletedPolicy != SyncArchive && i.DeletedPolicy != SyncDelete {
+		return nil
+	}
+
+	fmt.Println("\nLooking for bookmarks deleted in Raindrop.io...")
+	current, err := i.listRaindrops(ctx)
+	if err != nil {
+		i.stopOnAuthFailure(err)
+		if ctx.Err() != nil {
+			return i.interrupted(ctx)
+		}
+		return fmt.Errorf("failed to list bookmarks: %w", err)
+	}
+
+	known := make(map[int64]raindrop.Collection, len(collections))
+	for _, collection := range collections {
+		known[collection.ID] = collection
+	}
+	collection := func(id int64) raindrop.Collection {
+		if c, ok := known[id]; ok {
+			return c
+		}
+		return raindrop.Collection{ID: id, Title: fmt.Sprint(id)}
+	}
+
+	records := i.Ledger.Bookmarks()
+	for _, id := range sortedIDs(records) {
+		if ctx.Err() != nil {
+			return i.interrupted(ctx)
+		}
+		record := records[id]
+		_, exists := current[id]
+		if record.Existing || (!exists && record.Removed) {
+			// Bookmarks the import did not create are not touched, and
+			// the others may have been archived by a previous sync.
+			continue
+		}
+		if !exists {
+			exists, err = i.confirmDeleted(ctx, id)
+			if err != nil {
+				if ctx.Err() != nil {
+					return i.interrupted(ctx)
+				}
+				result.addRemoval(collection(record.CollectionID), id, "", err)
+				continue
+			}
+		}
+
+		switch {
+		case !exists && !record.Removed:
+			action, err := i.removeBookmark(context.WithoutCancel(ctx), id, record)
+			i.stopOnAuthFailure(err)
+			result.addRemoval(collection(record.CollectionID), id, action, err)
+		case exists && record.Removed:
+			// The bookmark was restored, and updated by the sync like
+			// any other changed bookmark.
+			i.Ledger.RecordRemoved(id, false)
+		}
+	}
+	return nil
+}
+
+// listRaindrops returns every bookmark in Raindrop.io by ID. Bookmarks in the
+// Trash are only listed when the Trash is imported, so that the others count
+// as deleted.
+func (i *Importer) listRaindrops(ctx context.Context) (map[int64]raindrop.Raindrop, error) {
+	collections := []int64{raindrop.AllCollectionID}
+	if i.ImportTrash {
+		collections = append(collections, raindrop.TrashCollectionID)
+	}
+
+	current := make(map[int64]raindrop.Raindrop)
+	for _, collectionID := range collections {
+		for item, err := range i.RaindropClient.Raindrops(ctx, collectionID, raindrop.PageOptions{}) {
+			if err != nil {
+				return nil, err
+			}
+			current[item.ID] = item
+		}
+	}
+	return current, nil
+}
+
+// confirmDeleted fetches a bookmark that was missing from the listing. It
+// reports whether the bookmark exists after all, outside of a Trash that is
+// not imported.
+func (i *Importer) confirmDeleted(ctx context.Context, raindropID int64) (bool, error) {
+	item, err := i.RaindropClient.GetRaindrop(ctx, raindropID)
+	i.stopOnAuthFailure(err)
+	if httpx.Classify(err) == httpx.KindNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return item.Collection.ID != raindrop.TrashCollectionID || i.ImportTrash, nil
+}
+
+// removeBookmark archives or deletes the Karakeep bookmark of a deleted
+// Raindrop bookmark, as DeletedPolicy says, and returns what was done.
+// Deleted bookmarks are dropped from the ledger.
+func (i *Importer) removeBookmark(ctx context.Context, raindropID int64, record ledger.BookmarkRecord) (Action, error) {
+	if i.DeletedPolicy == SyncDelete {
+		if err := i.KarakeepClient.DeleteBookmark(ctx, record.KarakeepID); err != nil && httpx.Classify(err) != httpx.KindNotFound {
+			log.Printf("Failed to delete bookmark %s: %v", record.KarakeepID, err)
+			return ActionDelete, err
+		}
+		i.Ledger.ForgetBookmark(raindropID)
+		fmt.Printf("  - Deleted bookmark %s\n", record.KarakeepID)
+		return ActionDelete, nil
+	}
+
+	archived := true
+	_, err := i.KarakeepClient.UpdateBookmark(ctx, record.KarakeepID, &karakeep.BookmarkUpdate{Archived: &archived})
+	if httpx.Classify(err) == httpx.KindNotFound {
+		// The Karakeep bookmark is gone as well.
+		i.Ledger.ForgetBookmark(raindropID)
+		return ActionArchive, nil
+	}
+	if err != nil {
+		log.Printf("Failed to archive bookmark %s: %v", record.KarakeepID, err)
+		return ActionArchive, err
+	}
+	i.Ledger.RecordRemoved(raindropID, true)
+	fmt.Printf("  - Archived bookmark %s\n", record.KarakeepID)
+	return ActionArchive, nil
+}
+
+// moveBookmark moves the Karakeep bookmark of a Raindrop bookmark that was
+// moved to another collection out of the list it was added to, and into the
+// list of its new collection if that was imported.
+func (i *Importer) moveBookmark(ctx context.Context, item raindrop.Raindrop, record ledger.BookmarkRecord) error {
+	if record.ListID != "" {
+		err := i.KarakeepClient.RemoveBookmarkFromList(ctx, record.KarakeepID, record.ListID)
+		if err != nil && httpx.Classify(err) != httpx.KindNotFound {
+			log.Printf("Failed to remove bookmark '%s' from its list: %v", item.Title, err)
+			return err
+		}
+	}
+
+	// The move is only recorded once it is complete, so that a failed one is
+	// tried again by the next sync.
+	listID, hasList := i.Ledger.ListID(item.Collection.ID)
+	if hasList {
+		err := i.KarakeepClient.AddBookmarkToList(ctx, record.KarakeepID, listID)
+		if err != nil && httpx.Classify(err) != httpx.KindConflict {
+			log.Printf("Failed to add bookmark '%s' to its new list: %v", item.Title, err)
+			return err
+		}
+	}
+	i.Ledger.RecordMove(item.ID, item.Collection.ID)
+	if hasList {
+		i.Ledger.RecordMembership(item.ID, listID)
+	}
+	fmt.Printf("  - Moved bookmark: %s\n", item.Title)
+	return nil
+}
@@ -0,0 +1,77 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WriteFailureReport writes failures to path as JSON Lines, one ItemError per
+// line, replacing any previous report. When there are no failures the
+// previous report is removed, so that a later retry does not repeat work
+// that has since succeeded.
+func WriteFailureReport(path string, failures []ItemError) error {
+	if len(failures) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove failure report: %w", err)
+		}
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write failure report: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	for _, failure := range failures {
+		if err := encoder.Encode(failure); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to write failure report: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write failure report: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write failure report: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write failure report: %w", err)
+	}
+	return nil
+}
+
+// ReadFailureReport reads a report written by WriteFailureReport.
+func ReadFailureReport(path string) ([]ItemError, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read failure report: %w", err)
+	}
+	defer file.Close()
+
+	var failures []ItemError
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var failure ItemError
+		if err := json.Unmarshal(scanner.Bytes(), &failure); err != nil {
+			return nil, fmt.Errorf("failed to read failure report: line %d: %w", line, err)
+		}
+		failures = append(failures, failure)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read failure report: %w", err)
+	}
+	return failures, nil
+}
@@ -0,0 +1,173 @@
+//go:build !integration
+
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ashebanow/rainbridge/internal/httpx"
+	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/ledger"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+func TestFailureReportRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failures.jsonl")
+	failures := []ItemError{
+		{Stage: FailedList, CollectionID: 2, CollectionTitle: "Work", Status: 500, Error: "failed to create list: 500 Internal Server Error"},
+		{Stage: FailedHighlight, CollectionID: 1, CollectionTitle: "Reading", RaindropID: 101, HighlightID: "h2", Title: "Article", URL: "https://example.com", Error: "boom"},
+	}
+
+	if err := WriteFailureReport(path, failures); err != nil {
+		t.Fatalf("WriteFailureReport failed: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); len(lines) != 2 {
+		t.Errorf("Expected one line per failure, got:\n%s", content)
+	}
+
+	read, err := ReadFailureReport(path)
+	if err != nil {
+		t.Fatalf("ReadFailureReport failed: %v", err)
+	}
+	if !reflect.DeepEqual(read, failures) {
+		t.Errorf("Expected %+v, got %+v", failures, read)
+	}
+
+	if err := WriteFailureReport(path, nil); err != nil {
+		t.Fatalf("WriteFailureReport failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected an empty report to remove the file, got %v", err)
+	}
+	if err := WriteFailureReport(path, nil); err != nil {
+		t.Errorf("Expected removing a missing report to succeed, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("{\"stage\": \"bookmark\"}\nnot json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFailureReport(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error naming the bad line, got %v", err)
+	}
+}
+
+func TestRetryFailures(t *testing.T) {
+	raindropServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}, {"_id": 2, "title": "Work"}]}`)
+		case "/rest/v1/raindrop/101":
+			fmt.Fprintln(w, `{"item": {"_id": 101, "title": "Article", "link": "https://example.com/article",
+				"highlights": [{"_id": "h1", "text": "first"}, {"_id": "h2", "text": "second"}]}}`)
+		case "/rest/v1/raindrop/201":
+			fmt.Fprintln(w, `{"item": {"_id": 201, "title": "Spec", "link": "https://example.com/spec"}}`)
+		case "/rest/v1/raindrop/202":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintln(w, `{"result": false}`)
+		default:
+			t.Errorf("Unexpected Raindrop request: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer raindropServer.Close()
+
+	var requests []string
+	karakeepServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/lists":
+			requests = append(requests, "create list")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "list-2", "name": "Work"}`)
+		case "/v1/highlights":
+			var highlight karakeep.Highlight
+			json.NewDecoder(r.Body).Decode(&highlight)
+			requests = append(requests, "highlight "+highlight.Text+" on "+highlight.BookmarkID)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "highlight-1"}`)
+		default:
+			requests = append(requests, r.Method+" "+r.URL.Path)
+			fmt.Fprintln(w, `{}`)
+		}
+	}))
+	defer karakeepServer.Close()
+
+	checkpoint := ledger.New()
+	checkpoint.RecordList(1, "list-1")
+	checkpoint.RecordBookmark(101, 1, "bookmark-101")
+	checkpoint.RecordMembership(101, "list-1")
+	checkpoint.RecordBookmark(201, 2, "bookmark-201")
+
+	raindropClient := raindrop.NewClient("test-token")
+	raindropClient.SetBaseURL(raindropServer.URL + "/rest/v1")
+	karakeepClient := karakeep.NewClient("test-token")
+	karakeepClient.SetBaseURL(karakeepServer.URL + "/v1")
+	importer := NewImporter(raindropClient, karakeepClient)
+	importer.Ledger = checkpoint
+
+	result, err := importer.RetryFailures(context.Background(), []ItemError{
+		{Stage: FailedHighlight, CollectionID: 1, RaindropID: 101, HighlightID: "h2"},
+		{Stage: FailedList, CollectionID: 2},
+		{Stage: FailedMembership, CollectionID: 2, RaindropID: 201},
+		{Stage: FailedBookmark, CollectionID: 2, RaindropID: 202},
+		{Stage: FailedBookmark, CollectionID: 3, RaindropID: 301},
+	})
+	if err != nil {
+		t.Fatalf("RetryFailures failed: %v", err)
+	}
+	if result.HasFailures() {
+		t.Errorf("Expected no failures, got %+v", result.Errors)
+	}
+
+	expected := []string{
+		"create list",
+		"highlight second on bookmark-101",
+		"POST /v1/lists/list-2/bookmarks/bookmark-201",
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("Expected requests %v, got %v", expected, requests)
+	}
+	if record, _ := checkpoint.Bookmark(201); record.ListID != "list-2" {
+		t.Errorf("Expected the membership to be recorded, got %+v", record)
+	}
+}
+
+func TestRetryFailuresKeepsValidationFailures(t *testing.T) {
+	importer := newTestImporter(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/rest/v1/collections" {
+				t.Errorf("Unexpected Raindrop request: %s", r.URL.Path)
+			}
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}]}`)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("Unexpected Karakeep request: %s %s", r.Method, r.URL.Path)
+		},
+	)
+	importer.Ledger = ledger.New()
+	importer.Ledger.RecordList(1, "list-1")
+
+	invalid := ItemError{Stage: FailedBookmark, CollectionID: 1, CollectionTitle: "Reading", RaindropID: 101,
+		Status: http.StatusBadRequest, Error: "failed to create bookmark: 400 Bad Request", Kind: httpx.KindValidation}
+	result, err := importer.RetryFailures(context.Background(), []ItemError{invalid})
+	if err != nil {
+		t.Fatalf("RetryFailures failed: %v", err)
+	}
+
+	// The failure is not retried, but stays in the report.
+	if result.BookmarksFailed != 1 || !reflect.DeepEqual(result.Errors, []ItemError{invalid}) {
+		t.Errorf("Expected the validation failure to be kept, got %+v", result)
+	}
+}
@@ -0,0 +1,373 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ashebanow/rainbridge/internal/httpx"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+// Stages of the import at which an ItemError can occur.
+const (
+	// FailedList means the Karakeep list for a collection could not be created.
+	FailedList = "list"
+	// FailedCollection means the bookmarks of a collection could not be fetched.
+	FailedCollection = "collection"
+	// FailedBookmark means a bookmark could not be created or updated.
+	FailedBookmark = "bookmark"
+	// FailedMembership means a bookmark could not be added to its list.
+	FailedMembership = "membership"
+	// FailedHighlight means a highlight could not be created.
+	FailedHighlight = "highlight"
+	// FailedFlags means the favourite and archived flags of a created
+	// bookmark could not be set.
+	FailedFlags = "flags"
+	// FailedRemoval means a sync could not archive, delete or move a
+	// bookmark that was deleted or moved in Raindrop.io. The next sync tries
+	// again, so import --retry-failed skips these.
+	FailedRemoval = "removal"
+)
+
+// ItemError describes a single list, collection, bookmark, list membership
+// or highlight that failed to import. It is also the record format of the
+// failure report.
+type ItemError struct {
+	Stage           string `json:"stage"`
+	CollectionID    int64  `json:"collectionId"`
+	CollectionTitle string `json:"collectionTitle"`
+	RaindropID      int64  `json:"raindropId,omitempty"`
+	HighlightID     string `json:"highlightId,omitempty"`
+	Title           string `json:"title,omitempty"`
+	URL             string `json:"url,omitempty"`
+	// Status is the HTTP status of the failed request, or 0 when the request
+	// did not get a response.
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error"`
+	// Kind classifies the failure, such as "validation" or "transient".
+	Kind httpx.Kind `json:"kind,omitempty"`
+	// Detail is the explanation the server gave for the failure, if any.
+	Detail string `json:"detail,omitempty"`
+}
+
+// newItemError describes err, a failure at stage of the given collection.
+func newItemError(stage string, collection raindrop.Collection, err error) ItemError {
+	e := ItemError{
+		Stage:           stage,
+		CollectionID:    collection.ID,
+		CollectionTitle: collection.Title,
+		Error:           err.Error(),
+		Kind:            httpx.Classify(err),
+	}
+	var apiErr *httpx.APIError
+	if errors.As(err, &apiErr) {
+		e.Status = apiErr.StatusCode
+		e.Detail = apiErr.Body
+	}
+	return e
+}
+
+// CollectionResult counts what happened to the bookmarks of one collection.
+type CollectionResult struct {
+	CollectionID int64
+	Title        string
+	// ListAction is what happened to the collection's list, or "" if the
+	// collection has no list. It is "failed" if the list could not be created.
+	ListAction Action
+	// FetchFailed is set when not all of the collection's bookmarks could be
+	// fetched. The ones that were fetched are imported anyway.
+	FetchFailed bool
+
+	BookmarksCreated   int
+	BookmarksReused    int
+	BookmarksUpdated   int
+	BookmarksSkipped   int
+	BookmarksFailed    int
+	MembershipFailures int
+	HighlightFailures  int
+	FlagFailures       int
+}
+
+// ImportResult summarizes an import.
+type ImportResult struct {
+	ListsCreated int
+	ListsReused  int
+	ListsSkipped int
+	ListsFailed  int
+
+	BookmarksCreated int
+	BookmarksReused  int
+	BookmarksUpdated int
+	BookmarksSkipped int
+	BookmarksFailed  int
+
+	MembershipFailures    int
+	HighlightFailures     int
+	FlagFailures          int
+	CollectionsNotFetched int
+
+	// BookmarksArchived, BookmarksDeleted and BookmarksMoved count the
+	// bookmarks a sync archived, deleted or moved because they were deleted
+	// or moved in Raindrop.io.
+	BookmarksArchived int
+	BookmarksDeleted  int
+	BookmarksMoved    int
+	RemovalFailures   int
+
+	// Collections has one entry per imported collection, in import order.
+	Collections []*CollectionResult
+	// Errors lists every failure, in the order they occurred.
+	Errors []ItemError
+
+	Elapsed time.Duration
+}
+
+// listFailed is the CollectionResult.ListAction of a list that could not be created.
+const listFailed Action = "failed"
+
+// Failures returns the number of failed lists, collections, bookmarks,
+// list memberships, highlights, flags and removals.
+func (r *ImportResult) Failures() int {
+	return r.ListsFailed + r.CollectionsNotFetched + r.BookmarksFailed + r.MembershipFailures + r.HighlightFailures + r.FlagFailures + r.RemovalFailures
+}
+
+// HasFailures reports whether anything failed to import.
+func (r *ImportResult) HasFailures() bool {
+	return r.Failures() > 0
+}
+
+// collection returns the result for a collection, adding it if needed.
+func (r *ImportResult) collection(collection raindrop.Collection) *CollectionResult {
+	for _, c := range r.Collections {
+		if c.CollectionID == collection.ID {
+			return c
+		}
+	}
+	c := &CollectionResult{CollectionID: collection.ID, Title: collection.Title}
+	r.Collections = append(r.Collections, c)
+	return c
+}
+
+// addList records what happened to a collection's list.
+func (r *ImportResult) addList(collection raindrop.Collection, action Action, err error) {
+	c := r.collection(collection)
+	if err != nil {
+		c.ListAction = listFailed
+		r.ListsFailed++
+		r.Errors = append(r.Errors, newItemError(FailedList, collection, err))
+		return
+	}
+
+	c.ListAction = action
+	switch action {
+	case ActionCreate:
+		r.ListsCreated++
+	case ActionReuse:
+		r.ListsReused++
+	case ActionSkip:
+		r.ListsSkipped++
+	}
+}
+
+// addFetchFailure records that a collection's bookmarks could not be fetched.
+func (r *ImportResult) addFetchFailure(collection raindrop.Collection, err error) {
+	r.collection(collection).FetchFailed = true
+	r.CollectionsNotFetched++
+	r.Errors = append(r.Errors, newItemError(FailedCollection, collection, err))
+}
+
+// addNotRetried records a failure from a failure report that was not
+// retried, so that it stays in the report.
+func (r *ImportResult) addNotRetried(failure ItemError) {
+	c := r.collection(raindrop.Collection{ID: failure.CollectionID, Title: failure.CollectionTitle})
+	switch failure.Stage {
+	case FailedList:
+		c.ListAction = listFailed
+		r.ListsFailed++
+	case FailedCollection:
+		c.FetchFailed = true
+		r.CollectionsNotFetched++
+	case FailedBookmark:
+		c.BookmarksFailed++
+		r.BookmarksFailed++
+	case FailedMembership:
+		c.MembershipFailures++
+		r.MembershipFailures++
+	case FailedHighlight:
+		c.HighlightFailures++
+		r.HighlightFailures++
+	case FailedFlags:
+		c.FlagFailures++
+		r.FlagFailures++
+	}
+	r.Errors = append(r.Errors, failure)
+}
+
+// bookmarkOutcome is what happened when importing a single bookmark.
+type bookmarkOutcome struct {
+	action Action
+	// err is set when the bookmark could not be created or updated.
+	err error
+	// membershipErr is set when the bookmark could not be added to its list.
+	membershipErr error
+	// highlightFailures holds the highlights that could not be created.
+	highlightFailures []highlightFailure
+	// flagsErr is set when the flags of a created bookmark could not be set.
+	flagsErr error
+	// moved is set when a sync moved the bookmark to the list of its new
+	// collection, and moveErr when that failed.
+	moved   bool
+	moveErr error
+}
+
+// errs returns every error of the outcome.
+func (o bookmarkOutcome) errs() []error {
+	errs := []error{o.err, o.membershipErr, o.flagsErr, o.moveErr}
+	for _, failure := range o.highlightFailures {
+		errs = append(errs, failure.err)
+	}
+	return errs
+}
+
+// highlightFailure is a Raindrop highlight that could not be created.
+type highlightFailure struct {
+	id  string
+	err error
+}
+
+// addBookmark records the outcome of importing a bookmark.
+func (r *ImportResult) addBookmark(collection raindrop.Collection, item raindrop.Raindrop, outcome bookmarkOutcome) {
+	c := r.collection(collection)
+	itemError := func(stage string, err error) ItemError {
+		e := newItemError(stage, collection, err)
+		e.RaindropID = item.ID
+		e.Title = item.Title
+		e.URL = item.Link
+		return e
+	}
+
+	if outcome.err != nil {
+		c.BookmarksFailed++
+		r.BookmarksFailed++
+		r.Errors = append(r.Errors, itemError(FailedBookmark, outcome.err))
+		return
+	}
+
+	switch outcome.action {
+	case ActionCreate:
+		c.BookmarksCreated++
+		r.BookmarksCreated++
+	case ActionReuse:
+		c.BookmarksReused++
+		r.BookmarksReused++
+	case ActionUpdate:
+		c.BookmarksUpdated++
+		r.BookmarksUpdated++
+	case ActionSkip, ActionAddToList:
+		c.BookmarksSkipped++
+		r.BookmarksSkipped++
+	}
+
+	if outcome.moved || outcome.moveErr != nil {
+		r.addRemoval(collection, item.ID, ActionMove, outcome.moveErr)
+	}
+	if outcome.membershipErr != nil {
+		c.MembershipFailures++
+		r.MembershipFailures++
+		r.Errors = append(r.Errors, itemError(FailedMembership, outcome.membershipErr))
+	}
+	if outcome.flagsErr != nil {
+		c.FlagFailures++
+		r.FlagFailures++
+		r.Errors = append(r.Errors, itemError(FailedFlags, outcome.flagsErr))
+	}
+	for _, failure := range outcome.highlightFailures {
+		c.HighlightFailures++
+		r.HighlightFailures++
+		e := itemError(FailedHighlight, failure.err)
+		e.HighlightID = failure.id
+		r.Errors = append(r.Errors, e)
+	}
+}
+
+// addRemoval records what a sync did with a bookmark that was deleted in
+// Raindrop.io or moved there from another collection.
+func (r *ImportResult) addRemoval(collection raindrop.Collection, raindropID int64, action Action, err error) {
+	if err != nil {
+		r.RemovalFailures++
+		e := newItemError(FailedRemoval, collection, err)
+		e.RaindropID = raindropID
+		r.Errors = append(r.Errors, e)
+		return
+	}
+
+	switch action {
+	case ActionArchive:
+		r.BookmarksArchived++
+	case ActionDelete:
+		r.BookmarksDeleted++
+	case ActionMove:
+		r.BookmarksMoved++
+	}
+}
+
+// PrintSummary writes a table of per-collection counts followed by the totals
+// and every failure.
+func (r *ImportResult) PrintSummary(w io.Writer) {
+	fmt.Fprintf(w, "\nImport summary (%s)\n\n", r.Elapsed.Round(time.Second))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Collection\tList\tCreated\tReused\tUpdated\tSkipped\tFailed\tNot listed\t")
+	for _, c := range r.Collections {
+		list := string(c.ListAction)
+		if list == "" {
+			list = "-"
+		}
+		failed := fmt.Sprint(c.BookmarksFailed)
+		if c.FetchFailed {
+			failed = "not fetched"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t%d\t\n", c.Title, list,
+			c.BookmarksCreated, c.BookmarksReused, c.BookmarksUpdated, c.BookmarksSkipped, failed, c.MembershipFailures)
+	}
+	fmt.Fprintf(tw, "Total\t\t%d\t%d\t%d\t%d\t%d\t%d\t\n",
+		r.BookmarksCreated, r.BookmarksReused, r.BookmarksUpdated, r.BookmarksSkipped, r.BookmarksFailed, r.MembershipFailures)
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nLists: %d created, %d reused, %d already imported, %d failed\n",
+		r.ListsCreated, r.ListsReused, r.ListsSkipped, r.ListsFailed)
+	if r.HighlightFailures > 0 {
+		fmt.Fprintf(w, "Highlights: %d failed\n", r.HighlightFailures)
+	}
+	if r.FlagFailures > 0 {
+		fmt.Fprintf(w, "Favourite and archived flags: %d failed\n", r.FlagFailures)
+	}
+	if r.BookmarksArchived+r.BookmarksDeleted+r.BookmarksMoved+r.RemovalFailures > 0 {
+		fmt.Fprintf(w, "Deleted or moved in Raindrop.io: %d archived, %d deleted, %d moved, %d failed\n",
+			r.BookmarksArchived, r.BookmarksDeleted, r.BookmarksMoved, r.RemovalFailures)
+	}
+
+	if len(r.Errors) == 0 {
+		fmt.Fprintln(w, "No failures.")
+		return
+	}
+
+	fmt.Fprintf(w, "\n%d failures:\n", len(r.Errors))
+	for _, e := range r.Errors {
+		message := e.Error
+		if e.Detail != "" {
+			message += " (" + e.Detail + ")"
+		}
+		switch e.Stage {
+		case FailedList, FailedCollection:
+			fmt.Fprintf(w, "  %-10s %s: %s\n", e.Stage, e.CollectionTitle, message)
+		case FailedRemoval:
+			fmt.Fprintf(w, "  %-10s bookmark %d in %s: %s\n", e.Stage, e.RaindropID, e.CollectionTitle, message)
+		default:
+			fmt.Fprintf(w, "  %-10s %s <%s> in %s: %s\n", e.Stage, e.Title, e.URL, e.CollectionTitle, message)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+//go:build !integration
+
+package importer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ashebanow/rainbridge/internal/httpx"
+	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+func TestRunImportReturnsResult(t *testing.T) {
+	raindropServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Works"}, {"_id": 2, "title": "Broken"}, {"_id": 3, "title": "Unreadable"}]}`)
+		case r.URL.Query().Get("page") != "0":
+			fmt.Fprintln(w, `{"items": []}`)
+		case r.URL.Path == "/rest/v1/raindrops/1":
+			fmt.Fprintln(w, `{"items": [{"_id": 101, "title": "Good", "link": "https://good.example.com"}, {"_id": 102, "title": "Bad", "link": "https://bad.example.com"}]}`)
+		case r.URL.Path == "/rest/v1/raindrops/2":
+			fmt.Fprintln(w, `{"items": [{"_id": 201, "title": "Orphan", "link": "https://orphan.example.com"}]}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer raindropServer.Close()
+
+	karakeepServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/v1/lists":
+			if strings.Contains(string(body), "Broken") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "list-1", "name": "Works"}`)
+		case "/v1/bookmarks":
+			if strings.Contains(string(body), "bad.example.com") {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintln(w, `{"code": "BAD_REQUEST", "message": "URL is too long"}`)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "bookmark-1"}`)
+		default:
+			fmt.Fprintln(w, `{}`)
+		}
+	}))
+	defer karakeepServer.Close()
+
+	raindropClient := raindrop.NewClient("test-token")
+	raindropClient.SetBaseURL(raindropServer.URL + "/rest/v1")
+	karakeepClient := karakeep.NewClient("test-token")
+	karakeepClient.SetBaseURL(karakeepServer.URL + "/v1")
+
+	result, err := NewImporter(raindropClient, karakeepClient).RunImport(context.Background())
+	if err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+
+	if result.ListsCreated != 2 || result.ListsFailed != 1 {
+		t.Errorf("Expected 2 lists created and 1 failed, got %d and %d", result.ListsCreated, result.ListsFailed)
+	}
+	if result.BookmarksCreated != 2 || result.BookmarksFailed != 1 {
+		t.Errorf("Expected 2 bookmarks created and 1 failed, got %d and %d", result.BookmarksCreated, result.BookmarksFailed)
+	}
+	if result.MembershipFailures != 1 {
+		t.Errorf("Expected the bookmark of the failed list to be a membership failure, got %d", result.MembershipFailures)
+	}
+	if result.CollectionsNotFetched != 1 {
+		t.Errorf("Expected 1 collection not fetched, got %d", result.CollectionsNotFetched)
+	}
+	if !result.HasFailures() || result.Failures() != 4 || len(result.Errors) != 4 {
+		t.Fatalf("Expected 4 failures, got %d: %+v", result.Failures(), result.Errors)
+	}
+
+	kinds := make([]string, len(result.Errors))
+	for n, e := range result.Errors {
+		kinds[n] = e.Stage
+	}
+	if got := strings.Join(kinds, ","); got != "list,bookmark,membership,collection" {
+		t.Errorf("Unexpected failure order: %s", got)
+	}
+	if e := result.Errors[1]; e.RaindropID != 102 || e.URL != "https://bad.example.com" || e.CollectionTitle != "Works" {
+		t.Errorf("Unexpected bookmark failure: %+v", e)
+	}
+	if e := result.Errors[1]; e.Status != http.StatusBadRequest || e.Kind != httpx.KindValidation || e.Detail != "URL is too long" {
+		t.Errorf("Expected the bookmark failure to carry the server's explanation, got %+v", e)
+	}
+	if e := result.Errors[0]; e.Kind != httpx.KindTransient {
+		t.Errorf("Expected the list failure to be transient, got %+v", e)
+	}
+
+	if len(result.Collections) != 3 {
+		t.Fatalf("Expected 3 collection results, got %d", len(result.Collections))
+	}
+	if c := result.Collections[0]; c.ListAction != ActionCreate || c.BookmarksCreated != 1 || c.BookmarksFailed != 1 {
+		t.Errorf("Unexpected result for the first collection: %+v", c)
+	}
+	if c := result.Collections[1]; c.ListAction != listFailed || c.MembershipFailures != 1 {
+		t.Errorf("Unexpected result for the second collection: %+v", c)
+	}
+	if !result.Collections[2].FetchFailed {
+		t.Errorf("Expected the third collection to be marked as not fetched")
+	}
+}
+
+func TestPrintSummary(t *testing.T) {
+	result := &ImportResult{}
+	works := raindrop.Collection{ID: 1, Title: "Works"}
+	result.addList(works, ActionCreate, nil)
+	result.addBookmark(works, raindrop.Raindrop{ID: 101}, bookmarkOutcome{action: ActionCreate})
+	result.addBookmark(works, raindrop.Raindrop{ID: 102}, bookmarkOutcome{action: ActionSkip})
+
+	var out bytes.Buffer
+	result.PrintSummary(&out)
+	for _, expected := range []string{"Collection", "Works", "Total", "1 created", "No failures."} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected summary to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+
+	result.addBookmark(works, raindrop.Raindrop{ID: 103, Title: "Bad", Link: "https://bad.example.com"},
+		bookmarkOutcome{action: ActionCreate, err: fmt.Errorf("failed to create bookmark: boom")})
+	out.Reset()
+	result.PrintSummary(&out)
+	if !strings.Contains(out.String(), "bookmark   Bad <https://bad.example.com> in Works: failed to create bookmark: boom") {
+		t.Errorf("Expected the failure to be listed, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,207 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/ashebanow/rainbridge/internal/httpx"
+	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/ledger"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+// collectionRetry is the work left to do for one collection of a failure report.
+type collectionRetry struct {
+	// all is set when the collection's bookmarks could not be fetched, so the
+	// whole collection is imported again.
+	all bool
+	// bookmarks holds the failed bookmarks in report order, with the IDs of
+	// their failed highlights and whether their flags failed.
+	bookmarks  []raindrop.Raindrop
+	highlights map[int64][]string
+	flags      map[int64]bool
+}
+
+// RetryFailures re-attempts only the items in failures, as read from a
+// failure report. Missing lists are created first, then each failed bookmark
+// is fetched again from Raindrop.io and imported with the same steps as
+// RunImport, so the ledger decides whether it still has to be created, added
+// to its list or only needs its highlights. Bookmarks and collections that no
+// longer exist in Raindrop.io are dropped. Validation failures are not
+// retried, as they would fail the same way, but are kept in the result.
+// Cancelling ctx, or a rejected token, stops the retry as it does RunImport.
+func (i *Importer) RetryFailures(ctx context.Context, failures []ItemError) (*ImportResult, error) {
+	ctx, done := i.begin(ctx)
+	defer done()
+	start := time.Now()
+	result := &ImportResult{}
+	defer func() { result.Elapsed = time.Since(start) }()
+
+	if i.Ledger == nil {
+		i.Ledger = ledger.New()
+	}
+
+	retries := make(map[int64]*collectionRetry)
+	notRetried := 0
+	for _, failure := range failures {
+		if failure.Stage == FailedRemoval {
+			// The next sync tries these again.
+			continue
+		}
+		if failure.Kind == httpx.KindValidation {
+			// Karakeep rejected the item itself, so it would fail the same
+			// way again. It stays in the report until it is fixed by hand.
+			result.addNotRetried(failure)
+			notRetried++
+			continue
+		}
+		retry := retries[failure.CollectionID]
+		if retry == nil {
+			retry = &collectionRetry{highlights: make(map[int64][]string), flags: make(map[int64]bool)}
+			retries[failure.CollectionID] = retry
+		}
+
+		switch failure.Stage {
+		case FailedCollection:
+			retry.all = true
+		case FailedBookmark, FailedMembership, FailedHighlight, FailedFlags:
+			if !slices.ContainsFunc(retry.bookmarks, func(r raindrop.Raindrop) bool { return r.ID == failure.RaindropID }) {
+				retry.bookmarks = append(retry.bookmarks, raindrop.Raindrop{ID: failure.RaindropID, Title: failure.Title, Link: failure.URL})
+			}
+			if failure.Stage == FailedHighlight {
+				retry.highlights[failure.RaindropID] = append(retry.highlights[failure.RaindropID], failure.HighlightID)
+			}
+			if failure.Stage == FailedFlags {
+				retry.flags[failure.RaindropID] = true
+			}
+		}
+	}
+
+	if notRetried > 0 {
+		fmt.Printf("Not retrying %d failures that Karakeep rejected as invalid\n", notRetried)
+	}
+
+	fmt.Println("Fetching collections from Raindrop.io...")
+	collections, err := i.fetchCollections(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to get collections: %w", err)
+	}
+
+	if err := i.loadExisting(ctx); err != nil {
+		return result, err
+	}
+
+	// Collections are in import order, so parent lists are created before
+	// their children.
+	var retried []raindrop.Collection
+	found := make(map[int64]bool)
+	for _, collection := range collections {
+		if retries[collection.ID] != nil {
+			retried = append(retried, collection)
+			found[collection.ID] = true
+		}
+	}
+	for _, id := range sortedIDs(retries) {
+		if !found[id] {
+			fmt.Printf("Collection %d no longer exists in Raindrop.io or is not imported, skipping its failures\n", id)
+		}
+	}
+
+	fmt.Printf("Retrying failures in %d collections...\n", len(retried))
+	for _, collection := range retried {
+		if ctx.Err() != nil {
+			return result, i.interrupted(ctx)
+		}
+		result.collection(collection)
+		if _, ok := i.Ledger.ListID(collection.ID); !ok && i.hasList(collection) {
+			i.createList(context.WithoutCancel(ctx), collection, result)
+		}
+	}
+	if err := i.Ledger.Save(); err != nil {
+		return result, fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	for _, collection := range retried {
+		if ctx.Err() != nil {
+			return result, i.interrupted(ctx)
+		}
+		retry := retries[collection.ID]
+		if retry.all {
+			if err := i.importCollection(ctx, collection, result); err != nil {
+				return result, err
+			}
+			continue
+		}
+		if len(retry.bookmarks) == 0 {
+			continue
+		}
+
+		fmt.Printf("\nRetrying %d bookmarks in collection: %s\n", len(retry.bookmarks), collection.Title)
+		listID, _ := i.Ledger.ListID(collection.ID)
+		for _, failed := range retry.bookmarks {
+			if ctx.Err() != nil {
+				return result, i.interrupted(ctx)
+			}
+			item, err := i.RaindropClient.GetRaindrop(ctx, failed.ID)
+			i.stopOnAuthFailure(err)
+			if err != nil && ctx.Err() != nil {
+				return result, i.interrupted(ctx)
+			}
+			if httpx.Classify(err) == httpx.KindNotFound {
+				fmt.Printf("  - Bookmark %d no longer exists in Raindrop.io, skipping\n", failed.ID)
+				continue
+			}
+			if err != nil {
+				result.addBookmark(collection, failed, bookmarkOutcome{err: err})
+				continue
+			}
+			outcome := i.retryBookmark(context.WithoutCancel(ctx), collection, *item, listID, retry.highlights[item.ID], retry.flags[item.ID])
+			i.stopOnAuthFailure(outcome.errs()...)
+			result.addBookmark(collection, *item, outcome)
+		}
+		if err := i.Ledger.Save(); err != nil {
+			return result, fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+	}
+
+	if result.HasFailures() {
+		fmt.Printf("\nRetry finished with %d failures.\n", result.Failures())
+	} else {
+		fmt.Println("\nRetry complete!")
+	}
+	return result, nil
+}
+
+// retryBookmark imports a bookmark again and, when it had already been
+// created, re-creates the highlights with the given Raindrop IDs and sets its
+// favourite and archived flags if they failed.
+func (i *Importer) retryBookmark(ctx context.Context, collection raindrop.Collection, item raindrop.Raindrop, listID string, highlightIDs []string, flags bool) bookmarkOutcome {
+	outcome := i.importBookmark(ctx, collection, item, listID)
+	if outcome.err != nil || outcome.action == ActionCreate || (len(highlightIDs) == 0 && !flags) {
+		return outcome
+	}
+
+	record, ok := i.Ledger.Bookmark(item.ID)
+	if !ok {
+		return outcome
+	}
+	if flags {
+		archived := i.archived(collection)
+		update := &karakeep.BookmarkUpdate{Favourited: &item.Important, Archived: &archived}
+		if _, err := i.KarakeepClient.UpdateBookmark(ctx, record.KarakeepID, update); err != nil {
+			log.Printf("Failed to set favourite and archived flags of bookmark '%s': %v", item.Title, err)
+			outcome.flagsErr = err
+		}
+	}
+	if len(highlightIDs) == 0 {
+		return outcome
+	}
+	item.Highlights = slices.DeleteFunc(slices.Clone(item.Highlights), func(h raindrop.Highlight) bool {
+		return !slices.Contains(highlightIDs, h.ID)
+	})
+	outcome.highlightFailures = i.importHighlights(ctx, record.KarakeepID, item)
+	return outcome
+}
@@ -0,0 +1,172 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"log"
+	"time"
+
+	"github.com/ashebanow/rainbridge/internal/ledger"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+// Sync brings Karakeep up to date with the Raindrop.io bookmarks that were
+// added or edited since the last import or sync that ran to the end, as
+// recorded in the ledger. New bookmarks are imported as RunImport would
+// import them, and bookmarks the ledger maps to a Karakeep bookmark are
+// updated in place. Lists are created for new collections. Without a
+// previous run, every bookmark is synced.
+//
+// Each collection is searched on the server for the bookmarks updated since
+// the last run, and the ones that did not change after it are dropped.
+// Moving a bookmark changes it, so MovedPolicy is applied along the way.
+// Then DeletedPolicy is applied to the bookmarks that were deleted in
+// Raindrop.io. The start time of the sync is recorded in the ledger when it
+// runs to the end without failures; otherwise the next sync looks at the
+// same changes again. Cancellation and rejected tokens are handled as in
+// RunImport.
+func (i *Importer) Sync(ctx context.Context) (*ImportResult, error) {
+	ctx, done := i.begin(ctx)
+	defer done()
+	start := time.Now()
+	result := &ImportResult{}
+	defer func() { result.Elapsed = time.Since(start) }()
+
+	if i.Ledger == nil {
+		i.Ledger = ledger.New()
+	}
+	since := i.Ledger.LastSync()
+	if since.IsZero() {
+		fmt.Println("No previous import or sync is recorded, syncing every bookmark.")
+	} else {
+		fmt.Printf("Syncing bookmarks changed since %s.\n", since.Local().Format(time.DateTime))
+	}
+
+	fmt.Println("Fetching collections from Raindrop.io...")
+	collections, err := i.fetchCollections(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to get collections: %w", err)
+	}
+
+	if err := i.loadExisting(ctx); err != nil {
+		return result, err
+	}
+
+	for _, collection := range collections {
+		if ctx.Err() != nil {
+			return result, i.interrupted(ctx)
+		}
+		result.collection(collection)
+		if _, ok := i.Ledger.ListID(collection.ID); !ok && i.hasList(collection) {
+			i.createList(context.WithoutCancel(ctx), collection, result)
+		}
+	}
+	if err := i.Ledger.Save(); err != nil {
+		return result, fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	for _, collection := range collections {
+		if ctx.Err() != nil {
+			return result, i.interrupted(ctx)
+		}
+		fmt.Printf("\nFetching changed bookmarks for collection: %s\n", collection.Title)
+		items := i.RaindropClient.Raindrops(ctx, collection.ID, raindrop.PageOptions{Search: changedSearch(since)})
+		if err := i.importItems(ctx, collection, changedSince(items, since), i.syncBookmark, result); err != nil {
+			return result, err
+		}
+	}
+
+	if err := i.propagateRemovals(ctx, collections, result); err != nil {
+		return result, err
+	}
+
+	// After a failure, the next sync starts from the same point, so that
+	// the bookmarks that failed are tried again.
+	if !result.HasFailures() {
+		i.Ledger.RecordSync(start)
+	}
+	if err := i.Ledger.Save(); err != nil {
+		return result, fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	if result.HasFailures() {
+		fmt.Printf("\nSync finished with %d failures.\n", result.Failures())
+	} else {
+		fmt.Println("\nSync complete!")
+	}
+	return result, nil
+}
+
+// changedSearch returns the Raindrop.io search for the bookmarks updated
+// since the given time, or "" to fetch every bookmark. The search only
+// compares dates, so it starts the day before and changedSince drops the
+// bookmarks that were not updated after since.
+func changedSearch(since time.Time) string {
+	if since.IsZero() {
+		return ""
+	}
+	return "lastUpdate:>" + since.UTC().AddDate(0, 0, -1).Format(time.DateOnly)
+}
+
+// changedSince yields the bookmarks of items that were updated after since.
+// The items may come in any order, so all of them are looked at.
+func changedSince(items iter.Seq2[raindrop.Raindrop, error], since time.Time) iter.Seq2[raindrop.Raindrop, error] {
+	return func(yield func(raindrop.Raindrop, error) bool) {
+		for item, err := range items {
+			if err == nil && !item.LastUpdate.After(since) {
+				continue
+			}
+			if !yield(item, err) {
+				return
+			}
+		}
+	}
+}
+
+// syncBookmark updates the Karakeep bookmark that the ledger maps a changed
+// Raindrop bookmark to, and imports bookmarks the ledger does not know yet.
+// Bookmarks that were already in Karakeep are only updated under
+// PolicyUpdate, as when importing them. Bookmarks moved to another
+// collection are moved to its list as MovedPolicy says.
+func (i *Importer) syncBookmark(ctx context.Context, collection raindrop.Collection, item raindrop.Raindrop, listID string) bookmarkOutcome {
+	record, imported := i.Ledger.Bookmark(item.ID)
+	if !imported {
+		return i.importBookmark(ctx, collection, item, listID)
+	}
+
+	outcome := bookmarkOutcome{action: ActionSkip}
+	if !record.Existing || i.BookmarkPolicy == PolicyUpdate {
+		update := i.bookmarkUpdate(collection, item)
+		if !*update.Archived && !record.Removed {
+			// Bookmarks archived in Karakeep stay archived, unless a sync
+			// archived them because they were deleted and they came back.
+			update.Archived = nil
+		}
+		if _, err := i.KarakeepClient.UpdateBookmark(ctx, record.KarakeepID, update); err != nil {
+			log.Printf("Failed to update bookmark '%s': %v", item.Title, err)
+			return bookmarkOutcome{action: ActionUpdate, err: err}
+		}
+		fmt.Printf("  - Updated bookmark: %s\n", item.Title)
+		outcome.action = ActionUpdate
+	}
+
+	// A bookmark moved to another collection stays in the list it was
+	// added to, unless MovedPolicy moves it.
+	if record.CollectionID != collection.ID {
+		if i.MovedPolicy == SyncMove {
+			item.Collection.ID = collection.ID
+			outcome.moveErr = i.moveBookmark(ctx, item, record)
+			outcome.moved = outcome.moveErr == nil
+		}
+		return outcome
+	}
+
+	// The ledger decides whether the bookmark still has to be added to its
+	// list, as when resuming an import.
+	membership := i.importBookmark(ctx, collection, item, listID)
+	if outcome.action == ActionUpdate {
+		membership.action = ActionUpdate
+	}
+	return membership
+}
@@ -0,0 +1,381 @@
+//go:build !integration
+
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ashebanow/rainbridge/internal/ledger"
+)
+
+func TestSync(t *testing.T) {
+	lastSync := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	var mu sync.Mutex
+	var pages []string
+	raindropHandler := func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}]}`)
+		case r.URL.Path == "/rest/v1/raindrops/1":
+			mu.Lock()
+			pages = append(pages, r.URL.Query().Get("page")+":"+r.URL.Query().Get("search"))
+			mu.Unlock()
+			if r.URL.Query().Get("page") != "0" {
+				fmt.Fprintln(w, `{"items": []}`)
+				return
+			}
+			// The search matches whole days, and the items are not sorted.
+			fmt.Fprintln(w, `{"items": [
+				{"_id": 102, "title": "Unchanged", "link": "https://example.com/unchanged", "lastUpdate": "2025-02-28T12:00:00Z"},
+				{"_id": 101, "title": "Edited", "link": "https://example.com/edited", "lastUpdate": "2025-03-02T00:00:00Z"},
+				{"_id": 104, "title": "Same day", "link": "https://example.com/same-day", "lastUpdate": "2025-03-01T00:00:00Z"},
+				{"_id": 103, "title": "New", "link": "https://example.com/new", "lastUpdate": "2025-03-03T00:00:00Z"}
+			]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+
+	var requests []string
+	karakeepHandler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "GET":
+			fmt.Fprintln(w, `[]`)
+		case r.Method == "POST" && r.URL.Path == "/v1/bookmarks":
+			requests = append(requests, "create")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "bookmark-103"}`)
+		case r.Method == "PATCH":
+			requests = append(requests, "update "+r.URL.Path)
+			fmt.Fprintln(w, `{}`)
+		default:
+			requests = append(requests, r.Method+" "+r.URL.Path)
+			fmt.Fprintln(w, `{}`)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	checkpoint, err := ledger.Open(path)
+	if err != nil {
+		t.Fatalf("ledger.Open failed: %v", err)
+	}
+	checkpoint.RecordList(1, "list-1")
+	checkpoint.RecordBookmark(101, 1, "bookmark-101")
+	checkpoint.RecordMembership(101, "list-1")
+	checkpoint.RecordBookmark(102, 1, "bookmark-102")
+	checkpoint.RecordMembership(102, "list-1")
+	checkpoint.RecordSync(lastSync)
+
+	importer := newTestImporter(t, raindropHandler, karakeepHandler)
+	importer.Ledger = checkpoint
+
+	started := time.Now()
+	result, err := importer.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if result.BookmarksCreated != 1 || result.BookmarksUpdated != 1 || result.HasFailures() {
+		t.Errorf("Expected 1 created and 1 updated bookmark, got %+v", result)
+	}
+	// The bookmarks are imported by several workers, in any order.
+	sort.Strings(requests)
+	expected := []string{"POST /v1/lists/list-1/bookmarks/bookmark-103", "create", "update /v1/bookmarks/bookmark-101"}
+	if strings.Join(requests, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected requests %v, got %v", expected, requests)
+	}
+	// Every page of the search is read, and the bookmarks that did not
+	// change after the last sync are dropped.
+	if strings.Join(pages, ",") != "0:lastUpdate:>2025-02-28,1:lastUpdate:>2025-02-28" {
+		t.Errorf("Expected the pages of the lastUpdate search, got %v", pages)
+	}
+
+	reopened, err := ledger.Open(path)
+	if err != nil {
+		t.Fatalf("Reopening ledger failed: %v", err)
+	}
+	if !reopened.LastSync().After(started.Add(-time.Second)) {
+		t.Errorf("Expected the sync to be recorded, got %v", reopened.LastSync())
+	}
+	if _, ok := reopened.Bookmark(103); !ok {
+		t.Error("Expected the new bookmark to be checkpointed")
+	}
+}
+
+func TestSyncReportsFailedUpdates(t *testing.T) {
+	lastSync := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	raindropHandler := func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}]}`)
+		case r.URL.Query().Get("page") == "0":
+			fmt.Fprintln(w, `{"items": [{"_id": 101, "title": "Edited", "link": "https://example.com/edited", "lastUpdate": "2025-03-02T00:00:00Z"}]}`)
+		default:
+			fmt.Fprintln(w, `{"items": []}`)
+		}
+	}
+	karakeepHandler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			fmt.Fprintln(w, `[]`)
+		case "PATCH":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintln(w, `{"message": "title too long"}`)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}
+
+	checkpoint := ledger.New()
+	checkpoint.RecordList(1, "list-1")
+	checkpoint.RecordBookmark(101, 1, "bookmark-101")
+	checkpoint.RecordMembership(101, "list-1")
+	checkpoint.RecordSync(lastSync)
+
+	importer := newTestImporter(t, raindropHandler, karakeepHandler)
+	importer.Ledger = checkpoint
+
+	result, err := importer.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if result.BookmarksFailed != 1 || len(result.Errors) != 1 || result.Errors[0].Stage != FailedBookmark || result.Errors[0].RaindropID != 101 {
+		t.Errorf("Expected the failed update to be reported, got %+v", result)
+	}
+	// The next sync looks at the edit again.
+	if !checkpoint.LastSync().Equal(lastSync) {
+		t.Errorf("Expected the last sync to stay at %v, got %v", lastSync, checkpoint.LastSync())
+	}
+}
+
+func TestSyncLeavesExistingBookmarksAndKeepsHighlights(t *testing.T) {
+	lastSync := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	raindropHandler := func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}]}`)
+		case r.URL.Query().Get("page") == "0":
+			fmt.Fprintln(w, `{"items": [
+				{"_id": 101, "title": "Imported", "note": "Mine", "highlights": [{"_id": "h1", "text": "Quote"}], "lastUpdate": "2025-03-02T00:00:00Z"},
+				{"_id": 102, "title": "Existing", "lastUpdate": "2025-03-02T00:00:00Z"}
+			]}`)
+		default:
+			fmt.Fprintln(w, `{"items": []}`)
+		}
+	}
+
+	var mu sync.Mutex
+	notes := make(map[string]string)
+	karakeepHandler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case "GET":
+			fmt.Fprintln(w, `[]`)
+		case "PATCH":
+			var body struct {
+				Note string `json:"note"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			notes[r.URL.Path] = body.Note
+			fmt.Fprintln(w, `{}`)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}
+
+	checkpoint := ledger.New()
+	checkpoint.RecordList(1, "list-1")
+	checkpoint.RecordBookmark(101, 1, "bookmark-101")
+	checkpoint.RecordMembership(101, "list-1")
+	checkpoint.RecordExistingBookmark(102, 1, "bookmark-102")
+	checkpoint.RecordMembership(102, "list-1")
+	checkpoint.RecordSync(lastSync)
+
+	importer := newTestImporter(t, raindropHandler, karakeepHandler)
+	importer.Ledger = checkpoint
+	importer.BookmarkPolicy = PolicySkip
+	importer.highlightsUnsupported.Store(true)
+
+	result, err := importer.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if result.BookmarksUpdated != 1 || result.BookmarksSkipped != 1 || result.HasFailures() {
+		t.Errorf("Expected 1 updated and 1 skipped bookmark, got %+v", result)
+	}
+	if _, ok := notes["/v1/bookmarks/bookmark-102"]; ok {
+		t.Error("Expected the bookmark that was already in Karakeep to be left alone")
+	}
+	if note := notes["/v1/bookmarks/bookmark-101"]; note != "Mine\n\nHighlights:\n\n> Quote" {
+		t.Errorf("Expected the highlights to stay in the note, got %q", note)
+	}
+}
+
+func TestParseSyncPolicies(t *testing.T) {
+	for _, value := range []string{"ignore", "archive", "delete", " DELETE "} {
+		if _, err := ParseDeletedPolicy(value); err != nil {
+			t.Errorf("ParseDeletedPolicy(%q) returned error: %v", value, err)
+		}
+	}
+	if _, err := ParseDeletedPolicy("move"); err == nil {
+		t.Error("Expected error for a deleted policy of move, got nil")
+	}
+	for _, value := range []string{"ignore", "move"} {
+		if _, err := ParseMovedPolicy(value); err != nil {
+			t.Errorf("ParseMovedPolicy(%q) returned error: %v", value, err)
+		}
+	}
+	if _, err := ParseMovedPolicy("delete"); err == nil {
+		t.Error("Expected error for a moved policy of delete, got nil")
+	}
+}
+
+func TestSyncPropagatesDeletionsAndMoves(t *testing.T) {
+	raindropHandler := func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}, {"_id": 2, "title": "Archive"}]}`)
+		case r.URL.Path == "/rest/v1/raindrops/2" && r.URL.Query().Get("page") == "0":
+			// Moving 102 to Archive changed it.
+			fmt.Fprintf(w, `{"items": [{"_id": 102, "title": "Moved", "link": "https://example.com/moved", "collection": {"$id": 2}, "lastUpdate": %q}]}`,
+				time.Now().Format(time.RFC3339))
+		case r.URL.Path == "/rest/v1/raindrops/0" && r.URL.Query().Get("page") == "0":
+			// The others in Reading are gone.
+			fmt.Fprintln(w, `{"items": [
+				{"_id": 102, "title": "Moved", "link": "https://example.com/moved", "collection": {"$id": 2}},
+				{"_id": 105, "title": "Kept", "link": "https://example.com/kept", "collection": {"$id": 1}}
+			]}`)
+		case r.URL.Path == "/rest/v1/raindrop/103":
+			fmt.Fprintln(w, `{"item": {"_id": 103, "title": "Trashed", "collection": {"$id": -99}}}`)
+		case r.URL.Path == "/rest/v1/raindrop/104" || r.URL.Path == "/rest/v1/raindrop/106":
+			t.Errorf("Expected %s not to be fetched", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		case strings.HasPrefix(r.URL.Path, "/rest/v1/raindrop/"):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			// Nothing else changed since the last sync.
+			fmt.Fprintln(w, `{"items": []}`)
+		}
+	}
+
+	var requests []string
+	karakeepHandler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprintln(w, `[]`)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		fmt.Fprintln(w, `{}`)
+	}
+
+	checkpoint := ledger.New()
+	checkpoint.RecordList(1, "list-1")
+	checkpoint.RecordList(2, "list-2")
+	for _, id := range []int64{101, 102, 103, 105} {
+		checkpoint.RecordBookmark(id, 1, fmt.Sprintf("bookmark-%d", id))
+		checkpoint.RecordMembership(id, "list-1")
+	}
+	checkpoint.RecordExistingBookmark(104, 1, "bookmark-104")
+	checkpoint.RecordBookmark(106, 1, "bookmark-106")
+	checkpoint.RecordRemoved(106, true)
+	checkpoint.RecordSync(time.Now().Add(-time.Hour))
+
+	importer := newTestImporter(t, raindropHandler, karakeepHandler)
+	importer.Ledger = checkpoint
+	importer.DeletedPolicy = SyncDelete
+	importer.MovedPolicy = SyncMove
+
+	result, err := importer.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if result.BookmarksDeleted != 2 || result.BookmarksArchived != 0 || result.BookmarksMoved != 1 || result.HasFailures() {
+		t.Errorf("Expected 2 deleted and 1 moved bookmark, got %+v", result)
+	}
+	// The bookmark is moved while the changed bookmarks are synced, before
+	// the deletions. The bookmark that was already in Karakeep is left
+	// alone.
+	expected := []string{
+		"PATCH /v1/bookmarks/bookmark-102",
+		"DELETE /v1/lists/list-1/bookmarks/bookmark-102",
+		"POST /v1/lists/list-2/bookmarks/bookmark-102",
+		"DELETE /v1/bookmarks/bookmark-101",
+		"DELETE /v1/bookmarks/bookmark-103",
+	}
+	if strings.Join(requests, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected requests %v, got %v", expected, requests)
+	}
+
+	if _, ok := checkpoint.Bookmark(101); ok {
+		t.Error("Expected the deleted bookmark to be forgotten")
+	}
+	if rec, _ := checkpoint.Bookmark(102); rec.CollectionID != 2 || rec.ListID != "list-2" {
+		t.Errorf("Expected the moved bookmark to be recorded in its new list, got %+v", rec)
+	}
+	if rec, ok := checkpoint.Bookmark(104); !ok || rec.Removed {
+		t.Errorf("Expected the bookmark that was already in Karakeep to be kept, got %+v (found=%v)", rec, ok)
+	}
+}
+
+func TestSyncArchivesDeletedBookmarks(t *testing.T) {
+	raindropHandler := func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}]}`)
+		case strings.HasPrefix(r.URL.Path, "/rest/v1/raindrop/"):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			fmt.Fprintln(w, `{"items": []}`)
+		}
+	}
+
+	var requests []string
+	karakeepHandler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprintln(w, `[]`)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		fmt.Fprintln(w, `{}`)
+	}
+
+	checkpoint := ledger.New()
+	checkpoint.RecordList(1, "list-1")
+	checkpoint.RecordBookmark(101, 1, "bookmark-101")
+	checkpoint.RecordSync(time.Now().Add(-time.Hour))
+
+	importer := newTestImporter(t, raindropHandler, karakeepHandler)
+	importer.Ledger = checkpoint
+	importer.DeletedPolicy = SyncArchive
+
+	result, err := importer.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if result.BookmarksArchived != 1 || result.HasFailures() {
+		t.Errorf("Expected 1 archived bookmark, got %+v", result)
+	}
+	if strings.Join(requests, ",") != "PATCH /v1/bookmarks/bookmark-101" {
+		t.Errorf("Expected the bookmark to be archived, got %v", requests)
+	}
+	if rec, _ := checkpoint.Bookmark(101); !rec.Removed {
+		t.Errorf("Expected the archived bookmark to be marked removed, got %+v", rec)
+	}
+}
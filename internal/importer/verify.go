@@ -0,0 +1,95 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ashebanow/rainbridge/internal/ledger"
+)
+
+// MissingItem is a list or bookmark recorded in the ledger that no longer
+// exists in Karakeep.
+type MissingItem struct {
+	RaindropID int64  `json:"raindropId"`
+	KarakeepID string `json:"karakeepId"`
+}
+
+// VerifyReport is the result of checking the ledger against Karakeep.
+type VerifyReport struct {
+	ListsChecked     int           `json:"listsChecked"`
+	BookmarksChecked int           `json:"bookmarksChecked"`
+	MissingLists     []MissingItem `json:"missingLists"`
+	MissingBookmarks []MissingItem `json:"missingBookmarks"`
+}
+
+// OK reports whether everything in the ledger was found in Karakeep.
+func (r *VerifyReport) OK() bool {
+	return len(r.MissingLists) == 0 && len(r.MissingBookmarks) == 0
+}
+
+// Verify checks that every list and bookmark recorded in the ledger still
+// exists in Karakeep.
+func (i *Importer) Verify(ctx context.Context) (*VerifyReport, error) {
+	if i.Ledger == nil {
+		i.Ledger = ledger.New()
+	}
+
+	listIDs := make(map[string]bool)
+	for list, err := range i.KarakeepClient.Lists(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to get lists: %w", err)
+		}
+		listIDs[list.ID] = true
+	}
+
+	bookmarkIDs := make(map[string]bool)
+	for bookmark, err := range i.KarakeepClient.Bookmarks(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to get bookmarks: %w", err)
+		}
+		bookmarkIDs[bookmark.ID] = true
+	}
+
+	report := &VerifyReport{
+		MissingLists:     []MissingItem{},
+		MissingBookmarks: []MissingItem{},
+	}
+	for collectionID, rec := range i.Ledger.Lists() {
+		report.ListsChecked++
+		if !listIDs[rec.KarakeepID] {
+			report.MissingLists = append(report.MissingLists, MissingItem{RaindropID: collectionID, KarakeepID: rec.KarakeepID})
+		}
+	}
+	for raindropID, rec := range i.Ledger.Bookmarks() {
+		report.BookmarksChecked++
+		if !bookmarkIDs[rec.KarakeepID] {
+			report.MissingBookmarks = append(report.MissingBookmarks, MissingItem{RaindropID: raindropID, KarakeepID: rec.KarakeepID})
+		}
+	}
+	sortMissing(report.MissingLists)
+	sortMissing(report.MissingBookmarks)
+
+	return report, nil
+}
+
+func sortMissing(items []MissingItem) {
+	sort.Slice(items, func(a, b int) bool { return items[a].RaindropID < items[b].RaindropID })
+}
+
+// Print writes a human-readable version of the report.
+func (r *VerifyReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "Checked %d lists and %d bookmarks recorded in the checkpoint.\n", r.ListsChecked, r.BookmarksChecked)
+	if r.OK() {
+		fmt.Fprintln(w, "Everything is present in Karakeep.")
+		return
+	}
+	for _, item := range r.MissingLists {
+		fmt.Fprintf(w, "  missing list %s (Raindrop collection %d)\n", item.KarakeepID, item.RaindropID)
+	}
+	for _, item := range r.MissingBookmarks {
+		fmt.Fprintf(w, "  missing bookmark %s (Raindrop bookmark %d)\n", item.KarakeepID, item.RaindropID)
+	}
+	fmt.Fprintf(w, "%d lists and %d bookmarks are missing from Karakeep.\n", len(r.MissingLists), len(r.MissingBookmarks))
+}
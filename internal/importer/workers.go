@@ -0,0 +1,109 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"iter"
+	"sync"
+
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+// importBookmarks imports the bookmarks of a collection with importOne as
+// they are fetched from items, with up to i.Workers goroutines, saving the
+// ledger every checkpointInterval bookmarks. It returns the bookmarks that
+// were fetched and their outcomes in the order Raindrop.io returned them,
+// however the work was scheduled, so that the result is the same for every
+// run.
+// fetchErr is set when not all bookmarks could be fetched; the ones that
+// were are imported anyway.
+//
+// Bookmarks whose URLs normalize to the same string are always imported by
+// the same worker, in order, so that duplicate detection sees the first one
+// before the next one is imported.
+//
+// Once ctx is cancelled, or a token is rejected, no further pages are
+// fetched and no further bookmarks are started, but the ones in progress
+// are finished. Bookmarks that were never started have a zero outcome.
+func (i *Importer) importBookmarks(ctx context.Context, collection raindrop.Collection, items iter.Seq2[raindrop.Raindrop, error], importOne importFunc, listID string) (raindrops []raindrop.Raindrop, outcomes []bookmarkOutcome, fetchErr, err error) {
+	type job struct {
+		n    int
+		item raindrop.Raindrop
+	}
+	type finished struct {
+		n       int
+		outcome bookmarkOutcome
+	}
+
+	workers := max(i.Workers, 1)
+	completed := make(chan finished)
+	queues := make([]chan job, workers)
+	var wg sync.WaitGroup
+	for w := range queues {
+		// Each queue holds about a page, so the next page is fetched while
+		// the workers import the current one.
+		queues[w] = make(chan job, raindrop.MaxPageSize)
+		wg.Add(1)
+		go func(queue <-chan job) {
+			defer wg.Done()
+			for j := range queue {
+				if ctx.Err() != nil {
+					continue
+				}
+				outcome := importOne(context.WithoutCancel(ctx), collection, j.item, listID)
+				i.stopOnAuthFailure(outcome.errs()...)
+				completed <- finished{n: j.n, outcome: outcome}
+			}
+		}(queues[w])
+	}
+
+	go func() {
+		defer func() {
+			for _, queue := range queues {
+				close(queue)
+			}
+			wg.Wait()
+			close(completed)
+		}()
+		for item, err := range items {
+			if err != nil {
+				fetchErr = err
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			raindrops = append(raindrops, item)
+			queues[shard(item.Link, workers)] <- job{n: len(raindrops) - 1, item: item}
+		}
+	}()
+
+	var saveErr error
+	count := 0
+	for f := range completed {
+		if f.n >= len(outcomes) {
+			outcomes = append(outcomes, make([]bookmarkOutcome, f.n+1-len(outcomes))...)
+		}
+		outcomes[f.n] = f.outcome
+		count++
+		if count%checkpointInterval == 0 && saveErr == nil {
+			saveErr = i.Ledger.Save()
+		}
+	}
+	outcomes = append(outcomes, make([]bookmarkOutcome, len(raindrops)-len(outcomes))...)
+	if saveErr != nil {
+		return raindrops, outcomes, fetchErr, fmt.Errorf("failed to save checkpoint: %w", saveErr)
+	}
+	return raindrops, outcomes, fetchErr, nil
+}
+
+// shard picks the worker for a bookmark URL.
+func shard(rawURL string, workers int) int {
+	if workers == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(normalizeURL(rawURL)))
+	return int(h.Sum32() % uint32(workers))
+}
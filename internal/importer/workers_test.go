@@ -0,0 +1,338 @@
+//go:build !integration
+
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ashebanow/rainbridge/internal/karakeep"
+	"github.com/ashebanow/rainbridge/internal/ledger"
+	"github.com/ashebanow/rainbridge/internal/raindrop"
+)
+
+func TestRunImportWithWorkers(t *testing.T) {
+	const bookmarks = 60
+
+	raindropServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}]}`)
+		case r.URL.Query().Get("page") == "0":
+			items := make([]string, 0, bookmarks)
+			for n := range bookmarks {
+				// Every tenth bookmark repeats the URL of the previous one.
+				link := fmt.Sprintf("https://example.com/%d", n-n%10/9)
+				items = append(items, fmt.Sprintf(`{"_id": %d, "title": "Bookmark %d", "link": %q}`, n+1, n+1, link))
+			}
+			fmt.Fprintf(w, `{"items": [%s]}`, strings.Join(items, ","))
+		default:
+			fmt.Fprintln(w, `{"items": []}`)
+		}
+	}))
+	defer raindropServer.Close()
+
+	var mu sync.Mutex
+	created := make(map[string]int)
+	karakeepServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET":
+			fmt.Fprintln(w, `[]`)
+		case r.URL.Path == "/v1/lists":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "list-1", "name": "Reading"}`)
+		case r.URL.Path == "/v1/bookmarks":
+			var bookmark karakeep.Bookmark
+			json.NewDecoder(r.Body).Decode(&bookmark)
+			if strings.HasSuffix(bookmark.URL, "/13") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			created[bookmark.URL]++
+			mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": "bookmark-%s"}`, strings.TrimPrefix(bookmark.URL, "https://example.com/"))
+		default:
+			fmt.Fprintln(w, `{}`)
+		}
+	}))
+	defer karakeepServer.Close()
+
+	raindropClient := raindrop.NewClient("test-token")
+	raindropClient.SetBaseURL(raindropServer.URL + "/rest/v1")
+	karakeepClient := karakeep.NewClient("test-token")
+	karakeepClient.SetBaseURL(karakeepServer.URL + "/v1")
+
+	importer := NewImporter(raindropClient, karakeepClient)
+	importer.Workers = 8
+	importer.BookmarkPolicy = PolicySkip
+
+	result, err := importer.RunImport(context.Background())
+	if err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+
+	for url, count := range created {
+		if count != 1 {
+			t.Errorf("Expected %s to be created once, got %d", url, count)
+		}
+	}
+	if result.BookmarksCreated != 53 || result.BookmarksReused != 6 || result.BookmarksFailed != 1 {
+		t.Errorf("Expected 53 created, 6 reused and 1 failed, got %d, %d and %d",
+			result.BookmarksCreated, result.BookmarksReused, result.BookmarksFailed)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].RaindropID != 14 {
+		t.Errorf("Expected only bookmark 14 to fail, got %+v", result.Errors)
+	}
+	if _, ok := importer.Ledger.Bookmark(bookmarks); !ok {
+		t.Errorf("Expected the last bookmark to be recorded in the ledger")
+	}
+}
+
+func TestShardKeepsDuplicateURLsTogether(t *testing.T) {
+	a := shard("https://www.example.com/page/", 8)
+	b := shard("https://example.com/page", 8)
+	if a != b {
+		t.Errorf("Expected equivalent URLs to go to the same worker, got %d and %d", a, b)
+	}
+	if shard("https://example.com/page", 1) != 0 {
+		t.Errorf("Expected a single worker to get every bookmark")
+	}
+}
+
+func TestRunImportFinishesInFlightBookmarksWhenCancelled(t *testing.T) {
+	const bookmarks = 20
+
+	raindropServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}, {"_id": 2, "title": "Work"}]}`)
+		case r.URL.Path == "/rest/v1/raindrops/1" && r.URL.Query().Get("page") == "0":
+			items := make([]string, 0, bookmarks)
+			for n := range bookmarks {
+				items = append(items, fmt.Sprintf(`{"_id": %d, "title": "Bookmark %d", "link": "https://example.com/%d"}`, n+1, n+1, n+1))
+			}
+			fmt.Fprintf(w, `{"items": [%s]}`, strings.Join(items, ","))
+		default:
+			fmt.Fprintln(w, `{"items": []}`)
+		}
+	}))
+	defer raindropServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	requests := 0
+	karakeepServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET":
+			fmt.Fprintln(w, `[]`)
+		case r.URL.Path == "/v1/lists":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "list-1", "name": "Reading"}`)
+		case r.URL.Path == "/v1/bookmarks":
+			mu.Lock()
+			requests++
+			if requests == 5 {
+				// Interrupt while this request is still being served.
+				cancel()
+			}
+			mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": "bookmark-%d"}`, requests)
+		default:
+			fmt.Fprintln(w, `{}`)
+		}
+	}))
+	defer karakeepServer.Close()
+
+	raindropClient := raindrop.NewClient("test-token")
+	raindropClient.SetBaseURL(raindropServer.URL + "/rest/v1")
+	karakeepClient := karakeep.NewClient("test-token")
+	karakeepClient.SetBaseURL(karakeepServer.URL + "/v1")
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	checkpoint, err := ledger.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	importer := NewImporter(raindropClient, karakeepClient)
+	importer.Ledger = checkpoint
+	importer.Workers = 2
+
+	result, err := importer.RunImport(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the import to be interrupted, got %v", err)
+	}
+
+	if result.BookmarksCreated < 5 || result.BookmarksCreated >= bookmarks {
+		t.Errorf("Expected some but not all bookmarks to be created, got %d", result.BookmarksCreated)
+	}
+	if result.BookmarksCreated != requests || result.HasFailures() {
+		t.Errorf("Expected all %d started bookmarks to finish, got %d created and %+v", requests, result.BookmarksCreated, result.Errors)
+	}
+
+	saved, err := ledger.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Bookmarks()) != result.BookmarksCreated {
+		t.Errorf("Expected the checkpoint to record %d bookmarks, got %d", result.BookmarksCreated, len(saved.Bookmarks()))
+	}
+	if len(result.Collections) != 2 || result.Collections[1].BookmarksCreated != 0 {
+		t.Errorf("Expected the second collection not to be imported, got %+v", result.Collections)
+	}
+}
+
+func TestRunImportStopsWhenTokenIsRejected(t *testing.T) {
+	const bookmarks = 20
+
+	raindropServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}, {"_id": 2, "title": "Work"}]}`)
+		case r.URL.Path == "/rest/v1/raindrops/1" && r.URL.Query().Get("page") == "0":
+			items := make([]string, 0, bookmarks)
+			for n := range bookmarks {
+				items = append(items, fmt.Sprintf(`{"_id": %d, "title": "Bookmark %d", "link": "https://example.com/%d"}`, n+1, n+1, n+1))
+			}
+			fmt.Fprintf(w, `{"items": [%s]}`, strings.Join(items, ","))
+		default:
+			fmt.Fprintln(w, `{"items": []}`)
+		}
+	}))
+	defer raindropServer.Close()
+
+	var mu sync.Mutex
+	requests := 0
+	karakeepServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET":
+			fmt.Fprintln(w, `[]`)
+		case r.URL.Path == "/v1/bookmarks":
+			mu.Lock()
+			requests++
+			n := requests
+			mu.Unlock()
+			if n > 3 {
+				// The API key is revoked after the third bookmark.
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": "bookmark-%d"}`, n)
+		default:
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "list-1", "name": "Reading"}`)
+		}
+	}))
+	defer karakeepServer.Close()
+
+	raindropClient := raindrop.NewClient("test-token")
+	raindropClient.SetBaseURL(raindropServer.URL + "/rest/v1")
+	karakeepClient := karakeep.NewClient("test-token")
+	karakeepClient.SetBaseURL(karakeepServer.URL + "/v1")
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	checkpoint, err := ledger.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	importer := NewImporter(raindropClient, karakeepClient)
+	importer.Ledger = checkpoint
+	importer.Workers = 2
+
+	result, err := importer.RunImport(context.Background())
+	if !errors.Is(err, karakeep.ErrUnauthorized) {
+		t.Fatalf("Expected the import to stop with karakeep.ErrUnauthorized, got %v", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a rejected token not to look like an interruption, got %v", err)
+	}
+
+	// Each worker may have started one more bookmark before the import stopped.
+	if requests > 5 {
+		t.Errorf("Expected the import to stop after the first rejected request, got %d requests", requests)
+	}
+	if result.BookmarksCreated != 3 {
+		t.Errorf("Expected 3 bookmarks to be created, got %d", result.BookmarksCreated)
+	}
+
+	saved, err := ledger.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Bookmarks()) != 3 {
+		t.Errorf("Expected the checkpoint to record 3 bookmarks, got %d", len(saved.Bookmarks()))
+	}
+}
+
+func TestRunImportPipelinesFetchingAndImporting(t *testing.T) {
+	firstCreated := make(chan struct{})
+	var once sync.Once
+
+	raindropServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/v1/collections":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}]}`)
+		case r.URL.Query().Get("page") == "0":
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "First", "link": "https://example.com/1"}]}`)
+		case r.URL.Query().Get("page") == "1":
+			// The second page is only served once the first bookmark has
+			// been created, which deadlocks unless they overlap.
+			select {
+			case <-firstCreated:
+			case <-time.After(5 * time.Second):
+				t.Error("Expected the first bookmark to be created before the second page was fetched")
+			}
+			fmt.Fprintln(w, `{"items": [{"_id": 2, "title": "Second", "link": "https://example.com/2"}]}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer raindropServer.Close()
+
+	karakeepServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET":
+			fmt.Fprintln(w, `[]`)
+		case r.URL.Path == "/v1/bookmarks":
+			once.Do(func() { close(firstCreated) })
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "bookmark-1"}`)
+		default:
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "list-1", "name": "Reading"}`)
+		}
+	}))
+	defer karakeepServer.Close()
+
+	raindropClient := raindrop.NewClient("test-token")
+	raindropClient.SetBaseURL(raindropServer.URL + "/rest/v1")
+	karakeepClient := karakeep.NewClient("test-token")
+	karakeepClient.SetBaseURL(karakeepServer.URL + "/v1")
+
+	result, err := NewImporter(raindropClient, karakeepClient).RunImport(context.Background())
+	if err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+
+	// The third page fails, but the bookmarks of the first two are kept.
+	if result.BookmarksCreated != 2 {
+		t.Errorf("Expected the bookmarks of the first two pages to be created, got %d", result.BookmarksCreated)
+	}
+	if result.CollectionsNotFetched != 1 || !result.Collections[0].FetchFailed {
+		t.Errorf("Expected the collection to be reported as not fully fetched, got %+v", result.Collections[0])
+	}
+}
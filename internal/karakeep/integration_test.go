@@ -3,6 +3,7 @@
 package karakeep
 
 import (
+	"context"
 	"log"
 	"os"
 	"strings"
@@ -41,7 +42,7 @@ func setupCleanup(t *testing.T, client *Client, testPrefix string) {
 func cleanupBookmarks(client *Client, testPrefix string) error {
 	log.Println("Cleaning up test bookmarks...")
 	
-	bookmarks, err := client.GetAllBookmarks()
+	bookmarks, err := client.GetAllBookmarks(context.Background())
 	if err != nil {
 		return err
 	}
@@ -51,7 +52,7 @@ func cleanupBookmarks(client *Client, testPrefix string) error {
 		// Only delete bookmarks that match our test prefix
 		if strings.HasPrefix(bookmark.Title, testPrefix) {
 			log.Printf("Deleting test bookmark: %s (ID: %s)", bookmark.Title, bookmark.ID)
-			if err := client.DeleteBookmark(bookmark.ID); err != nil {
+			if err := client.DeleteBookmark(context.Background(), bookmark.ID); err != nil {
 				log.Printf("Failed to delete bookmark %s: %v", bookmark.ID, err)
 			} else {
 				deletedCount++
@@ -67,7 +68,7 @@ func cleanupBookmarks(client *Client, testPrefix string) error {
 func cleanupLists(client *Client, testPrefix string) error {
 	log.Println("Cleaning up test lists...")
 	
-	lists, err := client.GetAllLists()
+	lists, err := client.GetAllLists(context.Background())
 	if err != nil {
 		return err
 	}
@@ -77,7 +78,7 @@ func cleanupLists(client *Client, testPrefix string) error {
 		// Only delete lists that match our test prefix
 		if strings.HasPrefix(list.Name, testPrefix) {
 			log.Printf("Deleting test list: %s (ID: %s)", list.Name, list.ID)
-			if err := client.DeleteList(list.ID); err != nil {
+			if err := client.DeleteList(context.Background(), list.ID); err != nil {
 				log.Printf("Failed to delete list %s: %v", list.ID, err)
 			} else {
 				deletedCount++
@@ -108,7 +109,7 @@ func TestIntegrationCreateBookmark(t *testing.T) {
 		Tags:  []string{"test"},
 	}
 
-	createdBookmark, err := client.CreateBookmark(bookmark)
+	createdBookmark, err := client.CreateBookmark(context.Background(), bookmark)
 	if err != nil {
 		t.Fatalf("Failed to create bookmark: %v", err)
 	}
@@ -133,7 +134,7 @@ func TestIntegrationCreateList(t *testing.T) {
 		Name: "[Test] Integration Test List",
 	}
 
-	createdList, err := client.CreateList(list)
+	createdList, err := client.CreateList(context.Background(), list)
 	if err != nil {
 		t.Fatalf("Failed to create list: %v", err)
 	}
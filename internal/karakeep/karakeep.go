@@ -1,42 +1,39 @@
 package karakeep
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"math/rand"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/ashebanow/rainbridge/internal/httpx"
+	"github.com/ashebanow/rainbridge/internal/ratelimit"
+	"github.com/ashebanow/rainbridge/internal/retry"
 )
 
-// Sleeper interface for dependency injection of sleep functionality
-type Sleeper interface {
-	Sleep(duration time.Duration)
-}
+// Sleeper waits between retries. Tests replace it to avoid sleeping.
+type Sleeper = httpx.Sleeper
 
 // RealSleeper implements Sleeper using time.Sleep
-type RealSleeper struct{}
+type RealSleeper = httpx.RealSleeper
 
-func (r RealSleeper) Sleep(duration time.Duration) {
-	time.Sleep(duration)
-}
+// DefaultBaseURL is the API endpoint of the hosted Karakeep service.
+const DefaultBaseURL = "https://api.karakeep.app/v1"
 
 // Client is the Karakeep API client.
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	token      string
-	sleeper    Sleeper
+	baseURL string
+	api     *httpx.Client
 }
 
 // NewClient creates a new Karakeep API client.
 func NewClient(token string) *Client {
 	return &Client{
-		baseURL:    "https://api.karakeep.app/v1",
-		httpClient: &http.Client{},
-		token:      token,
-		sleeper:    RealSleeper{},
+		baseURL: DefaultBaseURL,
+		api:     httpx.New(token),
 	}
 }
 
@@ -47,90 +44,253 @@ func (c *Client) SetBaseURL(baseURL string) {
 
 // SetHTTPClient sets the HTTP client for the Karakeep API client.
 func (c *Client) SetHTTPClient(httpClient *http.Client) {
-	c.httpClient = httpClient
+	c.api.HTTPClient = httpClient
 }
 
 // SetSleeper sets the sleeper for the Karakeep API client.
 func (c *Client) SetSleeper(sleeper Sleeper) {
-	c.sleeper = sleeper
+	c.api.Sleeper = sleeper
 }
 
-// doRequestWithRetry performs an HTTP request with exponential backoff retry logic.
-func (c *Client) doRequestWithRetry(req *http.Request) (*http.Response, error) {
-	const maxRetries = 5
-	baseDelay := time.Second
+// SetRateLimit limits the client to requestsPerSecond requests per second,
+// in bursts of up to burst requests, shared by all goroutines using it. A
+// value of zero or less removes the limit. Either way, the client slows down
+// when the server reports that its quota is running out.
+func (c *Client) SetRateLimit(requestsPerSecond float64, burst int) {
+	c.api.Limiter = ratelimit.New(requestsPerSecond, burst)
+}
 
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			return nil, err
-		}
+// SetRetryPolicy sets how the client retries failed requests. Without a
+// policy, only rate limited requests are retried.
+func (c *Client) SetRetryPolicy(policy retry.Policy) {
+	c.api.Retry = &policy
+}
 
-		// If not rate limited, return the response
-		if resp.StatusCode != http.StatusTooManyRequests {
-			return resp, nil
-		}
+// SetUserAgent sets the User-Agent header sent with every request.
+func (c *Client) SetUserAgent(userAgent string) {
+	c.api.UserAgent = userAgent
+}
 
-		// Close the response body since we're retrying
-		resp.Body.Close()
+// Transport returns the HTTP transport the client sends its requests with,
+// for settings that have no setter of their own, such as request hooks.
+func (c *Client) Transport() *httpx.Client {
+	return c.api
+}
 
-		// If this was the last attempt, return the rate limit error
-		if attempt == maxRetries {
-			return nil, fmt.Errorf("rate limited after %d retries: %s", maxRetries, resp.Status)
-		}
+// Bookmark represents a Karakeep bookmark.
+type Bookmark struct {
+	ID          string    `json:"id,omitempty"`
+	URL         string    `json:"url"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Favourited  bool      `json:"favourited,omitempty"`
+	Archived    bool      `json:"archived,omitempty"`
+	CreatedAt   time.Time `json:"createdAt,omitzero"`
+}
 
-		// Calculate delay with exponential backoff and jitter
-		delay := baseDelay * time.Duration(1<<uint(attempt))
-		jitter := time.Duration(rand.Float64() * float64(delay) * 0.1) // 10% jitter
-		sleepDuration := delay + jitter
+// UnmarshalJSON decodes a bookmark either in the flat shape CreateBookmark
+// sends, or as Karakeep returns it, with the URL and description inside its
+// content and tags as objects.
+func (b *Bookmark) UnmarshalJSON(data []byte) error {
+	type plain Bookmark
+	var wire struct {
+		plain
+		Tags    []tagName `json:"tags"`
+		Content struct {
+			URL         string `json:"url"`
+			Title       string `json:"title"`
+			Description string `json:"description"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
 
-		log.Printf("Rate limited (429), retrying in %v (attempt %d/%d)", sleepDuration, attempt+1, maxRetries)
-		c.sleeper.Sleep(sleepDuration)
+	*b = Bookmark(wire.plain)
+	if b.URL == "" {
+		b.URL = wire.Content.URL
+	}
+	if b.Title == "" {
+		// Karakeep only sets the title of a bookmark when it was edited.
+		b.Title = wire.Content.Title
+	}
+	if b.Description == "" {
+		b.Description = wire.Content.Description
+	}
+	b.Tags = nil
+	for _, tag := range wire.Tags {
+		b.Tags = append(b.Tags, string(tag))
 	}
+	return nil
+}
 
-	// This should never be reached due to the loop structure
-	return nil, fmt.Errorf("unexpected error in retry logic")
+// tagName decodes a tag given either as its name or as an object with a name.
+type tagName string
+
+func (t *tagName) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = tagName(name)
+		return nil
+	}
+	var tag struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &tag); err != nil {
+		return err
+	}
+	*t = tagName(tag.Name)
+	return nil
 }
 
-// Bookmark represents a Karakeep bookmark.
-type Bookmark struct {
-	ID          string   `json:"id,omitempty"`
-	URL         string   `json:"url"`
-	Title       string   `json:"title"`
-	Description string   `json:"description,omitempty"`
-	Tags        []string `json:"tags,omitempty"`
+// BookmarkUpdate holds the bookmark fields to change in UpdateBookmark. Nil
+// fields are left untouched.
+type BookmarkUpdate struct {
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Note        *string `json:"note,omitempty"`
+	Favourited  *bool   `json:"favourited,omitempty"`
+	Archived    *bool   `json:"archived,omitempty"`
+}
+
+// User represents a Karakeep user account.
+type User struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
 }
 
+// Highlight represents a Karakeep highlight on a bookmark.
+type Highlight struct {
+	ID          string `json:"id,omitempty"`
+	BookmarkID  string `json:"bookmarkId"`
+	StartOffset int    `json:"startOffset"`
+	EndOffset   int    `json:"endOffset"`
+	Color       string `json:"color,omitempty"`
+	Text        string `json:"text"`
+	Note        string `json:"note,omitempty"`
+}
+
+// Highlight colors supported by Karakeep.
+const (
+	HighlightYellow = "yellow"
+	HighlightRed    = "red"
+	HighlightGreen  = "green"
+	HighlightBlue   = "blue"
+)
+
+// ErrUnauthorized is wrapped by the error of every request that Karakeep
+// rejects because of the API key.
+var ErrUnauthorized = errors.New("karakeep rejected the API key")
+
+// apiError returns the error for an unexpected response to the request
+// described by op, wrapping ErrUnauthorized when the token was rejected.
+func apiError(op string, resp *http.Response) error {
+	err := httpx.NewAPIError(op, resp)
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		err.Err = ErrUnauthorized
+	}
+	return err
+}
+
+// ErrVersionUnknown is returned by ServerVersion when the server answers but
+// does not report its version.
+var ErrVersionUnknown = errors.New("server version unknown")
+
+// ErrHighlightsUnsupported is returned by CreateHighlight when the server has
+// no highlights API, as is the case for Karakeep versions before highlights
+// were introduced.
+var ErrHighlightsUnsupported = errors.New("server does not support highlights")
+
 // List represents a Karakeep list.
 type List struct {
-	ID   string `json:"id,omitempty"`
-	Name string `json:"name"`
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name"`
+	ParentID string `json:"parentId,omitempty"`
+	// Type is ListSmart for lists that hold the bookmarks matching a search
+	// query, and "manual" or empty otherwise.
+	Type string `json:"type,omitempty"`
 }
 
+// ListSmart is the Type of a smart list.
+const ListSmart = "smart"
 
-// CreateBookmark creates a new bookmark in Karakeep and returns the created bookmark.
-func (c *Client) CreateBookmark(bookmark *Bookmark) (*Bookmark, error) {
-	jsonPayload, err := json.Marshal(bookmark)
+
+// ServerVersion returns the version reported by the Karakeep server. The
+// version endpoint sits next to the versioned API, so for a base URL of
+// https://example.com/api/v1 it is https://example.com/api/version. It returns
+// an error wrapping ErrVersionUnknown when the server does not report one.
+func (c *Client) ServerVersion(ctx context.Context) (string, error) {
+	versionURL := strings.TrimSuffix(strings.TrimSuffix(c.baseURL, "/"), "/v1") + "/version"
+	req, err := c.api.NewRequest(ctx, "GET", versionURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.api.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get server version: %s: %w", resp.Status, ErrVersionUnknown)
+	}
+
+	var response struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil || response.Version == "" {
+		return "", fmt.Errorf("failed to get server version: unexpected response: %w", ErrVersionUnknown)
+	}
+
+	return response.Version, nil
+}
+
+// GetCurrentUser fetches the user the API key belongs to. It is a cheap way
+// to check that the key is valid, and returns an error wrapping
+// ErrUnauthorized when it is not.
+func (c *Client) GetCurrentUser(ctx context.Context) (*User, error) {
+	req, err := c.api.NewRequest(ctx, "GET", fmt.Sprintf("%s/users/me", c.baseURL), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/bookmarks", c.baseURL), bytes.NewBuffer(jsonPayload))
+	resp, err := c.api.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.token)
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError("get current user", resp)
+	}
+
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
 
-	resp, err := c.doRequestWithRetry(req)
+	return &user, nil
+}
+
+// CreateBookmark creates a new bookmark in Karakeep and returns the created bookmark.
+func (c *Client) CreateBookmark(ctx context.Context, bookmark *Bookmark) (*Bookmark, error) {
+	req, err := c.api.NewRequest(ctx, "POST", fmt.Sprintf("%s/bookmarks", c.baseURL), bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.api.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("failed to create bookmark: %s", resp.Status)
+		return nil, apiError("create bookmark", resp)
 	}
 
 	var createdBookmark Bookmark
@@ -141,154 +301,164 @@ func (c *Client) CreateBookmark(bookmark *Bookmark) (*Bookmark, error) {
 	return &createdBookmark, nil
 }
 
-// CreateList creates a new list in Karakeep and returns the created list.
-func (c *Client) CreateList(list *List) (*List, error) {
-	jsonPayload, err := json.Marshal(list)
+// UpdateBookmark updates an existing bookmark in Karakeep and returns the updated bookmark.
+func (c *Client) UpdateBookmark(ctx context.Context, bookmarkID string, update *BookmarkUpdate) (*Bookmark, error) {
+	req, err := c.api.NewRequest(ctx, "PATCH", fmt.Sprintf("%s/bookmarks/%s", c.baseURL, bookmarkID), update)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/lists", c.baseURL), bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.token)
-
-	resp, err := c.doRequestWithRetry(req)
+	resp, err := c.api.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("failed to create list: %s", resp.Status)
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError("update bookmark", resp)
 	}
 
-	var createdList List
-	if err := json.NewDecoder(resp.Body).Decode(&createdList); err != nil {
+	var updatedBookmark Bookmark
+	if err := json.NewDecoder(resp.Body).Decode(&updatedBookmark); err != nil {
 		return nil, err
 	}
 
-	return &createdList, nil
+	return &updatedBookmark, nil
 }
 
-// AddBookmarkToList adds a bookmark to a list in Karakeep.
-func (c *Client) AddBookmarkToList(bookmarkID, listID string) error {
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/lists/%s/bookmarks/%s", c.baseURL, listID, bookmarkID), nil)
+// CreateHighlight creates a highlight on a bookmark and returns the created
+// highlight. It returns an error wrapping ErrHighlightsUnsupported when the
+// server does not provide the highlights endpoint.
+func (c *Client) CreateHighlight(ctx context.Context, highlight *Highlight) (*Highlight, error) {
+	req, err := c.api.NewRequest(ctx, "POST", fmt.Sprintf("%s/highlights", c.baseURL), highlight)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
-
-	resp, err := c.doRequestWithRetry(req)
+	resp, err := c.api.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to add bookmark to list: %s", resp.Status)
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		apiErr := httpx.NewAPIError("create highlight", resp)
+		apiErr.Err = ErrHighlightsUnsupported
+		return nil, apiErr
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, apiError("create highlight", resp)
 	}
 
-	return nil
+	var createdHighlight Highlight
+	if err := json.NewDecoder(resp.Body).Decode(&createdHighlight); err != nil {
+		return nil, err
+	}
+
+	return &createdHighlight, nil
 }
 
-// GetAllBookmarks fetches all bookmarks from Karakeep.
-func (c *Client) GetAllBookmarks() ([]*Bookmark, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/bookmarks", c.baseURL), nil)
+// CreateList creates a new list in Karakeep and returns the created list.
+func (c *Client) CreateList(ctx context.Context, list *List) (*List, error) {
+	req, err := c.api.NewRequest(ctx, "POST", fmt.Sprintf("%s/lists", c.baseURL), list)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
-
-	resp, err := c.doRequestWithRetry(req)
+	resp, err := c.api.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get bookmarks: %s", resp.Status)
+	if resp.StatusCode != http.StatusCreated {
+		return nil, apiError("create list", resp)
 	}
 
-	var bookmarks []*Bookmark
-	if err := json.NewDecoder(resp.Body).Decode(&bookmarks); err != nil {
+	var createdList List
+	if err := json.NewDecoder(resp.Body).Decode(&createdList); err != nil {
 		return nil, err
 	}
 
-	return bookmarks, nil
+	return &createdList, nil
 }
 
-// GetAllLists fetches all lists from Karakeep.
-func (c *Client) GetAllLists() ([]*List, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/lists", c.baseURL), nil)
+// AddBookmarkToList adds a bookmark to a list in Karakeep.
+func (c *Client) AddBookmarkToList(ctx context.Context, bookmarkID, listID string) error {
+	req, err := c.api.NewRequest(ctx, "POST", fmt.Sprintf("%s/lists/%s/bookmarks/%s", c.baseURL, listID, bookmarkID), nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
-
-	resp, err := c.doRequestWithRetry(req)
+	resp, err := c.api.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get lists: %s", resp.Status)
+		return apiError("add bookmark to list", resp)
 	}
 
-	var lists []*List
-	if err := json.NewDecoder(resp.Body).Decode(&lists); err != nil {
-		return nil, err
+	return nil
+}
+
+// RemoveBookmarkFromList removes a bookmark from a list in Karakeep. The
+// bookmark itself is kept.
+func (c *Client) RemoveBookmarkFromList(ctx context.Context, bookmarkID, listID string) error {
+	req, err := c.api.NewRequest(ctx, "DELETE", fmt.Sprintf("%s/lists/%s/bookmarks/%s", c.baseURL, listID, bookmarkID), nil)
+	if err != nil {
+		return err
 	}
 
-	return lists, nil
+	resp, err := c.api.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return apiError("remove bookmark from list", resp)
+	}
+
+	return nil
 }
 
 // DeleteBookmark deletes a bookmark from Karakeep.
-func (c *Client) DeleteBookmark(bookmarkID string) error {
-	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/bookmarks/%s", c.baseURL, bookmarkID), nil)
+func (c *Client) DeleteBookmark(ctx context.Context, bookmarkID string) error {
+	req, err := c.api.NewRequest(ctx, "DELETE", fmt.Sprintf("%s/bookmarks/%s", c.baseURL, bookmarkID), nil)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
-
-	resp, err := c.doRequestWithRetry(req)
+	resp, err := c.api.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("failed to delete bookmark: %s", resp.Status)
+		return apiError("delete bookmark", resp)
 	}
 
 	return nil
 }
 
 // DeleteList deletes a list from Karakeep.
-func (c *Client) DeleteList(listID string) error {
-	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/lists/%s", c.baseURL, listID), nil)
+func (c *Client) DeleteList(ctx context.Context, listID string) error {
+	req, err := c.api.NewRequest(ctx, "DELETE", fmt.Sprintf("%s/lists/%s", c.baseURL, listID), nil)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
-
-	resp, err := c.doRequestWithRetry(req)
+	resp, err := c.api.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("failed to delete list: %s", resp.Status)
+		return apiError("delete list", resp)
 	}
 
 	return nil
@@ -3,6 +3,7 @@
 package karakeep
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -80,7 +81,7 @@ func TestCreateBookmarkWithEmptyFields(t *testing.T) {
 			client := NewClient("test-token")
 			client.SetBaseURL(server.URL + "/v1")
 
-			_, err := client.CreateBookmark(tc.bookmark)
+			_, err := client.CreateBookmark(context.Background(), tc.bookmark)
 			if tc.expectError && err == nil {
 				t.Error("Expected error but got none")
 			} else if !tc.expectError && err != nil {
@@ -119,7 +120,7 @@ func TestCreateBookmarkWithSpecialCharacters(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL + "/v1")
 
-	created, err := client.CreateBookmark(specialBookmark)
+	created, err := client.CreateBookmark(context.Background(), specialBookmark)
 	if err != nil {
 		t.Fatalf("CreateBookmark failed: %v", err)
 	}
@@ -160,7 +161,7 @@ func TestCreateBookmarkWithUnicode(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL + "/v1")
 
-	created, err := client.CreateBookmark(unicodeBookmark)
+	created, err := client.CreateBookmark(context.Background(), unicodeBookmark)
 	if err != nil {
 		t.Fatalf("CreateBookmark failed: %v", err)
 	}
@@ -204,7 +205,7 @@ func TestCreateBookmarkWithVeryLongContent(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL + "/v1")
 
-	created, err := client.CreateBookmark(longBookmark)
+	created, err := client.CreateBookmark(context.Background(), longBookmark)
 	if err != nil {
 		t.Fatalf("CreateBookmark failed: %v", err)
 	}
@@ -237,7 +238,7 @@ func TestCreateListWithEmptyName(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL + "/v1")
 
-	created, err := client.CreateList(emptyList)
+	created, err := client.CreateList(context.Background(), emptyList)
 	if err != nil {
 		t.Fatalf("CreateList failed: %v", err)
 	}
@@ -270,7 +271,7 @@ func TestCreateListWithSpecialCharacters(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL + "/v1")
 
-	created, err := client.CreateList(specialList)
+	created, err := client.CreateList(context.Background(), specialList)
 	if err != nil {
 		t.Fatalf("CreateList failed: %v", err)
 	}
@@ -304,7 +305,7 @@ func TestCreateListWithUnicode(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL + "/v1")
 
-	created, err := client.CreateList(unicodeList)
+	created, err := client.CreateList(context.Background(), unicodeList)
 	if err != nil {
 		t.Fatalf("CreateList failed: %v", err)
 	}
@@ -374,7 +375,7 @@ func TestAddBookmarkToListWithInvalidIDs(t *testing.T) {
 			client := NewClient("test-token")
 			client.SetBaseURL(server.URL + "/v1")
 
-			err := client.AddBookmarkToList(tc.bookmarkID, tc.listID)
+			err := client.AddBookmarkToList(context.Background(), tc.bookmarkID, tc.listID)
 			if tc.expectError && err == nil {
 				t.Error("Expected error but got none")
 			} else if !tc.expectError && err != nil {
@@ -429,7 +430,7 @@ func TestCreateBookmarkWithMalformedResponse(t *testing.T) {
 			client := NewClient("test-token")
 			client.SetBaseURL(server.URL + "/v1")
 
-			_, err := client.CreateBookmark(bookmark)
+			_, err := client.CreateBookmark(context.Background(), bookmark)
 			if tc.expectError && err == nil {
 				t.Error("Expected error for malformed response, got nil")
 			} else if !tc.expectError && err != nil {
@@ -462,7 +463,7 @@ func TestClientWithInvalidAuthentication(t *testing.T) {
 	client.SetBaseURL(server.URL + "/v1")
 
 	bookmark := &Bookmark{URL: "https://example.com", Title: "Test"}
-	_, err := client.CreateBookmark(bookmark)
+	_, err := client.CreateBookmark(context.Background(), bookmark)
 	if err == nil {
 		t.Error("Expected error for invalid token, got nil")
 	}
@@ -474,7 +475,7 @@ func TestClientWithInvalidAuthentication(t *testing.T) {
 	client = NewClient("valid-token")
 	client.SetBaseURL(server.URL + "/v1")
 
-	created, err := client.CreateBookmark(bookmark)
+	created, err := client.CreateBookmark(context.Background(), bookmark)
 	if err != nil {
 		t.Errorf("Expected success with valid token, got error: %v", err)
 	}
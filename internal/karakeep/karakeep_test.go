@@ -3,8 +3,11 @@
 package karakeep
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -12,6 +15,9 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/ashebanow/rainbridge/internal/httpx"
+	"github.com/ashebanow/rainbridge/internal/retry"
 )
 
 // MockSleeper implements Sleeper interface for testing
@@ -75,9 +81,8 @@ func TestCreateBookmark(t *testing.T) {
 	defer server.Close()
 
 	client := &Client{
-		baseURL:    server.URL + "/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
 	}
 
 	bookmark := &Bookmark{
@@ -85,11 +90,84 @@ func TestCreateBookmark(t *testing.T) {
 		Title: "Test Bookmark",
 	}
 
-	if _, err := client.CreateBookmark(bookmark); err != nil {
+	if _, err := client.CreateBookmark(context.Background(), bookmark); err != nil {
 		t.Fatalf("CreateBookmark failed: %v", err)
 	}
 }
 
+func TestCreateBookmarkOmitsZeroCreatedAt(t *testing.T) {
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintln(w, `{"id": "bookmark-123"}`)
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
+	}
+
+	created := time.Date(2024, 1, 15, 8, 30, 0, 0, time.UTC)
+	bookmarks := []*Bookmark{
+		{URL: "https://example.com/a"},
+		{URL: "https://example.com/b", Note: "note", CreatedAt: created},
+	}
+	for _, bookmark := range bookmarks {
+		if _, err := client.CreateBookmark(context.Background(), bookmark); err != nil {
+			t.Fatalf("CreateBookmark failed: %v", err)
+		}
+	}
+
+	if _, ok := bodies[0]["createdAt"]; ok {
+		t.Errorf("Expected zero createdAt to be omitted, got %v", bodies[0]["createdAt"])
+	}
+	if _, ok := bodies[0]["note"]; ok {
+		t.Errorf("Expected empty note to be omitted, got %v", bodies[0]["note"])
+	}
+	if bodies[1]["createdAt"] != "2024-01-15T08:30:00Z" || bodies[1]["note"] != "note" {
+		t.Errorf("Unexpected payload: %v", bodies[1])
+	}
+}
+
+func TestGetCurrentUser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/users/me" {
+			t.Errorf("Expected path /v1/users/me, got %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintln(w, `{}`)
+			return
+		}
+		fmt.Fprintln(w, `{"id": "user-1", "name": "Test User", "email": "test@example.com"}`)
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
+	}
+
+	user, err := client.GetCurrentUser(context.Background())
+	if err != nil {
+		t.Fatalf("GetCurrentUser failed: %v", err)
+	}
+	if user.Name != "Test User" || user.Email != "test@example.com" {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+
+	client.api.Token = "wrong-token"
+	if _, err := client.GetCurrentUser(context.Background()); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized for a rejected token, got %v", err)
+	}
+}
+
 func TestCreateList(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
@@ -104,14 +182,13 @@ func TestCreateList(t *testing.T) {
 	defer server.Close()
 
 	client := &Client{
-		baseURL:    server.URL + "/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
 	}
 
 	list := &List{Name: "Test List"}
 
-	if _, err := client.CreateList(list); err != nil {
+	if _, err := client.CreateList(context.Background(), list); err != nil {
 		t.Fatalf("CreateList failed: %v", err)
 	}
 }
@@ -129,14 +206,209 @@ func TestAddBookmarkToList(t *testing.T) {
 	defer server.Close()
 
 	client := &Client{
-		baseURL:    server.URL + "/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
+	}
+
+	if err := client.AddBookmarkToList(context.Background(), "bookmark-456", "list-123"); err != nil {
+		t.Fatalf("AddBookmarkToList failed: %v", err)
+	}
+}
+
+func TestRemoveBookmarkFromList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Errorf("Expected DELETE request, got %s", r.Method)
+		}
+		if r.URL.Path != "/v1/lists/list-123/bookmarks/bookmark-456" {
+			t.Errorf("Expected path /v1/lists/list-123/bookmarks/bookmark-456, got %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
+	}
+
+	if err := client.RemoveBookmarkFromList(context.Background(), "bookmark-456", "list-123"); err != nil {
+		t.Fatalf("RemoveBookmarkFromList failed: %v", err)
+	}
+}
+
+func TestUpdateBookmark(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" {
+			t.Errorf("Expected PATCH request, got %s", r.Method)
+		}
+		if r.URL.Path != "/v1/bookmarks/bookmark-123" {
+			t.Errorf("Expected path /v1/bookmarks/bookmark-123, got %s", r.URL.Path)
+		}
+
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload["title"] != "New Title" {
+			t.Errorf("Expected title 'New Title', got %v", payload["title"])
+		}
+		if _, ok := payload["description"]; ok {
+			t.Error("Expected unset description to be omitted from the update")
+		}
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, `{"id": "bookmark-123", "title": "New Title"}`)
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
+	}
+
+	title := "New Title"
+	updated, err := client.UpdateBookmark(context.Background(), "bookmark-123", &BookmarkUpdate{Title: &title})
+	if err != nil {
+		t.Fatalf("UpdateBookmark failed: %v", err)
+	}
+	if updated.Title != "New Title" {
+		t.Errorf("Expected updated title 'New Title', got '%s'", updated.Title)
+	}
+}
+
+func TestCreateHighlight(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/highlights" {
+			t.Errorf("Expected POST /v1/highlights, got %s %s", r.Method, r.URL.Path)
+		}
+
+		var highlight Highlight
+		if err := json.NewDecoder(r.Body).Decode(&highlight); err != nil {
+			t.Fatal(err)
+		}
+		if highlight.BookmarkID != "bookmark-123" || highlight.Text != "Quoted" || highlight.Color != HighlightBlue {
+			t.Errorf("Unexpected highlight payload: %+v", highlight)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintln(w, `{"id": "highlight-1", "bookmarkId": "bookmark-123", "text": "Quoted"}`)
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
+	}
+
+	created, err := client.CreateHighlight(context.Background(), &Highlight{BookmarkID: "bookmark-123", Text: "Quoted", EndOffset: 6, Color: HighlightBlue})
+	if err != nil {
+		t.Fatalf("CreateHighlight failed: %v", err)
+	}
+	if created.ID != "highlight-1" {
+		t.Errorf("Expected highlight-1, got %q", created.ID)
+	}
+}
+
+func TestCreateHighlightUnsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintln(w, `{}`)
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
+	}
+
+	_, err := client.CreateHighlight(context.Background(), &Highlight{BookmarkID: "bookmark-123", Text: "Quoted"})
+	if !errors.Is(err, ErrHighlightsUnsupported) {
+		t.Errorf("Expected ErrHighlightsUnsupported, got %v", err)
+	}
+}
+
+func TestServerVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		expected    string
+		expectedErr error
+	}{
+		{"reports version", http.StatusOK, `{"version": "0.25.0"}`, "0.25.0", nil},
+		{"no version endpoint", http.StatusNotFound, `{}`, "", ErrVersionUnknown},
+		{"not json", http.StatusOK, `<html></html>`, "", ErrVersionUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/version" {
+					t.Errorf("Expected path /api/version, got %s", r.URL.Path)
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprintln(w, tt.body)
+			}))
+			defer server.Close()
+
+			client := &Client{
+				baseURL: server.URL + "/api/v1",
+				api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
+			}
+
+			version, err := client.ServerVersion(context.Background())
+			if version != tt.expected {
+				t.Errorf("Expected version %q, got %q", tt.expected, version)
+			}
+			if tt.expectedErr == nil && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected error wrapping %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestSetRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sleeper := &MockSleeper{}
+	client := NewClient("test-token")
+	client.SetBaseURL(server.URL)
+	client.SetSleeper(sleeper)
+	client.SetRateLimit(10, 1)
+
+	for range 3 {
+		if err := client.AddBookmarkToList(context.Background(), "bookmark-1", "list-1"); err != nil {
+			t.Fatalf("AddBookmarkToList failed: %v", err)
+		}
+	}
+
+	// The mock sleeper returns immediately, so the first request goes out at
+	// once and the next two wait for their slots 100ms and 200ms later.
+	sleeps := sleeper.GetSleeps()
+	if len(sleeps) != 2 {
+		t.Fatalf("Expected 2 sleeps, got %v", sleeps)
+	}
+	if sleeps[0] < 50*time.Millisecond || sleeps[0] > 100*time.Millisecond {
+		t.Errorf("Expected the second request to wait about 100ms, got %v", sleeps[0])
+	}
+	if sleeps[1] < 150*time.Millisecond || sleeps[1] > 200*time.Millisecond {
+		t.Errorf("Expected the third request to wait about 200ms, got %v", sleeps[1])
 	}
 
-	if err := client.AddBookmarkToList("bookmark-456", "list-123"); err != nil {
+	client.SetRateLimit(0, 0)
+	if err := client.AddBookmarkToList(context.Background(), "bookmark-1", "list-1"); err != nil {
 		t.Fatalf("AddBookmarkToList failed: %v", err)
 	}
+	if len(sleeper.GetSleeps()) != 2 {
+		t.Errorf("Expected no sleep without a rate limit, got %v", sleeper.GetSleeps())
+	}
 }
 
 func TestCreateBookmarkRateLimitingWithRetry(t *testing.T) {
@@ -157,10 +429,8 @@ func TestCreateBookmarkRateLimitingWithRetry(t *testing.T) {
 
 	mockSleeper := &MockSleeper{}
 	client := &Client{
-		baseURL:    server.URL + "/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
-		sleeper:    mockSleeper,
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token", Sleeper: mockSleeper},
 	}
 
 	bookmark := &Bookmark{
@@ -169,7 +439,7 @@ func TestCreateBookmarkRateLimitingWithRetry(t *testing.T) {
 	}
 
 	start := time.Now()
-	createdBookmark, err := client.CreateBookmark(bookmark)
+	createdBookmark, err := client.CreateBookmark(context.Background(), bookmark)
 	elapsed := time.Since(start)
 
 	if err != nil {
@@ -220,10 +490,8 @@ func TestCreateBookmarkRateLimitingMaxRetries(t *testing.T) {
 
 	mockSleeper := &MockSleeper{}
 	client := &Client{
-		baseURL:    server.URL + "/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
-		sleeper:    mockSleeper,
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token", Sleeper: mockSleeper},
 	}
 
 	bookmark := &Bookmark{
@@ -232,7 +500,7 @@ func TestCreateBookmarkRateLimitingMaxRetries(t *testing.T) {
 	}
 
 	start := time.Now()
-	_, err := client.CreateBookmark(bookmark)
+	_, err := client.CreateBookmark(context.Background(), bookmark)
 	elapsed := time.Since(start)
 
 	if err == nil {
@@ -274,16 +542,14 @@ func TestCreateListRateLimitingWithRetry(t *testing.T) {
 
 	mockSleeper := &MockSleeper{}
 	client := &Client{
-		baseURL:    server.URL + "/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
-		sleeper:    mockSleeper,
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token", Sleeper: mockSleeper},
 	}
 
 	list := &List{Name: "Test List"}
 
 	start := time.Now()
-	createdList, err := client.CreateList(list)
+	createdList, err := client.CreateList(context.Background(), list)
 	elapsed := time.Since(start)
 
 	if err != nil {
@@ -342,14 +608,12 @@ func TestAddBookmarkToListRateLimitingWithRetry(t *testing.T) {
 
 	mockSleeper := &MockSleeper{}
 	client := &Client{
-		baseURL:    server.URL + "/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
-		sleeper:    mockSleeper,
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token", Sleeper: mockSleeper},
 	}
 
 	start := time.Now()
-	err := client.AddBookmarkToList("bookmark-456", "list-123")
+	err := client.AddBookmarkToList(context.Background(), "bookmark-456", "list-123")
 	elapsed := time.Since(start)
 
 	if err != nil {
@@ -397,9 +661,8 @@ func TestNoRetryOnNon429Errors(t *testing.T) {
 	defer server.Close()
 
 	client := &Client{
-		baseURL:    server.URL + "/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
 	}
 
 	bookmark := &Bookmark{
@@ -407,7 +670,7 @@ func TestNoRetryOnNon429Errors(t *testing.T) {
 		Title: "Test Bookmark",
 	}
 
-	_, err := client.CreateBookmark(bookmark)
+	_, err := client.CreateBookmark(context.Background(), bookmark)
 	if err == nil {
 		t.Fatal("Expected error for 500 status, got nil")
 	}
@@ -426,10 +689,8 @@ func TestNoRetryOnNon429Errors(t *testing.T) {
 func createTestClient(handler http.HandlerFunc) (*Client, *httptest.Server) {
 	server := httptest.NewServer(handler)
 	client := &Client{
-		baseURL:    server.URL + "/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
-		sleeper:    &MockSleeper{}, // Use mock sleeper by default for faster tests
+		baseURL: server.URL + "/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token", Sleeper: &MockSleeper{}},
 	}
 	return client, server
 }
@@ -438,11 +699,7 @@ func createTestClient(handler http.HandlerFunc) (*Client, *httptest.Server) {
 func createTimeoutClient(timeout time.Duration) *Client {
 	return &Client{
 		baseURL: "https://api.karakeep.app/v1",
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
-		token:   "test-token",
-		sleeper: &MockSleeper{},
+		api:     &httpx.Client{HTTPClient: &http.Client{Timeout: timeout}, Token: "test-token", Sleeper: &MockSleeper{}},
 	}
 }
 
@@ -533,7 +790,7 @@ func TestCreateBookmarkHTTPErrors(t *testing.T) {
 				Title: "Test Bookmark",
 			}
 
-			_, err := client.CreateBookmark(bookmark)
+			_, err := client.CreateBookmark(context.Background(), bookmark)
 			if err == nil {
 				t.Fatalf("Expected error for status %d, got nil", tt.statusCode)
 			}
@@ -633,7 +890,7 @@ func TestCreateListHTTPErrors(t *testing.T) {
 
 			list := &List{Name: "Test List"}
 
-			_, err := client.CreateList(list)
+			_, err := client.CreateList(context.Background(), list)
 			if err == nil {
 				t.Fatalf("Expected error for status %d, got nil", tt.statusCode)
 			}
@@ -731,7 +988,7 @@ func TestAddBookmarkToListHTTPErrors(t *testing.T) {
 			})
 			defer server.Close()
 
-			err := client.AddBookmarkToList("bookmark-123", "list-456")
+			err := client.AddBookmarkToList(context.Background(), "bookmark-123", "list-456")
 			if err == nil {
 				t.Fatalf("Expected error for status %d, got nil", tt.statusCode)
 			}
@@ -761,7 +1018,7 @@ func TestNetworkTimeoutErrors(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -770,7 +1027,7 @@ func TestNetworkTimeoutErrors(t *testing.T) {
 			method: "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -778,7 +1035,7 @@ func TestNetworkTimeoutErrors(t *testing.T) {
 			name:   "AddBookmarkToList Timeout",
 			method: "AddBookmarkToList",
 			setupFunc: func(client *Client) error {
-				return client.AddBookmarkToList("bookmark-123", "list-456")
+				return client.AddBookmarkToList(context.Background(), "bookmark-123", "list-456")
 			},
 		},
 	}
@@ -826,7 +1083,7 @@ func TestInvalidJSONResponses(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -837,7 +1094,7 @@ func TestInvalidJSONResponses(t *testing.T) {
 			method:       "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -851,7 +1108,7 @@ func TestInvalidJSONResponses(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -862,7 +1119,7 @@ func TestInvalidJSONResponses(t *testing.T) {
 			method:       "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -876,7 +1133,7 @@ func TestInvalidJSONResponses(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -887,7 +1144,7 @@ func TestInvalidJSONResponses(t *testing.T) {
 			method:       "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -930,10 +1187,8 @@ func TestConnectionErrors(t *testing.T) {
 			name: "Connection Refused CreateBookmark",
 			setupClient: func() *Client {
 				return &Client{
-					baseURL:    "http://localhost:0", // Port 0 should refuse connections
-					httpClient: &http.Client{Timeout: 1 * time.Second},
-					token:      "test-token",
-					sleeper:    &MockSleeper{},
+					baseURL: "http://localhost:0",
+					api:     &httpx.Client{HTTPClient: &http.Client{Timeout: 1 * time.Second}, Token: "test-token", Sleeper: &MockSleeper{}},
 				}
 			},
 			expectedError: "connect:",
@@ -943,7 +1198,7 @@ func TestConnectionErrors(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -951,17 +1206,15 @@ func TestConnectionErrors(t *testing.T) {
 			name: "Connection Refused CreateList",
 			setupClient: func() *Client {
 				return &Client{
-					baseURL:    "http://localhost:0", // Port 0 should refuse connections
-					httpClient: &http.Client{Timeout: 1 * time.Second},
-					token:      "test-token",
-					sleeper:    &MockSleeper{},
+					baseURL: "http://localhost:0",
+					api:     &httpx.Client{HTTPClient: &http.Client{Timeout: 1 * time.Second}, Token: "test-token", Sleeper: &MockSleeper{}},
 				}
 			},
 			expectedError: "connect:",
 			method:        "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -969,26 +1222,22 @@ func TestConnectionErrors(t *testing.T) {
 			name: "Connection Refused AddBookmarkToList",
 			setupClient: func() *Client {
 				return &Client{
-					baseURL:    "http://localhost:0", // Port 0 should refuse connections
-					httpClient: &http.Client{Timeout: 1 * time.Second},
-					token:      "test-token",
-					sleeper:    &MockSleeper{},
+					baseURL: "http://localhost:0",
+					api:     &httpx.Client{HTTPClient: &http.Client{Timeout: 1 * time.Second}, Token: "test-token", Sleeper: &MockSleeper{}},
 				}
 			},
 			expectedError: "connect:",
 			method:        "AddBookmarkToList",
 			setupFunc: func(client *Client) error {
-				return client.AddBookmarkToList("bookmark-123", "list-456")
+				return client.AddBookmarkToList(context.Background(), "bookmark-123", "list-456")
 			},
 		},
 		{
 			name: "Invalid Host CreateBookmark",
 			setupClient: func() *Client {
 				return &Client{
-					baseURL:    "http://invalid-host-that-does-not-exist.local",
-					httpClient: &http.Client{Timeout: 1 * time.Second},
-					token:      "test-token",
-					sleeper:    &MockSleeper{},
+					baseURL: "http://invalid-host-that-does-not-exist.local",
+					api:     &httpx.Client{HTTPClient: &http.Client{Timeout: 1 * time.Second}, Token: "test-token", Sleeper: &MockSleeper{}},
 				}
 			},
 			expectedError: "deadline exceeded",
@@ -998,7 +1247,7 @@ func TestConnectionErrors(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -1006,17 +1255,15 @@ func TestConnectionErrors(t *testing.T) {
 			name: "Invalid Port CreateList",
 			setupClient: func() *Client {
 				return &Client{
-					baseURL:    "http://localhost:99999", // Invalid port
-					httpClient: &http.Client{Timeout: 1 * time.Second},
-					token:      "test-token",
-					sleeper:    &MockSleeper{},
+					baseURL: "http://localhost:99999",
+					api:     &httpx.Client{HTTPClient: &http.Client{Timeout: 1 * time.Second}, Token: "test-token", Sleeper: &MockSleeper{}},
 				}
 			},
 			expectedError: "invalid port",
 			method:        "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -1049,10 +1296,8 @@ func TestRequestCreationFailures(t *testing.T) {
 			name: "Invalid URL CreateBookmark",
 			setupClient: func() *Client {
 				return &Client{
-					baseURL:    "://invalid-url",
-					httpClient: &http.Client{},
-					token:      "test-token",
-					sleeper:    &MockSleeper{},
+					baseURL: "://invalid-url",
+					api:     &httpx.Client{HTTPClient: &http.Client{}, Token: "test-token", Sleeper: &MockSleeper{}},
 				}
 			},
 			method: "CreateBookmark",
@@ -1061,7 +1306,7 @@ func TestRequestCreationFailures(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -1069,16 +1314,14 @@ func TestRequestCreationFailures(t *testing.T) {
 			name: "Invalid URL CreateList",
 			setupClient: func() *Client {
 				return &Client{
-					baseURL:    "://invalid-url",
-					httpClient: &http.Client{},
-					token:      "test-token",
-					sleeper:    &MockSleeper{},
+					baseURL: "://invalid-url",
+					api:     &httpx.Client{HTTPClient: &http.Client{}, Token: "test-token", Sleeper: &MockSleeper{}},
 				}
 			},
 			method: "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -1086,15 +1329,13 @@ func TestRequestCreationFailures(t *testing.T) {
 			name: "Invalid URL AddBookmarkToList",
 			setupClient: func() *Client {
 				return &Client{
-					baseURL:    "://invalid-url",
-					httpClient: &http.Client{},
-					token:      "test-token",
-					sleeper:    &MockSleeper{},
+					baseURL: "://invalid-url",
+					api:     &httpx.Client{HTTPClient: &http.Client{}, Token: "test-token", Sleeper: &MockSleeper{}},
 				}
 			},
 			method: "AddBookmarkToList",
 			setupFunc: func(client *Client) error {
-				return client.AddBookmarkToList("bookmark-123", "list-456")
+				return client.AddBookmarkToList(context.Background(), "bookmark-123", "list-456")
 			},
 		},
 	}
@@ -1136,7 +1377,7 @@ func TestAuthenticationFailures(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -1148,7 +1389,7 @@ func TestAuthenticationFailures(t *testing.T) {
 			method:         "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -1159,7 +1400,7 @@ func TestAuthenticationFailures(t *testing.T) {
 			expectedError:  "failed to add bookmark to list: 401 Unauthorized",
 			method:         "AddBookmarkToList",
 			setupFunc: func(client *Client) error {
-				return client.AddBookmarkToList("bookmark-123", "list-456")
+				return client.AddBookmarkToList(context.Background(), "bookmark-123", "list-456")
 			},
 		},
 	}
@@ -1210,7 +1451,7 @@ func TestMalformedAPIResponses(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -1222,7 +1463,7 @@ func TestMalformedAPIResponses(t *testing.T) {
 			method:         "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -1237,7 +1478,7 @@ func TestMalformedAPIResponses(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -1249,7 +1490,7 @@ func TestMalformedAPIResponses(t *testing.T) {
 			method:         "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -1263,7 +1504,7 @@ func TestMalformedAPIResponses(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -1274,7 +1515,7 @@ func TestMalformedAPIResponses(t *testing.T) {
 			method:         "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -1328,7 +1569,7 @@ func TestLargeResponseHandling(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 			generateFunc: func(size int) string {
@@ -1343,7 +1584,7 @@ func TestLargeResponseHandling(t *testing.T) {
 			method:       "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 			generateFunc: func(size int) string {
@@ -1361,7 +1602,7 @@ func TestLargeResponseHandling(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 			generateFunc: func(size int) string {
@@ -1420,7 +1661,7 @@ func TestConcurrentRequests(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -1429,7 +1670,7 @@ func TestConcurrentRequests(t *testing.T) {
 			method: "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -1437,7 +1678,7 @@ func TestConcurrentRequests(t *testing.T) {
 			name:   "Concurrent AddBookmarkToList",
 			method: "AddBookmarkToList",
 			setupFunc: func(client *Client) error {
-				return client.AddBookmarkToList("bookmark-123", "list-456")
+				return client.AddBookmarkToList(context.Background(), "bookmark-123", "list-456")
 			},
 		},
 	}
@@ -1510,7 +1751,7 @@ func TestTruncatedResponseHandling(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -1522,7 +1763,7 @@ func TestTruncatedResponseHandling(t *testing.T) {
 			method:       "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -1537,7 +1778,7 @@ func TestTruncatedResponseHandling(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -1549,7 +1790,7 @@ func TestTruncatedResponseHandling(t *testing.T) {
 			method:       "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -1609,7 +1850,7 @@ func TestConcurrentErrorHandling(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -1620,7 +1861,7 @@ func TestConcurrentErrorHandling(t *testing.T) {
 			method:        "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -1630,7 +1871,7 @@ func TestConcurrentErrorHandling(t *testing.T) {
 			expectedError: "rate limited after 5 retries",
 			method:        "AddBookmarkToList",
 			setupFunc: func(client *Client) error {
-				return client.AddBookmarkToList("bookmark-123", "list-456")
+				return client.AddBookmarkToList(context.Background(), "bookmark-123", "list-456")
 			},
 		},
 	}
@@ -1695,7 +1936,7 @@ func TestRequestHeaderValidation(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -1707,7 +1948,7 @@ func TestRequestHeaderValidation(t *testing.T) {
 			method:        "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -1718,7 +1959,7 @@ func TestRequestHeaderValidation(t *testing.T) {
 			expectedAuth:  "Bearer valid-token-789",
 			method:        "AddBookmarkToList",
 			setupFunc: func(client *Client) error {
-				return client.AddBookmarkToList("bookmark-123", "list-456")
+				return client.AddBookmarkToList(context.Background(), "bookmark-123", "list-456")
 			},
 		},
 		{
@@ -1732,7 +1973,7 @@ func TestRequestHeaderValidation(t *testing.T) {
 					URL:   "https://example.com",
 					Title: "Test Bookmark",
 				}
-				_, err := client.CreateBookmark(bookmark)
+				_, err := client.CreateBookmark(context.Background(), bookmark)
 				return err
 			},
 		},
@@ -1744,7 +1985,7 @@ func TestRequestHeaderValidation(t *testing.T) {
 			method:        "CreateList",
 			setupFunc: func(client *Client) error {
 				list := &List{Name: "Test List"}
-				_, err := client.CreateList(list)
+				_, err := client.CreateList(context.Background(), list)
 				return err
 			},
 		},
@@ -1755,7 +1996,7 @@ func TestRequestHeaderValidation(t *testing.T) {
 			expectedAuth:  "Bearer token-with-unicode-∑∆∫",
 			method:        "AddBookmarkToList",
 			setupFunc: func(client *Client) error {
-				return client.AddBookmarkToList("bookmark-123", "list-456")
+				return client.AddBookmarkToList(context.Background(), "bookmark-123", "list-456")
 			},
 		},
 	}
@@ -1780,7 +2021,7 @@ func TestRequestHeaderValidation(t *testing.T) {
 			defer server.Close()
 
 			// Set the token
-			client.token = tt.token
+			client.api.Token = tt.token
 
 			err := tt.setupFunc(client)
 
@@ -1801,3 +2042,102 @@ func TestRequestHeaderValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateBookmarkRetriesTransientErrorsWithFullBody(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+
+		switch len(bodies) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintln(w, `{"id": "bookmark-123"}`)
+		}
+	}))
+	defer server.Close()
+
+	sleeper := &MockSleeper{}
+	client := NewClient("test-token")
+	client.SetBaseURL(server.URL + "/v1")
+	client.SetSleeper(sleeper)
+	client.SetRetryPolicy(retry.Default())
+
+	created, err := client.CreateBookmark(context.Background(), &Bookmark{URL: "https://example.com", Title: "Test Bookmark"})
+	if err != nil {
+		t.Fatalf("CreateBookmark failed: %v", err)
+	}
+	if created.ID != "bookmark-123" {
+		t.Errorf("Expected bookmark ID 'bookmark-123', got '%s'", created.ID)
+	}
+
+	if len(bodies) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(bodies))
+	}
+	for n, body := range bodies {
+		if !strings.Contains(body, `"url":"https://example.com"`) || body != bodies[0] {
+			t.Errorf("Attempt %d sent body %q, expected %q", n+1, body, bodies[0])
+		}
+	}
+	if sleeps := sleeper.GetSleeps(); len(sleeps) != 2 {
+		t.Errorf("Expected 2 sleep calls, got %v", sleeps)
+	}
+}
+
+func TestRetryPolicyGivesUpOnServerErrors(t *testing.T) {
+	var attemptCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attemptCount, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token")
+	client.SetBaseURL(server.URL + "/v1")
+	client.SetSleeper(&MockSleeper{})
+	client.SetRetryPolicy(retry.Policy{MaxRetries: 2, BaseDelay: time.Second, Transient: true})
+
+	_, err := client.GetCurrentUser(context.Background())
+	if err == nil || err.Error() != "failed to get current user: 502 Bad Gateway" {
+		t.Errorf("Expected the last server error, got %v", err)
+	}
+	if attemptCount != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attemptCount)
+	}
+
+	// Creating a list may have worked despite the 502, so it is not retried.
+	atomic.StoreInt32(&attemptCount, 0)
+	_, err = client.CreateList(context.Background(), &List{Name: "Test"})
+	if err == nil || err.Error() != "failed to create list: 502 Bad Gateway" {
+		t.Errorf("Expected the server error, got %v", err)
+	}
+	if attemptCount != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attemptCount)
+	}
+}
+
+func TestCancelledContextStopsRetrying(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token")
+	client.SetBaseURL(server.URL + "/v1")
+
+	start := time.Now()
+	_, err := client.CreateList(ctx, &List{Name: "Test"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the retry wait to be cut short, took %v", elapsed)
+	}
+}
@@ -0,0 +1,179 @@
+package karakeep
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+)
+
+// PageSize is the number of bookmarks requested per page, the most Karakeep
+// returns at once.
+const PageSize = 100
+
+// Tag represents a Karakeep tag.
+type Tag struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	NumBookmarks int    `json:"numBookmarks"`
+}
+
+// Bookmarks returns an iterator over all bookmarks in Karakeep, fetched one
+// page at a time as the iteration proceeds. An error ends the iteration.
+func (c *Client) Bookmarks(ctx context.Context) iter.Seq2[*Bookmark, error] {
+	return paginate[*Bookmark](ctx, c, "get bookmarks", "bookmarks", "bookmarks", url.Values{"limit": {fmt.Sprint(PageSize)}})
+}
+
+// ListBookmarks returns an iterator over the bookmarks in a list. An error
+// ends the iteration.
+func (c *Client) ListBookmarks(ctx context.Context, listID string) iter.Seq2[*Bookmark, error] {
+	return paginate[*Bookmark](ctx, c, "get list bookmarks", "lists/"+url.PathEscape(listID)+"/bookmarks", "bookmarks", url.Values{"limit": {fmt.Sprint(PageSize)}})
+}
+
+// Lists returns an iterator over all lists in Karakeep. An error ends the
+// iteration.
+func (c *Client) Lists(ctx context.Context) iter.Seq2[*List, error] {
+	return paginate[*List](ctx, c, "get lists", "lists", "lists", nil)
+}
+
+// Tags returns an iterator over all tags in Karakeep. An error ends the
+// iteration.
+func (c *Client) Tags(ctx context.Context) iter.Seq2[*Tag, error] {
+	return paginate[*Tag](ctx, c, "get tags", "tags", "tags", nil)
+}
+
+// Highlights returns an iterator over the highlights of all bookmarks. An
+// error ends the iteration. Servers without a highlights API answer with a
+// 404 error.
+func (c *Client) Highlights(ctx context.Context) iter.Seq2[*Highlight, error] {
+	return paginate[*Highlight](ctx, c, "get highlights", "highlights", "highlights", url.Values{"limit": {fmt.Sprint(PageSize)}})
+}
+
+// GetAllBookmarks fetches all bookmarks from Karakeep.
+func (c *Client) GetAllBookmarks(ctx context.Context) ([]*Bookmark, error) {
+	return collect(c.Bookmarks(ctx))
+}
+
+// GetAllLists fetches all lists from Karakeep.
+func (c *Client) GetAllLists(ctx context.Context) ([]*List, error) {
+	return collect(c.Lists(ctx))
+}
+
+// GetAllTags fetches all tags from Karakeep.
+func (c *Client) GetAllTags(ctx context.Context) ([]*Tag, error) {
+	return collect(c.Tags(ctx))
+}
+
+// paginate iterates over the items of the paginated endpoint at path, which
+// answers with an object holding a page of items under key and the cursor of
+// the next page, if any:
+//
+//	{"bookmarks": [...], "nextCursor": "..."}
+//
+// A bare array is accepted too, as the only page.
+func paginate[T any](ctx context.Context, c *Client, op, path, key string, query url.Values) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		cursor := ""
+		for {
+			items, next, err := c.getPage(ctx, op, path, key, query, cursor)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, raw := range items {
+				var item T
+				if err := json.Unmarshal(raw, &item); err != nil {
+					yield(zero, fmt.Errorf("failed to %s: %w", op, err))
+					return
+				}
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if next == "" {
+				return
+			}
+			if next == cursor {
+				yield(zero, fmt.Errorf("failed to %s: the server returned the same cursor %q twice", op, next))
+				return
+			}
+			cursor = next
+		}
+	}
+}
+
+// getPage fetches one page of the endpoint at path, starting at cursor.
+func (c *Client) getPage(ctx context.Context, op, path, key string, query url.Values, cursor string) ([]json.RawMessage, string, error) {
+	params := url.Values{}
+	for name, values := range query {
+		params[name] = values
+	}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+	endpoint := fmt.Sprintf("%s/%s", c.baseURL, path)
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	req, err := c.api.NewRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := c.api.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", apiError(op, resp)
+	}
+
+	var body json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, "", err
+	}
+
+	var items []json.RawMessage
+	if json.Unmarshal(body, &items) == nil {
+		return items, "", nil
+	}
+
+	var page map[string]json.RawMessage
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, "", fmt.Errorf("failed to %s: %w", op, err)
+	}
+	if raw, ok := page[key]; ok {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, "", fmt.Errorf("failed to %s: %w", op, err)
+		}
+	}
+	var next *string
+	if raw, ok := page["nextCursor"]; ok {
+		if err := json.Unmarshal(raw, &next); err != nil {
+			return nil, "", fmt.Errorf("failed to %s: %w", op, err)
+		}
+	}
+	if next == nil {
+		return items, "", nil
+	}
+	return items, *next, nil
+}
+
+// collect gathers the items of an iterator into a slice, stopping at the
+// first error.
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var items []T
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
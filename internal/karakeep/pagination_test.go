@@ -0,0 +1,188 @@
+//go:build !integration
+
+package karakeep
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBookmarksFollowsCursor(t *testing.T) {
+	var cursors []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/bookmarks" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if limit := r.URL.Query().Get("limit"); limit != fmt.Sprint(PageSize) {
+			t.Errorf("Expected a limit of %d, got %q", PageSize, limit)
+		}
+		cursor := r.URL.Query().Get("cursor")
+		cursors = append(cursors, cursor)
+		switch cursor {
+		case "":
+			fmt.Fprintln(w, `{"bookmarks": [
+				{"id": "b1", "title": "One", "favourited": true, "tags": [{"id": "t1", "name": "go", "attachedBy": "human"}],
+				 "content": {"type": "link", "url": "https://example.com/1", "description": "First"}},
+				{"id": "b2", "title": null, "content": {"type": "link", "url": "https://example.com/2"}}
+			], "nextCursor": "page-2"}`)
+		case "page-2":
+			fmt.Fprintln(w, `{"bookmarks": [{"id": "b3", "url": "https://example.com/3", "tags": ["flat"]}], "nextCursor": null}`)
+		default:
+			t.Errorf("Unexpected cursor %q", cursor)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token")
+	client.SetBaseURL(server.URL + "/v1")
+
+	bookmarks, err := client.GetAllBookmarks(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllBookmarks failed: %v", err)
+	}
+	if !slices.Equal(cursors, []string{"", "page-2"}) {
+		t.Errorf("Expected two pages to be fetched, got cursors %q", cursors)
+	}
+	if len(bookmarks) != 3 {
+		t.Fatalf("Expected 3 bookmarks, got %d", len(bookmarks))
+	}
+
+	first := bookmarks[0]
+	if first.ID != "b1" || first.URL != "https://example.com/1" || first.Description != "First" || !first.Favourited {
+		t.Errorf("Unexpected first bookmark: %+v", first)
+	}
+	if !slices.Equal(first.Tags, []string{"go"}) {
+		t.Errorf("Expected the tag objects to be decoded by name, got %q", first.Tags)
+	}
+	if bookmarks[1].URL != "https://example.com/2" || bookmarks[1].Title != "" {
+		t.Errorf("Unexpected second bookmark: %+v", bookmarks[1])
+	}
+	if bookmarks[2].URL != "https://example.com/3" || !slices.Equal(bookmarks[2].Tags, []string{"flat"}) {
+		t.Errorf("Unexpected third bookmark: %+v", bookmarks[2])
+	}
+}
+
+func TestBookmarksStopsWhenIterationStops(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `{"bookmarks": [{"id": "b%d"}, {"id": "c%d"}], "nextCursor": "page-%d"}`, requests, requests, requests+1)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token")
+	client.SetBaseURL(server.URL + "/v1")
+
+	for bookmark, err := range client.Bookmarks(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bookmark.ID == "b2" {
+			break
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Expected no page to be fetched after the iteration stopped, got %d requests", requests)
+	}
+}
+
+func TestBookmarksRejectsRepeatedCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"bookmarks": [{"id": "b1"}], "nextCursor": "stuck"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token")
+	client.SetBaseURL(server.URL + "/v1")
+
+	_, err := client.GetAllBookmarks(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "same cursor") {
+		t.Errorf("Expected a repeated cursor to be an error, got %v", err)
+	}
+}
+
+func TestGetAllListsAndTags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/lists":
+			fmt.Fprintln(w, `{"lists": [{"id": "l1", "name": "Reading", "parentId": null}, {"id": "l2", "name": "Go", "parentId": "l1"}]}`)
+		case "/v1/tags":
+			fmt.Fprintln(w, `{"tags": [{"id": "t1", "name": "go", "numBookmarks": 3}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token")
+	client.SetBaseURL(server.URL + "/v1")
+
+	lists, err := client.GetAllLists(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllLists failed: %v", err)
+	}
+	if len(lists) != 2 || lists[0].ParentID != "" || lists[1].ParentID != "l1" {
+		t.Errorf("Unexpected lists: %+v %+v", lists[0], lists[1])
+	}
+
+	tags, err := client.GetAllTags(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllTags failed: %v", err)
+	}
+	if len(tags) != 1 || tags[0].Name != "go" || tags[0].NumBookmarks != 3 {
+		t.Errorf("Unexpected tags: %+v", tags)
+	}
+}
+
+func TestGetAllListsReportsHTTPErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token")
+	client.SetBaseURL(server.URL + "/v1")
+
+	_, err := client.GetAllLists(context.Background())
+	if err == nil || err.Error() != "failed to get lists: 500 Internal Server Error" {
+		t.Errorf("Expected an API error, got %v", err)
+	}
+}
+
+func TestListBookmarksAndHighlights(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/lists/l1/bookmarks":
+			fmt.Fprintln(w, `{"bookmarks": [{"id": "b1", "content": {"type": "link", "url": "https://example.com/1", "title": "Crawled"}}], "nextCursor": null}`)
+		case "/v1/highlights":
+			fmt.Fprintln(w, `{"highlights": [{"id": "h1", "bookmarkId": "b1", "text": "Quote", "color": "green"}], "nextCursor": null}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token")
+	client.SetBaseURL(server.URL + "/v1")
+
+	bookmarks, err := collect(client.ListBookmarks(context.Background(), "l1"))
+	if err != nil {
+		t.Fatalf("ListBookmarks failed: %v", err)
+	}
+	if len(bookmarks) != 1 || bookmarks[0].ID != "b1" || bookmarks[0].Title != "Crawled" {
+		t.Errorf("Expected the list's bookmark with its crawled title, got %+v", bookmarks)
+	}
+
+	highlights, err := collect(client.Highlights(context.Background()))
+	if err != nil {
+		t.Fatalf("Highlights failed: %v", err)
+	}
+	if len(highlights) != 1 || highlights[0].BookmarkID != "b1" || highlights[0].Color != HighlightGreen {
+		t.Errorf("Unexpected highlights %+v", highlights)
+	}
+}
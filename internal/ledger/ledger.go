@@ -0,0 +1,294 @@
+package ledger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// currentVersion is the on-disk format version written by Save.
+const currentVersion = 1
+
+// ListRecord describes the Karakeep list a Raindrop collection was imported into.
+type ListRecord struct {
+	KarakeepID string `json:"karakeepId"`
+	// Existing is set when the list already existed in Karakeep and was
+	// reused rather than created by the import.
+	Existing bool `json:"existing,omitempty"`
+}
+
+// BookmarkRecord describes a Raindrop bookmark that has been written to Karakeep.
+type BookmarkRecord struct {
+	KarakeepID   string `json:"karakeepId"`
+	CollectionID int64  `json:"collectionId"`
+	// ListID is set once the bookmark has been added to its Karakeep list.
+	ListID string `json:"listId,omitempty"`
+	// Existing is set when the bookmark already existed in Karakeep and was
+	// reused rather than created by the import.
+	Existing bool `json:"existing,omitempty"`
+	// Removed is set when the Raindrop bookmark was deleted and the Karakeep
+	// bookmark was archived by a sync.
+	Removed bool `json:"removed,omitempty"`
+}
+
+// data is the serialized form of a Ledger.
+type data struct {
+	Version   int                       `json:"version"`
+	Lists     map[int64]*ListRecord     `json:"lists"`
+	Bookmarks map[int64]*BookmarkRecord `json:"bookmarks"`
+	LastSync  time.Time                 `json:"lastSync,omitzero"`
+}
+
+// Ledger records which Raindrop collections and bookmarks have already been
+// imported into Karakeep, so that an interrupted import can resume where it
+// stopped instead of starting over. It is safe for concurrent use.
+type Ledger struct {
+	mu   sync.Mutex
+	path string
+	data data
+}
+
+// New creates an empty, in-memory ledger. Save is a no-op for such a ledger.
+func New() *Ledger {
+	return &Ledger{data: newData()}
+}
+
+// Open loads the ledger stored at path. A missing file yields an empty ledger
+// that will be created on the first Save.
+func Open(path string) (*Ledger, error) {
+	l := &Ledger{path: path, data: newData()}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+
+	if err := json.Unmarshal(content, &l.data); err != nil {
+		return nil, fmt.Errorf("failed to parse ledger %s: %w", path, err)
+	}
+	if l.data.Version > currentVersion {
+		return nil, fmt.Errorf("ledger %s has unsupported version %d", path, l.data.Version)
+	}
+	if l.data.Lists == nil {
+		l.data.Lists = make(map[int64]*ListRecord)
+	}
+	if l.data.Bookmarks == nil {
+		l.data.Bookmarks = make(map[int64]*BookmarkRecord)
+	}
+
+	return l, nil
+}
+
+func newData() data {
+	return data{
+		Version:   currentVersion,
+		Lists:     make(map[int64]*ListRecord),
+		Bookmarks: make(map[int64]*BookmarkRecord),
+	}
+}
+
+// Path returns the file backing the ledger, or "" for an in-memory ledger.
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// ListID returns the Karakeep list ID recorded for a Raindrop collection.
+func (l *Ledger) ListID(collectionID int64) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec, ok := l.data.Lists[collectionID]
+	if !ok {
+		return "", false
+	}
+	return rec.KarakeepID, true
+}
+
+// List returns the record for a Raindrop collection, if it has been imported.
+func (l *Ledger) List(collectionID int64) (ListRecord, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec, ok := l.data.Lists[collectionID]
+	if !ok {
+		return ListRecord{}, false
+	}
+	return *rec, true
+}
+
+// RecordList records the Karakeep list created for a Raindrop collection.
+func (l *Ledger) RecordList(collectionID int64, listID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.data.Lists[collectionID] = &ListRecord{KarakeepID: listID}
+}
+
+// RecordExistingList records a pre-existing Karakeep list that a Raindrop
+// collection was mapped onto.
+func (l *Ledger) RecordExistingList(collectionID int64, listID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.data.Lists[collectionID] = &ListRecord{KarakeepID: listID, Existing: true}
+}
+
+// Bookmark returns the record for a Raindrop bookmark, if it has been imported.
+func (l *Ledger) Bookmark(raindropID int64) (BookmarkRecord, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec, ok := l.data.Bookmarks[raindropID]
+	if !ok {
+		return BookmarkRecord{}, false
+	}
+	return *rec, true
+}
+
+// RecordBookmark records the Karakeep bookmark created for a Raindrop bookmark.
+func (l *Ledger) RecordBookmark(raindropID, collectionID int64, karakeepID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.data.Bookmarks[raindropID] = &BookmarkRecord{
+		KarakeepID:   karakeepID,
+		CollectionID: collectionID,
+	}
+}
+
+// RecordExistingBookmark records a pre-existing Karakeep bookmark that a
+// Raindrop bookmark was matched with.
+func (l *Ledger) RecordExistingBookmark(raindropID, collectionID int64, karakeepID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.data.Bookmarks[raindropID] = &BookmarkRecord{
+		KarakeepID:   karakeepID,
+		CollectionID: collectionID,
+		Existing:     true,
+	}
+}
+
+// RecordMembership records that a bookmark has been added to a Karakeep list.
+func (l *Ledger) RecordMembership(raindropID int64, listID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rec, ok := l.data.Bookmarks[raindropID]; ok {
+		rec.ListID = listID
+	}
+}
+
+// RecordMove records that a Raindrop bookmark was moved to another
+// collection. The bookmark is no longer in a Karakeep list until
+// RecordMembership is called again.
+func (l *Ledger) RecordMove(raindropID, collectionID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rec, ok := l.data.Bookmarks[raindropID]; ok {
+		rec.CollectionID = collectionID
+		rec.ListID = ""
+	}
+}
+
+// RecordRemoved records whether a Raindrop bookmark was deleted and its
+// Karakeep bookmark archived, or restored again.
+func (l *Ledger) RecordRemoved(raindropID int64, removed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rec, ok := l.data.Bookmarks[raindropID]; ok {
+		rec.Removed = removed
+	}
+}
+
+// Lists returns a copy of all list records, keyed by Raindrop collection ID.
+func (l *Ledger) Lists() map[int64]ListRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lists := make(map[int64]ListRecord, len(l.data.Lists))
+	for id, rec := range l.data.Lists {
+		lists[id] = *rec
+	}
+	return lists
+}
+
+// Bookmarks returns a copy of all bookmark records, keyed by Raindrop bookmark ID.
+func (l *Ledger) Bookmarks() map[int64]BookmarkRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bookmarks := make(map[int64]BookmarkRecord, len(l.data.Bookmarks))
+	for id, rec := range l.data.Bookmarks {
+		bookmarks[id] = *rec
+	}
+	return bookmarks
+}
+
+// ForgetList removes the record for a Raindrop collection.
+func (l *Ledger) ForgetList(collectionID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.data.Lists, collectionID)
+}
+
+// ForgetBookmark removes the record for a Raindrop bookmark.
+func (l *Ledger) ForgetBookmark(raindropID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.data.Bookmarks, raindropID)
+}
+
+// LastSync returns the start time of the last import or sync that ran to the
+// end without failures, or the zero time if there was none.
+func (l *Ledger) LastSync() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.data.LastSync
+}
+
+// RecordSync records the start time of an import or sync that ran to the end
+// without failures.
+func (l *Ledger) RecordSync(start time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.data.LastSync = start
+}
+
+// Counts returns the number of lists and bookmarks recorded in the ledger.
+func (l *Ledger) Counts() (lists, bookmarks int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.data.Lists), len(l.data.Bookmarks)
+}
+
+// Save writes the ledger to disk. The file is replaced atomically so that a
+// crash during Save never leaves a truncated ledger behind.
+func (l *Ledger) Save() error {
+	if l.path == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	content, err := json.MarshalIndent(l.data, "", "  ")
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to save ledger: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save ledger: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save ledger: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return fmt.Errorf("failed to save ledger: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+//go:build !integration
+
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	lists, bookmarks := l.Counts()
+	if lists != 0 || bookmarks != 0 {
+		t.Errorf("Expected empty ledger, got %d lists and %d bookmarks", lists, bookmarks)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected Open not to create the file, stat returned %v", err)
+	}
+}
+
+func TestSaveAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	l.RecordList(1, "list-1")
+	l.RecordBookmark(101, 1, "bookmark-101")
+	l.RecordMembership(101, "list-1")
+	l.RecordBookmark(102, 1, "bookmark-102")
+	synced := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	l.RecordSync(synced)
+
+	if err := l.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open after save failed: %v", err)
+	}
+
+	if listID, ok := reopened.ListID(1); !ok || listID != "list-1" {
+		t.Errorf("Expected list-1 for collection 1, got %q (found=%v)", listID, ok)
+	}
+
+	rec, ok := reopened.Bookmark(101)
+	if !ok {
+		t.Fatal("Expected bookmark 101 to be recorded")
+	}
+	if rec.KarakeepID != "bookmark-101" || rec.CollectionID != 1 || rec.ListID != "list-1" {
+		t.Errorf("Unexpected record for bookmark 101: %+v", rec)
+	}
+
+	rec, ok = reopened.Bookmark(102)
+	if !ok {
+		t.Fatal("Expected bookmark 102 to be recorded")
+	}
+	if rec.ListID != "" {
+		t.Errorf("Expected bookmark 102 to have no list membership, got %q", rec.ListID)
+	}
+
+	if _, ok := reopened.Bookmark(103); ok {
+		t.Error("Expected bookmark 103 to be absent")
+	}
+
+	if !reopened.LastSync().Equal(synced) {
+		t.Errorf("Expected the last sync to be %v, got %v", synced, reopened.LastSync())
+	}
+}
+
+func TestSaveLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "checkpoint.json")
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	l.RecordList(1, "list-1")
+
+	for n := 0; n < 3; n++ {
+		if err := l.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the ledger file in %s, got %d entries", dir, len(entries))
+	}
+}
+
+func TestOpenCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if _, err := Open(path); err == nil {
+		t.Error("Expected error opening corrupt ledger, got nil")
+	}
+}
+
+func TestInMemoryLedgerSaveIsNoop(t *testing.T) {
+	l := New()
+	l.RecordList(1, "list-1")
+
+	if err := l.Save(); err != nil {
+		t.Errorf("Save on in-memory ledger returned %v", err)
+	}
+	if l.Path() != "" {
+		t.Errorf("Expected empty path, got %q", l.Path())
+	}
+}
+
+func TestSnapshotsAndForget(t *testing.T) {
+	l := New()
+	l.RecordList(1, "list-1")
+	l.RecordExistingList(2, "list-2")
+	l.RecordBookmark(101, 1, "bookmark-101")
+	l.RecordExistingBookmark(102, 2, "bookmark-102")
+
+	lists := l.Lists()
+	if len(lists) != 2 || lists[1].KarakeepID != "list-1" || !lists[2].Existing {
+		t.Errorf("Unexpected lists: %+v", lists)
+	}
+	bookmarks := l.Bookmarks()
+	if len(bookmarks) != 2 || bookmarks[101].KarakeepID != "bookmark-101" || !bookmarks[102].Existing {
+		t.Errorf("Unexpected bookmarks: %+v", bookmarks)
+	}
+
+	// The snapshots are copies.
+	rec := bookmarks[101]
+	rec.ListID = "changed"
+	bookmarks[101] = rec
+	if stored, _ := l.Bookmark(101); stored.ListID != "" {
+		t.Errorf("Expected snapshot changes not to affect the ledger, got %+v", stored)
+	}
+
+	l.ForgetList(1)
+	l.ForgetBookmark(101)
+	if lists, bookmarks := l.Counts(); lists != 1 || bookmarks != 1 {
+		t.Errorf("Expected 1 list and 1 bookmark after forgetting, got %d and %d", lists, bookmarks)
+	}
+	if _, ok := l.ListID(1); ok {
+		t.Error("Expected list 1 to be forgotten")
+	}
+}
+
+func TestRecordMoveAndRemoved(t *testing.T) {
+	l := New()
+	l.RecordBookmark(101, 1, "bookmark-101")
+	l.RecordMembership(101, "list-1")
+
+	l.RecordMove(101, 2)
+	if rec, _ := l.Bookmark(101); rec.CollectionID != 2 || rec.ListID != "" {
+		t.Errorf("Expected the bookmark to be moved out of its list, got %+v", rec)
+	}
+
+	l.RecordRemoved(101, true)
+	if rec, _ := l.Bookmark(101); !rec.Removed {
+		t.Errorf("Expected the bookmark to be marked removed, got %+v", rec)
+	}
+	l.RecordRemoved(101, false)
+	if rec, _ := l.Bookmark(101); rec.Removed {
+		t.Errorf("Expected the bookmark to be restored, got %+v", rec)
+	}
+}
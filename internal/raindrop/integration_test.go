@@ -3,6 +3,7 @@
 package raindrop
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -19,7 +20,7 @@ func TestIntegrationGetRaindrops(t *testing.T) {
 
 	client := NewClient(token)
 
-	_, err := client.GetRaindrops()
+	_, err := client.GetRaindrops(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to get raindrops: %v", err)
 	}
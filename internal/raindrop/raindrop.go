@@ -1,41 +1,51 @@
 package raindrop
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"math/rand"
+	"iter"
 	"net/http"
+	"net/url"
 	"time"
+
+	"github.com/ashebanow/rainbridge/internal/httpx"
+	"github.com/ashebanow/rainbridge/internal/ratelimit"
+	"github.com/ashebanow/rainbridge/internal/retry"
 )
 
-// Sleeper interface for dependency injection of sleep functionality
-type Sleeper interface {
-	Sleep(duration time.Duration)
-}
+// Sleeper waits between retries. Tests replace it to avoid sleeping.
+type Sleeper = httpx.Sleeper
 
 // RealSleeper implements Sleeper using time.Sleep
-type RealSleeper struct{}
+type RealSleeper = httpx.RealSleeper
+
+// System collection IDs. These collections are never returned by GetCollections,
+// but their bookmarks can be fetched with GetRaindropsByCollection.
+const (
+	// AllCollectionID lists the bookmarks of every collection except Trash.
+	AllCollectionID int64 = 0
+	// UnsortedCollectionID is the collection of bookmarks not filed in any collection.
+	UnsortedCollectionID int64 = -1
+	// TrashCollectionID is the collection of deleted bookmarks.
+	TrashCollectionID int64 = -99
+)
 
-func (r RealSleeper) Sleep(duration time.Duration) {
-	time.Sleep(duration)
-}
+// DefaultBaseURL is the Raindrop.io REST API endpoint.
+const DefaultBaseURL = "https://api.raindrop.io/rest/v1"
 
 // Client is the Raindrop.io API client.
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	token      string
-	sleeper    Sleeper
+	baseURL string
+	api     *httpx.Client
 }
 
 // NewClient creates a new Raindrop.io API client.
 func NewClient(token string) *Client {
 	return &Client{
-		baseURL:    "https://api.raindrop.io/rest/v1",
-		httpClient: &http.Client{},
-		token:      token,
-		sleeper:    RealSleeper{},
+		baseURL: DefaultBaseURL,
+		api:     httpx.New(token),
 	}
 }
 
@@ -46,139 +56,319 @@ func (c *Client) SetBaseURL(baseURL string) {
 
 // SetHTTPClient sets the HTTP client for the Raindrop.io API client.
 func (c *Client) SetHTTPClient(httpClient *http.Client) {
-	c.httpClient = httpClient
+	c.api.HTTPClient = httpClient
 }
 
 // SetSleeper sets the sleeper for the Raindrop.io API client.
 func (c *Client) SetSleeper(sleeper Sleeper) {
-	c.sleeper = sleeper
+	c.api.Sleeper = sleeper
 }
 
-// doRequestWithRetry performs an HTTP request with exponential backoff retry logic.
-func (c *Client) doRequestWithRetry(req *http.Request) (*http.Response, error) {
-	const maxRetries = 5
-	baseDelay := time.Second
-
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			return nil, err
-		}
+// SetRateLimit limits the client to requestsPerSecond requests per second,
+// in bursts of up to burst requests, shared by all goroutines using it. A
+// value of zero or less removes the limit. Either way, the client slows down
+// when the server reports that its quota is running out.
+func (c *Client) SetRateLimit(requestsPerSecond float64, burst int) {
+	c.api.Limiter = ratelimit.New(requestsPerSecond, burst)
+}
 
-		// If not rate limited, return the response
-		if resp.StatusCode != http.StatusTooManyRequests {
-			return resp, nil
-		}
+// SetRetryPolicy sets how the client retries failed requests. Without a
+// policy, only rate limited requests are retried.
+func (c *Client) SetRetryPolicy(policy retry.Policy) {
+	c.api.Retry = &policy
+}
 
-		// Close the response body since we're retrying
-		resp.Body.Close()
+// SetUserAgent sets the User-Agent header sent with every request.
+func (c *Client) SetUserAgent(userAgent string) {
+	c.api.UserAgent = userAgent
+}
 
-		// If this was the last attempt, return the rate limit error
-		if attempt == maxRetries {
-			return nil, fmt.Errorf("rate limited after %d retries: %s", maxRetries, resp.Status)
-		}
+// Transport returns the HTTP transport the client sends its requests with,
+// for settings that have no setter of their own, such as request hooks.
+func (c *Client) Transport() *httpx.Client {
+	return c.api
+}
 
-		// Calculate delay with exponential backoff and jitter
-		delay := baseDelay * time.Duration(1<<uint(attempt))
-		jitter := time.Duration(rand.Float64() * float64(delay) * 0.1) // 10% jitter
-		sleepDuration := delay + jitter
+// ErrUnauthorized is wrapped by the error of every request that Raindrop.io
+// rejects because of the API token.
+var ErrUnauthorized = errors.New("raindrop.io rejected the API token")
 
-		log.Printf("Rate limited (429), retrying in %v (attempt %d/%d)", sleepDuration, attempt+1, maxRetries)
-		c.sleeper.Sleep(sleepDuration)
+// apiError returns the error for an unexpected response to the request
+// described by op, wrapping ErrUnauthorized when the token was rejected.
+func apiError(op string, resp *http.Response) error {
+	err := httpx.NewAPIError(op, resp)
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		err.Err = ErrUnauthorized
 	}
+	return err
+}
 
-	// This should never be reached due to the loop structure
-	return nil, fmt.Errorf("unexpected error in retry logic")
+// User represents a Raindrop.io user account.
+type User struct {
+	ID       int64  `json:"_id"`
+	FullName string `json:"fullName"`
+	Email    string `json:"email"`
 }
 
 // Raindrop represents a Raindrop.io bookmark.
 type Raindrop struct {
-	ID      int64    `json:"_id"`
-	Title   string   `json:"title"`
-	Excerpt string   `json:"excerpt"`
-	Link    string   `json:"link"`
-	Tags    []string `json:"tags"`
+	ID         int64       `json:"_id"`
+	Title      string      `json:"title"`
+	Excerpt    string      `json:"excerpt"`
+	Note       string      `json:"note"`
+	Link       string      `json:"link"`
+	Domain     string      `json:"domain"`
+	Type       string      `json:"type"`
+	Cover      string      `json:"cover"`
+	Media      []Media     `json:"media"`
+	Tags       []string    `json:"tags"`
+	Highlights []Highlight `json:"highlights"`
+	Important  bool        `json:"important"`
+	Created    time.Time   `json:"created"`
+	LastUpdate time.Time   `json:"lastUpdate"`
+	// Collection is the collection the bookmark is filed in.
+	Collection CollectionRef `json:"collection"`
+}
+
+// Highlight is a passage highlighted in a Raindrop.io bookmark, with an
+// optional annotation.
+type Highlight struct {
+	ID      string    `json:"_id"`
+	Text    string    `json:"text"`
+	Note    string    `json:"note"`
+	Color   string    `json:"color"`
+	Created time.Time `json:"created"`
+}
+
+// Media is an image or other media item attached to a Raindrop.io bookmark.
+type Media struct {
+	Link string `json:"link"`
+	Type string `json:"type"`
 }
 
 // Collection represents a Raindrop.io collection.
 type Collection struct {
-	ID    int64  `json:"_id"`
-	Title string `json:"title"`
+	ID     int64          `json:"_id"`
+	Title  string         `json:"title"`
+	Parent *CollectionRef `json:"parent,omitempty"`
+}
+
+// CollectionRef is a reference to another Raindrop.io collection.
+type CollectionRef struct {
+	ID int64 `json:"$id"`
+}
+
+// ParentID returns the ID of the collection's parent, or 0 for a root collection.
+func (c Collection) ParentID() int64 {
+	if c.Parent == nil {
+		return 0
+	}
+	return c.Parent.ID
 }
 
 // GetRaindrops fetches all bookmarks from Raindrop.io.
-func (c *Client) GetRaindrops() ([]Raindrop, error) {
-	return c.GetRaindropsByCollection(0)
+func (c *Client) GetRaindrops(ctx context.Context) ([]Raindrop, error) {
+	return c.GetRaindropsByCollection(ctx, AllCollectionID)
+}
+
+// MaxPageSize is the most bookmarks Raindrop.io returns per page.
+const MaxPageSize = 50
+
+// PageOptions selects the pages of a collection that Raindrops fetches.
+type PageOptions struct {
+	// StartPage is the first page to fetch, counting from 0.
+	StartPage int
+	// PageSize is the number of bookmarks per page. Zero or more than
+	// MaxPageSize means MaxPageSize.
+	PageSize int
+	// Sort orders the bookmarks, such as "-lastUpdate" for the most recently
+	// changed first. Empty means the Raindrop.io default, newest first.
+	Sort string
+	// Search only returns the bookmarks matching a Raindrop.io search query.
+	Search string
 }
 
 // GetRaindropsByCollection fetches all bookmarks from a specific collection in Raindrop.io.
-func (c *Client) GetRaindropsByCollection(collectionID int64) ([]Raindrop, error) {
+func (c *Client) GetRaindropsByCollection(ctx context.Context, collectionID int64) ([]Raindrop, error) {
 	var allRaindrops []Raindrop
-	page := 0
-	for {
-		req, err := http.NewRequest("GET", fmt.Sprintf("%s/raindrops/%d?page=%d&perpage=50", c.baseURL, collectionID, page), nil)
+	for raindrop, err := range c.Raindrops(ctx, collectionID, PageOptions{}) {
 		if err != nil {
 			return nil, err
 		}
+		allRaindrops = append(allRaindrops, raindrop)
+	}
+	return allRaindrops, nil
+}
 
-		req.Header.Set("Authorization", "Bearer "+c.token)
+// Raindrops returns an iterator over the bookmarks of a collection. Pages
+// are fetched as the iteration reaches them, until an empty page is
+// returned, so that callers can work on the first bookmarks while the rest
+// are still being downloaded. An error ends the iteration.
+func (c *Client) Raindrops(ctx context.Context, collectionID int64, opts PageOptions) iter.Seq2[Raindrop, error] {
+	pageSize := opts.PageSize
+	if pageSize <= 0 || pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
 
-		resp, err := c.doRequestWithRetry(req)
-		if err != nil {
-			return nil, err
-		}
-		
-		// Process response and ensure body is closed immediately
-		response, err := func() (struct{ Items []Raindrop `json:"items"` }, error) {
-			defer resp.Body.Close()
-			
-			if resp.StatusCode != http.StatusOK {
-				return struct{ Items []Raindrop `json:"items"` }{}, fmt.Errorf("failed to get raindrops: %s", resp.Status)
+	return func(yield func(Raindrop, error) bool) {
+		for page := opts.StartPage; ; page++ {
+			items, err := c.getRaindropsPage(ctx, collectionID, page, pageSize, opts)
+			if err != nil {
+				yield(Raindrop{}, err)
+				return
 			}
-
-			var response struct {
-				Items []Raindrop `json:"items"`
+			if len(items) == 0 {
+				return
 			}
-
-			if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-				return response, err
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
 			}
-
-			return response, nil
-		}()
-		
-		if err != nil {
-			return nil, err
 		}
+	}
+}
 
-		if len(response.Items) == 0 {
-			break
-		}
+// getRaindropsPage fetches one page of the bookmarks of a collection, sorted
+// and filtered as opts says.
+func (c *Client) getRaindropsPage(ctx context.Context, collectionID int64, page, pageSize int, opts PageOptions) ([]Raindrop, error) {
+	query := url.Values{}
+	query.Set("page", fmt.Sprint(page))
+	query.Set("perpage", fmt.Sprint(pageSize))
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.Search != "" {
+		query.Set("search", opts.Search)
+	}
 
-		allRaindrops = append(allRaindrops, response.Items...)
-		page++
+	req, err := c.api.NewRequest(ctx, "GET", fmt.Sprintf("%s/raindrops/%d?%s", c.baseURL, collectionID, query.Encode()), nil)
+	if err != nil {
+		return nil, err
 	}
 
-	return allRaindrops, nil
+	resp, err := c.api.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError("get raindrops", resp)
+	}
+
+	var response struct {
+		Items []Raindrop `json:"items"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return response.Items, nil
+}
+
+// GetRaindrop fetches a single bookmark by ID.
+func (c *Client) GetRaindrop(ctx context.Context, id int64) (*Raindrop, error) {
+	req, err := c.api.NewRequest(ctx, "GET", fmt.Sprintf("%s/raindrop/%d", c.baseURL, id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.api.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError("get raindrop", resp)
+	}
+
+	var response struct {
+		Item Raindrop `json:"item"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return &response.Item, nil
+}
+
+// GetUser fetches the user the API token belongs to. It is a cheap way to
+// check that the token is valid, and returns an error wrapping
+// ErrUnauthorized when it is not.
+func (c *Client) GetUser(ctx context.Context) (*User, error) {
+	req, err := c.api.NewRequest(ctx, "GET", fmt.Sprintf("%s/user", c.baseURL), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.api.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError("get user", resp)
+	}
+
+	var response struct {
+		User User `json:"user"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return &response.User, nil
 }
 
 // GetCollections fetches all collections from Raindrop.io.
-func (c *Client) GetCollections() ([]Collection, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/collections", c.baseURL), nil)
+func (c *Client) GetCollections(ctx context.Context) ([]Collection, error) {
+	req, err := c.api.NewRequest(ctx, "GET", fmt.Sprintf("%s/collections", c.baseURL), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.api.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError("get collections", resp)
+	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
+	var response struct {
+		Items []Collection `json:"items"`
+	}
 
-	resp, err := c.doRequestWithRetry(req)
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return response.Items, nil
+}
+
+// GetChildCollections fetches all nested (non-root) collections from Raindrop.io.
+func (c *Client) GetChildCollections(ctx context.Context) ([]Collection, error) {
+	req, err := c.api.NewRequest(ctx, "GET", fmt.Sprintf("%s/collections/childrens", c.baseURL), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.api.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get collections: %s", resp.Status)
+		return nil, apiError("get child collections", resp)
 	}
 
 	var response struct {
@@ -191,3 +381,117 @@ func (c *Client) GetCollections() ([]Collection, error) {
 
 	return response.Items, nil
 }
+
+// GetAllCollections fetches the root collections followed by all nested collections.
+func (c *Client) GetAllCollections(ctx context.Context) ([]Collection, error) {
+	roots, err := c.GetCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := c.GetChildCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(roots, children...), nil
+}
+
+// MaxBatchSize is the most bookmarks CreateRaindrops creates at once.
+const MaxBatchSize = 100
+
+// NewRaindrop is a bookmark to create with CreateRaindrops.
+type NewRaindrop struct {
+	Link       string         `json:"link"`
+	Title      string         `json:"title,omitempty"`
+	Excerpt    string         `json:"excerpt,omitempty"`
+	Note       string         `json:"note,omitempty"`
+	Tags       []string       `json:"tags,omitempty"`
+	Important  bool           `json:"important,omitempty"`
+	Created    time.Time      `json:"created,omitzero"`
+	Collection CollectionRef  `json:"collection"`
+	Highlights []NewHighlight `json:"highlights,omitempty"`
+}
+
+// NewHighlight is a highlight to create along with a NewRaindrop.
+type NewHighlight struct {
+	Text  string `json:"text"`
+	Note  string `json:"note,omitempty"`
+	Color string `json:"color,omitempty"`
+}
+
+// CreateCollection creates a collection in Raindrop.io, nested in the
+// collection parentID, or at the root when parentID is 0.
+func (c *Client) CreateCollection(ctx context.Context, title string, parentID int64) (*Collection, error) {
+	body := struct {
+		Title  string         `json:"title"`
+		Parent *CollectionRef `json:"parent,omitempty"`
+	}{Title: title}
+	if parentID != 0 {
+		body.Parent = &CollectionRef{ID: parentID}
+	}
+
+	req, err := c.api.NewRequest(ctx, "POST", fmt.Sprintf("%s/collection", c.baseURL), body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.api.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, apiError("create collection", resp)
+	}
+
+	var response struct {
+		Item Collection `json:"item"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return &response.Item, nil
+}
+
+// CreateRaindrops creates up to MaxBatchSize bookmarks in Raindrop.io with a
+// single request, and returns them in the order they were given.
+func (c *Client) CreateRaindrops(ctx context.Context, items []NewRaindrop) ([]Raindrop, error) {
+	if len(items) > MaxBatchSize {
+		return nil, fmt.Errorf("cannot create %d raindrops at once, the limit is %d", len(items), MaxBatchSize)
+	}
+
+	body := struct {
+		Items []NewRaindrop `json:"items"`
+	}{Items: items}
+	req, err := c.api.NewRequest(ctx, "POST", fmt.Sprintf("%s/raindrops", c.baseURL), body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.api.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, apiError("create raindrops", resp)
+	}
+
+	var response struct {
+		Items []Raindrop `json:"items"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	if len(response.Items) != len(items) {
+		return nil, fmt.Errorf("failed to create raindrops: sent %d, got %d back", len(items), len(response.Items))
+	}
+
+	return response.Items, nil
+}
@@ -3,6 +3,7 @@
 package raindrop
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -22,7 +23,7 @@ func TestGetRaindropsWithEmptyResponse(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL)
 
-	raindrops, err := client.GetRaindrops()
+	raindrops, err := client.GetRaindrops(context.Background())
 	if err != nil {
 		t.Fatalf("GetRaindrops failed: %v", err)
 	}
@@ -43,7 +44,7 @@ func TestGetRaindropsWithMalformedJSON(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL)
 
-	_, err := client.GetRaindrops()
+	_, err := client.GetRaindrops(context.Background())
 	if err == nil {
 		t.Error("Expected error for malformed JSON, got nil")
 	}
@@ -81,7 +82,7 @@ func TestGetRaindropsWithUnicodeData(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL)
 
-	raindrops, err := client.GetRaindrops()
+	raindrops, err := client.GetRaindrops(context.Background())
 	if err != nil {
 		t.Fatalf("GetRaindrops failed: %v", err)
 	}
@@ -138,7 +139,7 @@ func TestGetRaindropsWithLargePageSize(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL)
 
-	raindrops, err := client.GetRaindrops()
+	raindrops, err := client.GetRaindrops(context.Background())
 	if err != nil {
 		t.Fatalf("GetRaindrops failed: %v", err)
 	}
@@ -172,7 +173,7 @@ func TestGetCollectionsWithEmptyResponse(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL)
 
-	collections, err := client.GetCollections()
+	collections, err := client.GetCollections(context.Background())
 	if err != nil {
 		t.Fatalf("GetCollections failed: %v", err)
 	}
@@ -203,7 +204,7 @@ func TestGetCollectionsWithSpecialCharacters(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL)
 
-	collections, err := client.GetCollections()
+	collections, err := client.GetCollections(context.Background())
 	if err != nil {
 		t.Fatalf("GetCollections failed: %v", err)
 	}
@@ -258,7 +259,7 @@ func TestGetRaindropsWithNullFields(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL)
 
-	raindrops, err := client.GetRaindrops()
+	raindrops, err := client.GetRaindrops(context.Background())
 	if err != nil {
 		t.Fatalf("GetRaindrops failed: %v", err)
 	}
@@ -320,7 +321,7 @@ func TestGetRaindropsWithExtremelyLongContent(t *testing.T) {
 	client := NewClient("test-token")
 	client.SetBaseURL(server.URL)
 
-	raindrops, err := client.GetRaindrops()
+	raindrops, err := client.GetRaindrops(context.Background())
 	if err != nil {
 		t.Fatalf("GetRaindrops failed: %v", err)
 	}
@@ -359,7 +360,7 @@ func TestGetRaindropsWithInvalidToken(t *testing.T) {
 	client := NewClient("invalid-token")
 	client.SetBaseURL(server.URL)
 
-	_, err := client.GetRaindrops()
+	_, err := client.GetRaindrops(context.Background())
 	if err == nil {
 		t.Error("Expected error for invalid token, got nil")
 	}
@@ -371,7 +372,7 @@ func TestGetRaindropsWithInvalidToken(t *testing.T) {
 	client = NewClient("valid-token")
 	client.SetBaseURL(server.URL)
 
-	raindrops, err := client.GetRaindrops()
+	raindrops, err := client.GetRaindrops(context.Background())
 	if err != nil {
 		t.Errorf("Expected success with valid token, got error: %v", err)
 	}
@@ -3,14 +3,21 @@
 package raindrop
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/ashebanow/rainbridge/internal/httpx"
+	"github.com/ashebanow/rainbridge/internal/retry"
 )
 
 // MockSleeper implements Sleeper interface for testing
@@ -65,12 +72,11 @@ func TestGetRaindrops(t *testing.T) {
 	defer server.Close()
 
 	client := &Client{
-		baseURL:    server.URL + "/rest/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
+		baseURL: server.URL + "/rest/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
 	}
 
-	raindrops, err := client.GetRaindrops()
+	raindrops, err := client.GetRaindrops(context.Background())
 	if err != nil {
 		t.Fatalf("GetRaindrops failed: %v", err)
 	}
@@ -84,6 +90,132 @@ func TestGetRaindrops(t *testing.T) {
 	}
 }
 
+func TestGetRaindropsDecodesAllFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "0" {
+			fmt.Fprintln(w, `{"items": []}`)
+			return
+		}
+		fmt.Fprintln(w, `{"items": [{
+			"_id": 1,
+			"title": "Article",
+			"excerpt": "Summary",
+			"note": "My thoughts",
+			"link": "https://example.com/article",
+			"domain": "example.com",
+			"type": "article",
+			"cover": "https://example.com/cover.png",
+			"media": [{"link": "https://example.com/cover.png", "type": "image"}],
+			"tags": ["go"],
+			"highlights": [{"_id": "h1", "text": "Quoted", "note": "Why", "color": "yellow", "created": "2024-02-01T10:00:00Z"}],
+			"important": true,
+			"created": "2024-01-15T08:30:00.000Z",
+			"lastUpdate": "2024-03-01T12:00:00.000Z"
+		}]}`)
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL: server.URL + "/rest/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
+	}
+
+	raindrops, err := client.GetRaindropsByCollection(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("GetRaindropsByCollection failed: %v", err)
+	}
+	if len(raindrops) != 1 {
+		t.Fatalf("Expected 1 raindrop, got %d", len(raindrops))
+	}
+
+	r := raindrops[0]
+	if r.Note != "My thoughts" || r.Domain != "example.com" || r.Type != "article" || r.Cover != "https://example.com/cover.png" {
+		t.Errorf("Unexpected scalar fields: %+v", r)
+	}
+	if !r.Important {
+		t.Error("Expected Important to be true")
+	}
+	if len(r.Media) != 1 || r.Media[0].Type != "image" {
+		t.Errorf("Unexpected media: %+v", r.Media)
+	}
+	if len(r.Highlights) != 1 || r.Highlights[0].Text != "Quoted" || r.Highlights[0].Note != "Why" || r.Highlights[0].Color != "yellow" {
+		t.Errorf("Unexpected highlights: %+v", r.Highlights)
+	}
+	if want := time.Date(2024, 1, 15, 8, 30, 0, 0, time.UTC); !r.Created.Equal(want) {
+		t.Errorf("Expected created %v, got %v", want, r.Created)
+	}
+	if want := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC); !r.LastUpdate.Equal(want) {
+		t.Errorf("Expected lastUpdate %v, got %v", want, r.LastUpdate)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/user" {
+			t.Errorf("Expected path /rest/v1/user, got %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintln(w, `{}`)
+			return
+		}
+		fmt.Fprintln(w, `{"result": true, "user": {"_id": 42, "fullName": "Test User", "email": "test@example.com"}}`)
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL: server.URL + "/rest/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
+	}
+
+	user, err := client.GetUser(context.Background())
+	if err != nil {
+		t.Fatalf("GetUser failed: %v", err)
+	}
+	if user.FullName != "Test User" || user.Email != "test@example.com" {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+
+	client.api.Token = "wrong-token"
+	if _, err := client.GetUser(context.Background()); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized for a rejected token, got %v", err)
+	}
+}
+
+func TestGetRaindrop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/raindrop/7" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintln(w, `{"result": false}`)
+			return
+		}
+		fmt.Fprintln(w, `{"result": true, "item": {"_id": 7, "title": "Seven", "link": "https://example.com/7"}}`)
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL: server.URL + "/rest/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
+	}
+
+	item, err := client.GetRaindrop(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("GetRaindrop failed: %v", err)
+	}
+	if item.ID != 7 || item.Link != "https://example.com/7" {
+		t.Errorf("Unexpected raindrop: %+v", item)
+	}
+
+	_, err = client.GetRaindrop(context.Background(), 8)
+	var statusErr *httpx.APIError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected an APIError with status 404, got %v", err)
+	}
+	if err.Error() != "failed to get raindrop: 404 Not Found" {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
+
 func TestGetCollections(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/rest/v1/collections" {
@@ -95,12 +227,11 @@ func TestGetCollections(t *testing.T) {
 	defer server.Close()
 
 	client := &Client{
-		baseURL:    server.URL + "/rest/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
+		baseURL: server.URL + "/rest/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
 	}
 
-	collections, err := client.GetCollections()
+	collections, err := client.GetCollections(context.Background())
 	if err != nil {
 		t.Fatalf("GetCollections failed: %v", err)
 	}
@@ -114,6 +245,97 @@ func TestGetCollections(t *testing.T) {
 	}
 }
 
+func TestGetChildCollections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/collections/childrens" {
+			t.Errorf("Expected path /rest/v1/collections/childrens, got %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, `{"items": [{"_id": 456, "title": "Child", "parent": {"$ref": "collections", "$id": 123}}]}`)
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL: server.URL + "/rest/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
+	}
+
+	collections, err := client.GetChildCollections(context.Background())
+	if err != nil {
+		t.Fatalf("GetChildCollections failed: %v", err)
+	}
+
+	if len(collections) != 1 {
+		t.Fatalf("Expected 1 collection, got %d", len(collections))
+	}
+
+	if collections[0].ParentID() != 123 {
+		t.Errorf("Expected parent ID 123, got %d", collections[0].ParentID())
+	}
+}
+
+func TestGetAllCollections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Path == "/rest/v1/collections" {
+			fmt.Fprintln(w, `{"items": [{"_id": 123, "title": "Root"}]}`)
+		} else {
+			fmt.Fprintln(w, `{"items": [{"_id": 456, "title": "Child", "parent": {"$id": 123}}]}`)
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL: server.URL + "/rest/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
+	}
+
+	collections, err := client.GetAllCollections(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllCollections failed: %v", err)
+	}
+
+	if len(collections) != 2 {
+		t.Fatalf("Expected 2 collections, got %d", len(collections))
+	}
+
+	if collections[0].ParentID() != 0 || collections[1].ParentID() != 123 {
+		t.Errorf("Expected root then child, got parents %d and %d", collections[0].ParentID(), collections[1].ParentID())
+	}
+}
+
+func TestSetRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"user": {"_id": 1}}`)
+	}))
+	defer server.Close()
+
+	sleeper := &MockSleeper{}
+	client := NewClient("test-token")
+	client.SetBaseURL(server.URL)
+	client.SetSleeper(sleeper)
+	client.SetRateLimit(10, 1)
+
+	for range 3 {
+		if _, err := client.GetUser(context.Background()); err != nil {
+			t.Fatalf("GetUser failed: %v", err)
+		}
+	}
+
+	// The first request goes out at once and the next two wait for their
+	// slots 100ms and 200ms later.
+	sleeps := sleeper.GetSleeps()
+	if len(sleeps) != 2 {
+		t.Fatalf("Expected 2 sleeps, got %v", sleeps)
+	}
+	if sleeps[0] < 50*time.Millisecond || sleeps[0] > 100*time.Millisecond {
+		t.Errorf("Expected the second request to wait about 100ms, got %v", sleeps[0])
+	}
+	if sleeps[1] < 150*time.Millisecond || sleeps[1] > 200*time.Millisecond {
+		t.Errorf("Expected the third request to wait about 200ms, got %v", sleeps[1])
+	}
+}
+
 func TestRateLimitingWithRetry(t *testing.T) {
 	var attemptCount int32
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -132,14 +354,12 @@ func TestRateLimitingWithRetry(t *testing.T) {
 
 	mockSleeper := &MockSleeper{}
 	client := &Client{
-		baseURL:    server.URL + "/rest/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
-		sleeper:    mockSleeper,
+		baseURL: server.URL + "/rest/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token", Sleeper: mockSleeper},
 	}
 
 	start := time.Now()
-	collections, err := client.GetCollections()
+	collections, err := client.GetCollections(context.Background())
 	elapsed := time.Since(start)
 
 	if err != nil {
@@ -190,14 +410,12 @@ func TestRateLimitingMaxRetries(t *testing.T) {
 
 	mockSleeper := &MockSleeper{}
 	client := &Client{
-		baseURL:    server.URL + "/rest/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
-		sleeper:    mockSleeper,
+		baseURL: server.URL + "/rest/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token", Sleeper: mockSleeper},
 	}
 
 	start := time.Now()
-	_, err := client.GetCollections()
+	_, err := client.GetCollections(context.Background())
 	elapsed := time.Since(start)
 
 	if err == nil {
@@ -231,12 +449,11 @@ func TestNoRetryOnNon429Errors(t *testing.T) {
 	defer server.Close()
 
 	client := &Client{
-		baseURL:    server.URL + "/rest/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
+		baseURL: server.URL + "/rest/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token"},
 	}
 
-	_, err := client.GetCollections()
+	_, err := client.GetCollections(context.Background())
 	if err == nil {
 		t.Fatal("Expected error for 500 status, got nil")
 	}
@@ -251,10 +468,8 @@ func TestNoRetryOnNon429Errors(t *testing.T) {
 func createTestClient(handler http.HandlerFunc) (*Client, *httptest.Server) {
 	server := httptest.NewServer(handler)
 	client := &Client{
-		baseURL:    server.URL + "/rest/v1",
-		httpClient: server.Client(),
-		token:      "test-token",
-		sleeper:    &MockSleeper{}, // Use mock sleeper by default for faster tests
+		baseURL: server.URL + "/rest/v1",
+		api:     &httpx.Client{HTTPClient: server.Client(), Token: "test-token", Sleeper: &MockSleeper{}},
 	}
 	return client, server
 }
@@ -263,10 +478,7 @@ func createTestClient(handler http.HandlerFunc) (*Client, *httptest.Server) {
 func createTimeoutClient(timeout time.Duration) *Client {
 	return &Client{
 		baseURL: "https://api.raindrop.io/rest/v1",
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
-		token: "test-token",
+		api:     &httpx.Client{HTTPClient: &http.Client{Timeout: timeout}, Token: "test-token"},
 	}
 }
 
@@ -338,7 +550,7 @@ func TestHTTPErrorHandling(t *testing.T) {
 			})
 			defer server.Close()
 
-			_, err := client.GetCollections()
+			_, err := client.GetCollections(context.Background())
 			if err == nil {
 				t.Fatalf("Expected error for status %d, got nil", tt.statusCode)
 			}
@@ -394,7 +606,7 @@ func TestRaindropsHTTPErrorHandling(t *testing.T) {
 			})
 			defer server.Close()
 
-			_, err := client.GetRaindrops()
+			_, err := client.GetRaindrops(context.Background())
 			if err == nil {
 				t.Fatalf("Expected error for status %d, got nil", tt.statusCode)
 			}
@@ -421,7 +633,7 @@ func TestNetworkTimeout(t *testing.T) {
 	client := createTimeoutClient(100 * time.Millisecond)
 	client.SetBaseURL(server.URL + "/rest/v1")
 
-	_, err := client.GetCollections()
+	_, err := client.GetCollections(context.Background())
 	if err == nil {
 		t.Fatal("Expected timeout error, got nil")
 	}
@@ -475,9 +687,9 @@ func TestInvalidJSONResponse(t *testing.T) {
 
 			var err error
 			if tt.endpoint == "collections" {
-				_, err = client.GetCollections()
+				_, err = client.GetCollections(context.Background())
 			} else {
-				_, err = client.GetRaindrops()
+				_, err = client.GetRaindrops(context.Background())
 			}
 
 			if err == nil {
@@ -566,7 +778,7 @@ func TestEmptyAndNilResponses(t *testing.T) {
 			defer server.Close()
 
 			if tt.endpoint == "collections" {
-				collections, err := client.GetCollections()
+				collections, err := client.GetCollections(context.Background())
 				if tt.expectError {
 					if err == nil {
 						t.Fatal("Expected error, got nil")
@@ -580,7 +792,7 @@ func TestEmptyAndNilResponses(t *testing.T) {
 					t.Errorf("Expected empty collections, got %d items", len(collections))
 				}
 			} else {
-				raindrops, err := client.GetRaindrops()
+				raindrops, err := client.GetRaindrops(context.Background())
 				if tt.expectError {
 					if err == nil {
 						t.Fatal("Expected error, got nil")
@@ -662,7 +874,7 @@ func TestPaginationEdgeCases(t *testing.T) {
 			})
 			defer server.Close()
 
-			raindrops, err := client.GetRaindrops()
+			raindrops, err := client.GetRaindrops(context.Background())
 			if err != nil {
 				t.Fatalf("Unexpected error: %v", err)
 			}
@@ -678,6 +890,78 @@ func TestPaginationEdgeCases(t *testing.T) {
 	}
 }
 
+func TestRaindropsIterator(t *testing.T) {
+	var pages []string
+	client, server := createTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/raindrops/7" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if perpage := r.URL.Query().Get("perpage"); perpage != "2" {
+			t.Errorf("Expected a page size of 2, got %q", perpage)
+		}
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		switch page {
+		case "3":
+			fmt.Fprint(w, `{"items": [{"_id": 1}, {"_id": 2}]}`)
+		case "4":
+			fmt.Fprint(w, `{"items": [{"_id": 3}, {"_id": 4}]}`)
+		default:
+			fmt.Fprint(w, `{"items": []}`)
+		}
+	})
+	defer server.Close()
+
+	var ids []int64
+	for raindrop, err := range client.Raindrops(context.Background(), 7, PageOptions{StartPage: 3, PageSize: 2}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, raindrop.ID)
+	}
+	if !slices.Equal(ids, []int64{1, 2, 3, 4}) || !slices.Equal(pages, []string{"3", "4", "5"}) {
+		t.Errorf("Expected 4 bookmarks from pages 3 to 5, got %v from pages %v", ids, pages)
+	}
+
+	// Stopping the iteration stops fetching pages.
+	pages = nil
+	for raindrop := range client.Raindrops(context.Background(), 7, PageOptions{StartPage: 3, PageSize: 2}) {
+		if raindrop.ID == 2 {
+			break
+		}
+	}
+	if !slices.Equal(pages, []string{"3"}) {
+		t.Errorf("Expected only page 3 to be fetched, got %v", pages)
+	}
+}
+
+func TestRaindropsIteratorReportsErrors(t *testing.T) {
+	client, server := createTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "0" {
+			fmt.Fprint(w, `{"items": [{"_id": 1}]}`)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer server.Close()
+
+	var ids []int64
+	var lastErr error
+	for raindrop, err := range client.Raindrops(context.Background(), 0, PageOptions{}) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ids = append(ids, raindrop.ID)
+	}
+	if !slices.Equal(ids, []int64{1}) {
+		t.Errorf("Expected the first page to be yielded, got %v", ids)
+	}
+	if lastErr == nil || lastErr.Error() != "failed to get raindrops: 500 Internal Server Error" {
+		t.Errorf("Expected the error of the second page, got %v", lastErr)
+	}
+}
+
 func TestAuthenticationFailures(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -719,7 +1003,7 @@ func TestAuthenticationFailures(t *testing.T) {
 			})
 			defer server.Close()
 
-			_, err := client.GetCollections()
+			_, err := client.GetCollections(context.Background())
 			if err == nil {
 				t.Fatal("Expected authentication error, got nil")
 			}
@@ -811,9 +1095,9 @@ func TestMalformedAPIResponses(t *testing.T) {
 
 			var err error
 			if tt.endpoint == "collections" {
-				_, err = client.GetCollections()
+				_, err = client.GetCollections(context.Background())
 			} else {
-				_, err = client.GetRaindrops()
+				_, err = client.GetRaindrops(context.Background())
 			}
 
 			if tt.expectError {
@@ -916,7 +1200,7 @@ func TestLargeResponseHandling(t *testing.T) {
 			start := time.Now()
 			
 			if tt.endpoint == "collections" {
-				collections, err := client.GetCollections()
+				collections, err := client.GetCollections(context.Background())
 				if err != nil {
 					t.Fatalf("Unexpected error: %v", err)
 				}
@@ -924,7 +1208,7 @@ func TestLargeResponseHandling(t *testing.T) {
 					t.Errorf("Expected %d collections, got %d", tt.expectedCount, len(collections))
 				}
 			} else {
-				raindrops, err := client.GetRaindrops()
+				raindrops, err := client.GetRaindrops(context.Background())
 				if err != nil {
 					t.Fatalf("Unexpected error: %v", err)
 				}
@@ -965,7 +1249,7 @@ func TestConcurrentRequests(t *testing.T) {
 	// Start multiple goroutines making requests
 	for i := 0; i < numGoroutines; i++ {
 		go func() {
-			_, err := client.GetCollections()
+			_, err := client.GetCollections(context.Background())
 			errChan <- err
 		}()
 	}
@@ -994,12 +1278,11 @@ func TestConcurrentRequests(t *testing.T) {
 func TestRequestCreationFailure(t *testing.T) {
 	// Test with invalid URL to trigger request creation failure
 	client := &Client{
-		baseURL:    "://invalid-url",
-		httpClient: &http.Client{},
-		token:      "test-token",
+		baseURL: "://invalid-url",
+		api:     &httpx.Client{HTTPClient: &http.Client{}, Token: "test-token"},
 	}
 	
-	_, err := client.GetCollections()
+	_, err := client.GetCollections(context.Background())
 	if err == nil {
 		t.Fatal("Expected error for invalid URL, got nil")
 	}
@@ -1020,9 +1303,8 @@ func TestConnectionErrors(t *testing.T) {
 			name: "Connection Refused",
 			setupClient: func() *Client {
 				return &Client{
-					baseURL:    "http://localhost:0", // Port 0 should refuse connections
-					httpClient: &http.Client{Timeout: 1 * time.Second},
-					token:      "test-token",
+					baseURL: "http://localhost:0",
+					api:     &httpx.Client{HTTPClient: &http.Client{Timeout: 1 * time.Second}, Token: "test-token"},
 				}
 			},
 			expectedError: "connect:", // More general connection error
@@ -1031,9 +1313,8 @@ func TestConnectionErrors(t *testing.T) {
 			name: "Invalid Host",
 			setupClient: func() *Client {
 				return &Client{
-					baseURL:    "http://invalid-host-that-does-not-exist.local",
-					httpClient: &http.Client{Timeout: 1 * time.Second},
-					token:      "test-token",
+					baseURL: "http://invalid-host-that-does-not-exist.local",
+					api:     &httpx.Client{HTTPClient: &http.Client{Timeout: 1 * time.Second}, Token: "test-token"},
 				}
 			},
 			expectedError: "deadline exceeded", // DNS resolution or connection timeout
@@ -1042,9 +1323,8 @@ func TestConnectionErrors(t *testing.T) {
 			name: "Invalid Port",
 			setupClient: func() *Client {
 				return &Client{
-					baseURL:    "http://localhost:99999", // Invalid port
-					httpClient: &http.Client{Timeout: 1 * time.Second},
-					token:      "test-token",
+					baseURL: "http://localhost:99999",
+					api:     &httpx.Client{HTTPClient: &http.Client{Timeout: 1 * time.Second}, Token: "test-token"},
 				}
 			},
 			expectedError: "invalid port",
@@ -1055,7 +1335,7 @@ func TestConnectionErrors(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			client := tt.setupClient()
 			
-			_, err := client.GetCollections()
+			_, err := client.GetCollections(context.Background())
 			if err == nil {
 				t.Fatal("Expected connection error, got nil")
 			}
@@ -1124,9 +1404,9 @@ func TestTruncatedResponseHandling(t *testing.T) {
 
 			var err error
 			if tt.endpoint == "collections" {
-				_, err = client.GetCollections()
+				_, err = client.GetCollections(context.Background())
 			} else {
-				_, err = client.GetRaindrops()
+				_, err = client.GetRaindrops(context.Background())
 			}
 
 			if err == nil {
@@ -1207,13 +1487,13 @@ func TestRequestHeaderValidation(t *testing.T) {
 			defer server.Close()
 
 			// Set the token
-			client.token = tt.token
+			client.api.Token = tt.token
 
 			var err error
 			if tt.endpoint == "collections" {
-				_, err = client.GetCollections()
+				_, err = client.GetCollections(context.Background())
 			} else {
-				_, err = client.GetRaindrops()
+				_, err = client.GetRaindrops(context.Background())
 			}
 
 			if tt.expectError {
@@ -1235,28 +1515,35 @@ func TestRequestHeaderValidation(t *testing.T) {
 }
 
 func TestRateLimitWithRetryAfterHeader(t *testing.T) {
-	// Note: The current implementation doesn't actually handle Retry-After headers
-	// This test verifies that the exponential backoff still works even when 
-	// Retry-After headers are present (they are ignored)
+	// The client waits as long as Retry-After asks, and falls back to
+	// exponential backoff when the header cannot be parsed
 	tests := []struct {
 		name             string
 		retryAfterHeader string
 		expectError      bool
+		minDelay         time.Duration
+		maxDelay         time.Duration
 	}{
 		{
 			name:             "Retry-After Seconds",
 			retryAfterHeader: "2",
 			expectError:      false,
+			minDelay:         2 * time.Second,
+			maxDelay:         2 * time.Second,
 		},
 		{
-			name:             "Retry-After HTTP Date", 
-			retryAfterHeader: time.Now().Add(2*time.Second).Format(time.RFC1123),
+			name:             "Retry-After HTTP Date",
+			retryAfterHeader: time.Now().UTC().Add(3 * time.Second).Format(http.TimeFormat),
 			expectError:      false,
+			minDelay:         time.Second,
+			maxDelay:         3 * time.Second,
 		},
 		{
 			name:             "Invalid Retry-After",
 			retryAfterHeader: "invalid",
 			expectError:      false,
+			minDelay:         900 * time.Millisecond,
+			maxDelay:         1100 * time.Millisecond,
 		},
 	}
 
@@ -1281,10 +1568,10 @@ func TestRateLimitWithRetryAfterHeader(t *testing.T) {
 			defer server.Close()
 
 			// Replace the client's sleeper with our controlled mock
-			client.sleeper = mockSleeper
+			client.api.Sleeper = mockSleeper
 
 			start := time.Now()
-			_, err := client.GetCollections()
+			_, err := client.GetCollections(context.Background())
 			elapsed := time.Since(start)
 
 			if tt.expectError {
@@ -1303,16 +1590,15 @@ func TestRateLimitWithRetryAfterHeader(t *testing.T) {
 				t.Errorf("Expected 2 attempts, got %d", attemptCount)
 			}
 
-			// Verify we attempted to sleep (exponential backoff, ignoring Retry-After)
+			// Verify we slept once, for as long as the server asked
 			sleeps := mockSleeper.GetSleeps()
 			if len(sleeps) != 1 {
 				t.Errorf("Expected 1 sleep call, got %d", len(sleeps))
 			}
 
-			// Verify exponential backoff pattern (approximately 1s with jitter)
 			if len(sleeps) > 0 {
-				if sleeps[0] < 900*time.Millisecond || sleeps[0] > 1100*time.Millisecond {
-					t.Errorf("Delay should be ~1s, got %v", sleeps[0])
+				if sleeps[0] < tt.minDelay || sleeps[0] > tt.maxDelay {
+					t.Errorf("Delay should be between %v and %v, got %v", tt.minDelay, tt.maxDelay, sleeps[0])
 				}
 			}
 
@@ -1324,6 +1610,41 @@ func TestRateLimitWithRetryAfterHeader(t *testing.T) {
 	}
 }
 
+func TestRateLimitWaitsUntilReset(t *testing.T) {
+	reset := time.Now().Add(30 * time.Second).Unix()
+	var attemptCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "120")
+		if atomic.AddInt32(&attemptCount, 1) == 1 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("X-RateLimit-Remaining", "119")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset+60))
+		fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Success"}]}`)
+	}))
+	defer server.Close()
+
+	mockSleeper := &MockSleeper{}
+	client := NewClient("test-token")
+	client.SetBaseURL(server.URL + "/rest/v1")
+	client.SetSleeper(mockSleeper)
+
+	if _, err := client.GetCollections(context.Background()); err != nil {
+		t.Fatalf("GetCollections failed: %v", err)
+	}
+
+	sleeps := mockSleeper.GetSleeps()
+	if len(sleeps) != 1 {
+		t.Fatalf("Expected 1 sleep, got %v", sleeps)
+	}
+	if sleeps[0] < 28*time.Second || sleeps[0] > 30*time.Second {
+		t.Errorf("Expected to sleep until the reset about 30s away, got %v", sleeps[0])
+	}
+}
+
 func TestMaxResponseSizeHandling(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -1402,9 +1723,9 @@ func TestMaxResponseSizeHandling(t *testing.T) {
 			
 			var err error
 			if tt.endpoint == "collections" {
-				_, err = client.GetCollections()
+				_, err = client.GetCollections(context.Background())
 			} else {
-				_, err = client.GetRaindrops()
+				_, err = client.GetRaindrops(context.Background())
 			}
 			
 			elapsed := time.Since(start)
@@ -1466,7 +1787,7 @@ func TestConcurrentErrorHandling(t *testing.T) {
 			// Start multiple goroutines making requests
 			for i := 0; i < numGoroutines; i++ {
 				go func() {
-					_, err := client.GetCollections()
+					_, err := client.GetCollections(context.Background())
 					errChan <- err
 				}()
 			}
@@ -1560,9 +1881,9 @@ func TestPartialJSONDecoding(t *testing.T) {
 
 			var err error
 			if tt.endpoint == "collections" {
-				_, err = client.GetCollections()
+				_, err = client.GetCollections(context.Background())
 			} else {
-				_, err = client.GetRaindrops()
+				_, err = client.GetRaindrops(context.Background())
 			}
 
 			if tt.expectError {
@@ -1653,7 +1974,7 @@ func TestRaindropsPaginationErrorHandling(t *testing.T) {
 			})
 			defer server.Close()
 
-			_, err := client.GetRaindrops()
+			_, err := client.GetRaindrops(context.Background())
 
 			if tt.expectError {
 				if err == nil {
@@ -1712,9 +2033,9 @@ func TestErrorMessageFormatting(t *testing.T) {
 
 			var err error
 			if tt.endpoint == "collections" {
-				_, err = client.GetCollections()
+				_, err = client.GetCollections(context.Background())
 			} else {
-				_, err = client.GetRaindrops()
+				_, err = client.GetRaindrops(context.Background())
 			}
 
 			if err == nil {
@@ -1727,3 +2048,110 @@ func TestErrorMessageFormatting(t *testing.T) {
 		})
 	}
 }
+
+func TestRetryPolicyRetriesTransientFailures(t *testing.T) {
+	var attemptCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&attemptCount, 1) {
+		case 1:
+			// Drop the connection without a response.
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		case 2:
+			w.WriteHeader(http.StatusGatewayTimeout)
+		default:
+			fmt.Fprintln(w, `{"items": [{"_id": 1, "title": "Reading"}]}`)
+		}
+	}))
+	defer server.Close()
+
+	sleeper := &MockSleeper{}
+	client := NewClient("test-token")
+	client.SetBaseURL(server.URL + "/rest/v1")
+	client.SetSleeper(sleeper)
+	client.SetRetryPolicy(retry.Default())
+
+	collections, err := client.GetCollections(context.Background())
+	if err != nil {
+		t.Fatalf("GetCollections failed: %v", err)
+	}
+	if len(collections) != 1 {
+		t.Errorf("Expected 1 collection, got %d", len(collections))
+	}
+	if attemptCount != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attemptCount)
+	}
+	if sleeps := sleeper.GetSleeps(); len(sleeps) != 2 {
+		t.Errorf("Expected 2 sleep calls, got %v", sleeps)
+	}
+}
+
+func TestCreateCollection(t *testing.T) {
+	client, server := createTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/rest/v1/collection" {
+			t.Errorf("Expected POST /rest/v1/collection, got %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body["title"] != "Go" || fmt.Sprint(body["parent"]) != "map[$id:7]" {
+			t.Errorf("Unexpected body %v", body)
+		}
+		fmt.Fprint(w, `{"result": true, "item": {"_id": 8, "title": "Go", "parent": {"$id": 7}}}`)
+	})
+	defer server.Close()
+
+	collection, err := client.CreateCollection(context.Background(), "Go", 7)
+	if err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	if collection.ID != 8 || collection.ParentID() != 7 {
+		t.Errorf("Unexpected collection %+v", collection)
+	}
+}
+
+func TestCreateRaindrops(t *testing.T) {
+	client, server := createTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/rest/v1/raindrops" {
+			t.Errorf("Expected POST /rest/v1/raindrops, got %s %s", r.Method, r.URL.Path)
+		}
+		var body struct {
+			Items []map[string]any `json:"items"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Items) != 2 {
+			t.Fatalf("Expected 2 items, got %v", body.Items)
+		}
+		first := body.Items[0]
+		if first["link"] != "https://example.com/1" || first["important"] != true || fmt.Sprint(first["collection"]) != "map[$id:7]" {
+			t.Errorf("Unexpected first item %v", first)
+		}
+		if _, ok := first["created"]; ok {
+			t.Errorf("Expected a zero creation time to be left out, got %v", first["created"])
+		}
+		if fmt.Sprint(first["highlights"]) != "[map[color:yellow text:Quote]]" {
+			t.Errorf("Unexpected highlights %v", first["highlights"])
+		}
+		fmt.Fprint(w, `{"result": true, "items": [{"_id": 101}, {"_id": 102}]}`)
+	})
+	defer server.Close()
+
+	items := []NewRaindrop{
+		{Link: "https://example.com/1", Important: true, Collection: CollectionRef{ID: 7}, Highlights: []NewHighlight{{Text: "Quote", Color: "yellow"}}},
+		{Link: "https://example.com/2", Collection: CollectionRef{ID: 7}},
+	}
+	created, err := client.CreateRaindrops(context.Background(), items)
+	if err != nil {
+		t.Fatalf("CreateRaindrops failed: %v", err)
+	}
+	if len(created) != 2 || created[0].ID != 101 || created[1].ID != 102 {
+		t.Errorf("Unexpected raindrops %+v", created)
+	}
+
+	if _, err := client.CreateRaindrops(context.Background(), make([]NewRaindrop, MaxBatchSize+1)); err == nil {
+		t.Error("Expected an error for a batch over MaxBatchSize, got nil")
+	}
+}
@@ -0,0 +1,173 @@
+// Package ratelimit paces requests to an HTTP API, combining a client-side
+// token bucket with the quota the server reports in its response headers.
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// lowQuota is the fraction of the server's quota below which requests are
+// spread out evenly over the time left until the quota resets.
+const lowQuota = 0.2
+
+// Limiter decides how long each request has to wait. It is safe for
+// concurrent use, and a nil *Limiter never makes requests wait.
+//
+// Requests are limited by a token bucket, when one is configured, and by the
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset and
+// Retry-After headers of the responses passed to Observe. Once the remaining
+// quota drops below a fifth of the limit, requests are spaced so that the
+// quota lasts until it resets, and when it is used up, or the server asks
+// the client to retry later, requests wait until that time.
+type Limiter struct {
+	mu  sync.Mutex
+	now func() time.Time
+
+	// Token bucket. rate is zero when the bucket is disabled.
+	rate     float64
+	burst    float64
+	tokens   float64
+	refilled time.Time
+
+	// Quota reported by the server. limit is zero until it has been seen.
+	limit     int
+	remaining int
+	reset     time.Time
+	lastSent  time.Time
+
+	// blockedUntil is the time the server asked the client to wait until.
+	blockedUntil time.Time
+}
+
+// New returns a Limiter that allows requestsPerSecond requests per second on
+// average, in bursts of up to burst requests. A requestsPerSecond of zero or
+// less disables the token bucket, so that only the server's headers slow
+// requests down.
+func New(requestsPerSecond float64, burst int) *Limiter {
+	l := &Limiter{now: time.Now}
+	if requestsPerSecond > 0 {
+		l.rate = requestsPerSecond
+		l.burst = float64(max(burst, 1))
+		l.tokens = l.burst
+	}
+	return l
+}
+
+// Reserve takes the next request slot and returns how long to wait before
+// sending the request.
+func (l *Limiter) Reserve() time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	at := now
+
+	if l.rate > 0 {
+		if !l.refilled.IsZero() {
+			l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.refilled).Seconds()*l.rate)
+		}
+		l.refilled = now
+		l.tokens--
+		if l.tokens < 0 {
+			at = now.Add(time.Duration(-l.tokens / l.rate * float64(time.Second)))
+		}
+	}
+
+	if l.limit > 0 && now.Before(l.reset) {
+		switch {
+		case l.remaining <= 0:
+			at = latest(at, l.reset)
+		case float64(l.remaining) < lowQuota*float64(l.limit):
+			spacing := l.reset.Sub(now) / time.Duration(l.remaining+1)
+			at = latest(at, l.lastSent.Add(spacing))
+		}
+		// Count the request against the quota until the response reports
+		// the server's own count, so concurrent requests see it too.
+		l.remaining--
+	}
+
+	at = latest(at, l.blockedUntil)
+	l.lastSent = at
+	return at.Sub(now)
+}
+
+// Observe records the quota reported by a response. It returns how long the
+// server asked the client to wait before its next request, from the
+// Retry-After header or, when the quota is used up, X-RateLimit-Reset.
+func (l *Limiter) Observe(resp *http.Response) (time.Duration, bool) {
+	now := time.Now()
+	if l != nil {
+		now = l.now()
+	}
+
+	var until time.Time
+	limit, limitErr := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	remaining, remainingErr := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	reset, resetOK := parseReset(resp.Header.Get("X-RateLimit-Reset"), now)
+	if remainingErr == nil && remaining <= 0 && resetOK {
+		until = reset
+	}
+	if retryAfter, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+		until = latest(until, now.Add(retryAfter))
+	}
+
+	if l != nil {
+		l.mu.Lock()
+		if limitErr == nil && remainingErr == nil && resetOK && limit > 0 {
+			l.limit, l.remaining, l.reset = limit, remaining, reset
+		}
+		l.blockedUntil = latest(l.blockedUntil, until)
+		l.mu.Unlock()
+	}
+
+	if !until.After(now) {
+		return 0, false
+	}
+	return until.Sub(now), true
+}
+
+// ParseRetryAfter parses a Retry-After header, which holds either a number of
+// seconds or an HTTP date.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// parseReset parses an X-RateLimit-Reset header. Raindrop.io sends the Unix
+// time at which the quota resets, while other servers send the number of
+// seconds until then, so small values are taken as a number of seconds.
+func parseReset(value string, now time.Time) (time.Time, bool) {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, false
+	}
+	if seconds < 1_000_000_000 {
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+	return time.Unix(seconds, 0), true
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
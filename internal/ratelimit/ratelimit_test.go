@@ -0,0 +1,160 @@
+//go:build !integration
+
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestLimiter(requestsPerSecond float64, burst int) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l := New(requestsPerSecond, burst)
+	l.now = clock.Now
+	return l, clock
+}
+
+func response(headers map[string]string) *http.Response {
+	resp := &http.Response{Header: make(http.Header)}
+	for key, value := range headers {
+		resp.Header.Set(key, value)
+	}
+	return resp
+}
+
+func TestTokenBucket(t *testing.T) {
+	l, clock := newTestLimiter(10, 2)
+
+	var delays []time.Duration
+	for range 4 {
+		delays = append(delays, l.Reserve())
+	}
+	expected := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for n := range expected {
+		if delays[n] != expected[n] {
+			t.Errorf("Expected delays %v, got %v", expected, delays)
+			break
+		}
+	}
+
+	// After a second the bucket is full again.
+	clock.Advance(time.Second)
+	if d := l.Reserve(); d != 0 {
+		t.Errorf("Expected no delay after the bucket refilled, got %v", d)
+	}
+}
+
+func TestUnlimitedAndNil(t *testing.T) {
+	l, _ := newTestLimiter(0, 0)
+	for range 100 {
+		if d := l.Reserve(); d != 0 {
+			t.Fatalf("Expected no delay without a rate, got %v", d)
+		}
+	}
+
+	var nilLimiter *Limiter
+	if d := nilLimiter.Reserve(); d != 0 {
+		t.Errorf("Expected no delay from a nil limiter, got %v", d)
+	}
+	if d, ok := nilLimiter.Observe(response(map[string]string{"Retry-After": "3"})); !ok || d != 3*time.Second {
+		t.Errorf("Expected a nil limiter to report Retry-After, got %v, %v", d, ok)
+	}
+}
+
+func TestWaitsUntilResetWhenQuotaIsUsedUp(t *testing.T) {
+	l, clock := newTestLimiter(0, 0)
+	reset := clock.now.Add(42 * time.Second)
+
+	d, ok := l.Observe(response(map[string]string{
+		"X-RateLimit-Limit":     "120",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     strconv.FormatInt(reset.Unix(), 10),
+	}))
+	if !ok || d != 42*time.Second {
+		t.Errorf("Expected Observe to report 42s until the reset, got %v, %v", d, ok)
+	}
+	if d := l.Reserve(); d != 42*time.Second {
+		t.Errorf("Expected the next request to wait exactly until the reset, got %v", d)
+	}
+
+	clock.Advance(time.Minute)
+	if d := l.Reserve(); d != 0 {
+		t.Errorf("Expected no delay after the reset, got %v", d)
+	}
+}
+
+func TestSlowsDownWhenQuotaRunsLow(t *testing.T) {
+	l, _ := newTestLimiter(0, 0)
+
+	l.Observe(response(map[string]string{
+		"X-RateLimit-Limit":     "100",
+		"X-RateLimit-Remaining": "50",
+		"X-RateLimit-Reset":     "60",
+	}))
+	if d := l.Reserve(); d != 0 {
+		t.Errorf("Expected no delay with plenty of quota left, got %v", d)
+	}
+
+	l.Observe(response(map[string]string{
+		"X-RateLimit-Limit":     "100",
+		"X-RateLimit-Remaining": "9",
+		"X-RateLimit-Reset":     "60",
+	}))
+	first := l.Reserve()
+	second := l.Reserve()
+	if first <= 0 || second <= first {
+		t.Errorf("Expected increasing delays with little quota left, got %v and %v", first, second)
+	}
+	// Nine requests left for sixty seconds are spaced six seconds apart.
+	if first != 6*time.Second {
+		t.Errorf("Expected a spacing of 6s, got %v", first)
+	}
+}
+
+func TestRetryAfterBlocksAllRequests(t *testing.T) {
+	l, clock := newTestLimiter(0, 0)
+
+	d, ok := l.Observe(response(map[string]string{"Retry-After": clock.now.Add(5 * time.Second).Format(http.TimeFormat)}))
+	if !ok || d != 5*time.Second {
+		t.Errorf("Expected Observe to report 5s, got %v, %v", d, ok)
+	}
+	for range 3 {
+		if d := l.Reserve(); d != 5*time.Second {
+			t.Errorf("Expected every request to wait 5s, got %v", d)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"2", 2 * time.Second, true},
+		{"0.5", 500 * time.Millisecond, true},
+		{"-1", 0, false},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second, true},
+		{now.Add(-10 * time.Second).Format(http.TimeFormat), 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		d, ok := ParseRetryAfter(tt.value, now)
+		if d != tt.expected || ok != tt.ok {
+			t.Errorf("ParseRetryAfter(%q) = %v, %v, expected %v, %v", tt.value, d, ok, tt.expected, tt.ok)
+		}
+	}
+}
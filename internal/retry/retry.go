@@ -0,0 +1,147 @@
+// Package retry decides which failed HTTP requests are worth sending again
+// and how long to wait before doing so.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Policy controls how a client retries requests. Rate limited requests (429)
+// are always retried; transient failures only when Transient is set.
+type Policy struct {
+	// MaxRetries is the number of times a request is sent again after the
+	// first attempt. Zero disables retries.
+	MaxRetries int
+	// BaseDelay is the wait before the first retry. It doubles with every
+	// further attempt, plus up to 10% jitter.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between attempts. Zero means no cap. Delays
+	// the server asks for with Retry-After are not capped.
+	MaxDelay time.Duration
+	// Transient also retries timeouts, dropped connections and 500, 502, 503
+	// and 504 responses. POST and PATCH requests are only retried after a
+	// failed connection when it was never made, and after a 503.
+	Transient bool
+}
+
+// RateLimitOnly is the policy of a client that has not been given one: it
+// retries rate limited requests five times and nothing else.
+var RateLimitOnly = Policy{MaxRetries: 5, BaseDelay: time.Second}
+
+// Default returns the policy used by the command line tool, which also
+// retries transient failures.
+func Default() Policy {
+	return Policy{MaxRetries: 5, BaseDelay: time.Second, MaxDelay: 30 * time.Second, Transient: true}
+}
+
+// Backoff returns the delay before retry number attempt+1.
+func (p Policy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay * time.Duration(1<<uint(min(attempt, 30)))
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay + time.Duration(rand.Float64()*float64(delay)*0.1)
+}
+
+// ShouldRetryStatus reports whether a response with the given status to a
+// request with the given method is retried under the policy, apart from 429
+// which always is. A POST or PATCH request that got a 500, 502 or 504 may
+// have been handled, so only a 503 is sent again.
+func (p Policy) ShouldRetryStatus(method string, code int) bool {
+	if !p.Transient || !IsTransientStatus(code) {
+		return false
+	}
+	return IsIdempotent(method) || code == http.StatusServiceUnavailable
+}
+
+// ShouldRetryError reports whether a request with the given method that
+// failed with err is retried under the policy. POST and PATCH requests may
+// have been handled by the server before the connection failed, so they are
+// only sent again when the connection was never made.
+func (p Policy) ShouldRetryError(method string, err error) bool {
+	if !p.Transient || !IsTransientError(err) {
+		return false
+	}
+	return IsIdempotent(method) || IsConnectError(err)
+}
+
+// IsIdempotent reports whether sending a request with the given method twice
+// has the same effect as sending it once.
+func IsIdempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// IsConnectError reports whether err, as returned by http.Client.Do, shows
+// that the connection to the server could not be made, so that the request
+// was never sent.
+func IsConnectError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// IsTransientStatus reports whether a response status is usually caused by a
+// server or proxy that is briefly unavailable.
+func IsTransientStatus(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// IsTransientError reports whether err, as returned by http.Client.Do, is a
+// timeout or a connection failure that may succeed when tried again. Unknown
+// hosts and cancelled requests are not transient.
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// Rewind prepares req to be sent again by replacing its consumed body with a
+// fresh copy. Requests built by http.NewRequest from a bytes.Buffer,
+// bytes.Reader or strings.Reader can always be rewound.
+func Rewind(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.GetBody == nil {
+		return errors.New("request body cannot be sent again")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	return nil
+}
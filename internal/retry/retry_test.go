@@ -0,0 +1,153 @@
+//go:build !integration
+
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := Policy{MaxRetries: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		d := p.Backoff(attempt)
+		if d < expected || d > expected+expected/10 {
+			t.Errorf("Backoff(%d) = %v, expected %v plus up to 10%%", attempt, d, expected)
+		}
+	}
+
+	if d := RateLimitOnly.Backoff(5); d < 32*time.Second {
+		t.Errorf("Expected no cap without MaxDelay, got %v", d)
+	}
+}
+
+func TestIsTransientStatus(t *testing.T) {
+	for code, expected := range map[int]bool{
+		http.StatusOK:                  false,
+		http.StatusBadRequest:          false,
+		http.StatusUnauthorized:        false,
+		http.StatusNotFound:            false,
+		http.StatusTooManyRequests:     false,
+		http.StatusInternalServerError: true,
+		http.StatusNotImplemented:      false,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+		http.StatusGatewayTimeout:      true,
+	} {
+		if IsTransientStatus(code) != expected {
+			t.Errorf("IsTransientStatus(%d) = %v, expected %v", code, !expected, expected)
+		}
+	}
+
+	if (Policy{}).ShouldRetryStatus("GET", http.StatusBadGateway) {
+		t.Error("Expected a policy without Transient not to retry server errors")
+	}
+
+	// A POST or PATCH that got a server error may have been handled.
+	policy := Default()
+	for _, tt := range []struct {
+		method   string
+		code     int
+		expected bool
+	}{
+		{"GET", http.StatusBadGateway, true},
+		{"PUT", http.StatusInternalServerError, true},
+		{"POST", http.StatusInternalServerError, false},
+		{"POST", http.StatusBadGateway, false},
+		{"PATCH", http.StatusGatewayTimeout, false},
+		{"POST", http.StatusServiceUnavailable, true},
+		{"PATCH", http.StatusServiceUnavailable, true},
+	} {
+		if got := policy.ShouldRetryStatus(tt.method, tt.code); got != tt.expected {
+			t.Errorf("ShouldRetryStatus(%s, %d) = %v, expected %v", tt.method, tt.code, got, tt.expected)
+		}
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	urlError := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com", Err: err}
+	}
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"timeout", urlError(os.ErrDeadlineExceeded), true},
+		{"connection reset", urlError(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), true},
+		{"connection refused", urlError(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), true},
+		{"closed by server", urlError(io.EOF), true},
+		{"unknown host", urlError(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "invalid.local", IsNotFound: true}}), false},
+		{"DNS timeout", urlError(&net.DNSError{Err: "timeout", Name: "example.com", IsTimeout: true}), true},
+		{"cancelled", urlError(context.Canceled), false},
+		{"other", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		if got := IsTransientError(tt.err); got != tt.expected {
+			t.Errorf("%s: IsTransientError(%v) = %v, expected %v", tt.name, tt.err, got, tt.expected)
+		}
+	}
+}
+
+func TestShouldRetryError(t *testing.T) {
+	refused := &url.Error{Op: "Post", URL: "https://example.com", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}
+	dropped := &url.Error{Op: "Post", URL: "https://example.com", Err: io.EOF}
+	policy := Default()
+	tests := []struct {
+		method   string
+		err      error
+		expected bool
+	}{
+		{"GET", dropped, true},
+		{"PUT", dropped, true},
+		{"DELETE", dropped, true},
+		{"POST", dropped, false},
+		{"PATCH", dropped, false},
+		{"POST", refused, true},
+		{"PATCH", refused, true},
+	}
+	for _, tt := range tests {
+		if got := policy.ShouldRetryError(tt.method, tt.err); got != tt.expected {
+			t.Errorf("ShouldRetryError(%s, %v) = %v, expected %v", tt.method, tt.err, got, tt.expected)
+		}
+	}
+	if RateLimitOnly.ShouldRetryError("GET", refused) {
+		t.Error("Expected RateLimitOnly not to retry connection failures")
+	}
+}
+
+func TestRewind(t *testing.T) {
+	req, err := http.NewRequest("POST", "https://example.com", bytes.NewBufferString("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for attempt := range 2 {
+		if err := Rewind(req); err != nil {
+			t.Fatalf("Rewind failed: %v", err)
+		}
+		body, _ := io.ReadAll(req.Body)
+		if string(body) != "payload" {
+			t.Errorf("Attempt %d read %q, expected the full body", attempt+1, body)
+		}
+	}
+
+	req, _ = http.NewRequest("GET", "https://example.com", nil)
+	if err := Rewind(req); err != nil {
+		t.Errorf("Expected a request without a body to rewind, got %v", err)
+	}
+
+	req, _ = http.NewRequest("POST", "https://example.com", io.NopCloser(bytes.NewBufferString("payload")))
+	if err := Rewind(req); err == nil {
+		t.Error("Expected an error for a body that cannot be rebuilt")
+	}
+}
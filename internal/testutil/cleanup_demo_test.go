@@ -3,6 +3,7 @@
 package testutil
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -57,27 +58,27 @@ func TestCleanupDemonstration(t *testing.T) {
 		
 		// Simulate cleanup - in real tests this would be more complex
 		t.Log("Cleaning up demo bookmarks...")
-		bookmarks, err := client.GetAllBookmarks()
+		bookmarks, err := client.GetAllBookmarks(context.Background())
 		if err != nil {
 			t.Logf("Failed to get bookmarks for cleanup: %v", err)
 		} else {
 			for _, bookmark := range bookmarks {
 				if bookmark.Title == "[Test] Demo Bookmark" {
 					t.Logf("Would delete bookmark: %s", bookmark.Title)
-					// In real cleanup, we would call client.DeleteBookmark(bookmark.ID)
+					// In real cleanup, we would call client.DeleteBookmark(context.Background(), bookmark.ID)
 				}
 			}
 		}
 
 		t.Log("Cleaning up demo lists...")
-		lists, err := client.GetAllLists()
+		lists, err := client.GetAllLists(context.Background())
 		if err != nil {
 			t.Logf("Failed to get lists for cleanup: %v", err)
 		} else {
 			for _, list := range lists {
 				if list.Name == "[Test] Demo List" {
 					t.Logf("Would delete list: %s", list.Name)
-					// In real cleanup, we would call client.DeleteList(list.ID)
+					// In real cleanup, we would call client.DeleteList(context.Background(), list.ID)
 				}
 			}
 		}
@@ -91,7 +92,7 @@ func TestCleanupDemonstration(t *testing.T) {
 		Title: "[Test] Demo Bookmark",
 	}
 	
-	createdBookmark, err := client.CreateBookmark(bookmark)
+	createdBookmark, err := client.CreateBookmark(context.Background(), bookmark)
 	if err != nil {
 		t.Fatalf("Failed to create bookmark: %v", err)
 	}
@@ -101,7 +102,7 @@ func TestCleanupDemonstration(t *testing.T) {
 		Name: "[Test] Demo List",
 	}
 	
-	createdList, err := client.CreateList(list)
+	createdList, err := client.CreateList(context.Background(), list)
 	if err != nil {
 		t.Fatalf("Failed to create list: %v", err)
 	}
@@ -25,12 +25,11 @@ class Rainbridge < Formula
   depends_on "go"
 
   def install
-    system "go", "build", "-ldflags", "-s -w", "-o", bin/"rainbridge", "./cmd/rainbridge"
+    system "go", "build", "-ldflags", "-s -w -X main.version=#{version}", "-o", bin/"rainbridge", "./cmd/rainbridge"
   end
 
   test do
-    # Basic test to ensure the binary runs
-    system bin/"rainbridge", "--version"
+    assert_match version.to_s, shell_output("#{bin}/rainbridge --version")
   end
 end
 EOF